@@ -0,0 +1,282 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"taxowalk/internal/cmdutil"
+	"taxowalk/internal/history"
+	"taxowalk/internal/taxonomy"
+)
+
+var version = "dev"
+
+// Exit codes follow diff(1).
+const (
+	exitSame    = 0
+	exitChanged = 1
+	exitError   = 2
+)
+
+func main() {
+	changed, err := run()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "taxodiff:", err)
+		os.Exit(exitError)
+	}
+	if changed {
+		os.Exit(exitChanged)
+	}
+	os.Exit(exitSame)
+}
+
+// affected is a recorded classification whose category changed.
+type affected struct {
+	RecordID    int64     `json:"record_id"`
+	Timestamp   time.Time `json:"timestamp"`
+	Description string    `json:"description"`
+	CategoryID  string    `json:"category_id"`
+	Category    string    `json:"category"`
+	Change      string    `json:"change"`
+	// Via is the renamed or moved ancestor when the category itself is
+	// unchanged but its full path is not.
+	Via         string `json:"via,omitempty"`
+	NewFullName string `json:"new_full_name,omitempty"`
+}
+
+type report struct {
+	*taxonomy.Diff
+	Affected []affected `json:"affected,omitempty"`
+}
+
+func run() (bool, error) {
+	var (
+		showVersion bool
+		format      string
+		dbPath      string
+		loc         string
+		taxFormat   string
+		refresh     bool
+		maxAge      time.Duration
+	)
+	flag.StringVar(&format, "format", "text", "output format: text, json or markdown")
+	flag.StringVar(&dbPath, "history-db", "", "taxowalk history database whose classifications are checked against the changes")
+	flag.StringVar(&loc, "locale", "", "taxonomy locale to load from both sources, e.g. en, fr, de")
+	flag.StringVar(&taxFormat, "taxonomy-format", taxonomy.FormatAuto, "format of both taxonomy files: "+strings.Join(append([]string{taxonomy.FormatAuto}, taxonomy.Formats()...), ", "))
+	flag.BoolVar(&refresh, "refresh-taxonomy", false, "ignore cached taxonomy data and fetch fresh copies")
+	cmdutil.RegisterCacheMaxAge(flag.CommandLine, &maxAge)
+	flag.BoolVar(&showVersion, "version", false, "print the taxodiff version and the embedded taxonomy version, then exit")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "taxodiff - compare two taxonomy versions\n\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] <old taxonomy> <new taxonomy>\n\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "Flags:\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if showVersion {
+		fmt.Println(cmdutil.VersionLine("taxodiff", version))
+		return false, nil
+	}
+	if flag.NArg() != 2 {
+		return false, errors.New("an old and a new taxonomy URL or file path must be provided")
+	}
+	var write func(io.Writer, report) error
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case "text":
+		write = writeText
+	case "json":
+		write = writeJSON
+	case "markdown", "md":
+		write = writeMarkdown
+	default:
+		return false, fmt.Errorf("unknown format %q (want text, json or markdown)", format)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	opts := append(cmdutil.FetchOptions(refresh, maxAge), taxonomy.WithFormat(taxFormat))
+	var taxonomies [2]*taxonomy.Taxonomy
+	for i, source := range flag.Args() {
+		tax, err := taxonomy.Fetch(ctx, cmdutil.LocaleURL(source, loc), opts...)
+		if err != nil {
+			return false, fmt.Errorf("failed to load taxonomy %s: %w", source, err)
+		}
+		taxonomies[i] = tax
+	}
+
+	out := report{Diff: taxonomy.Compare(taxonomies[0], taxonomies[1])}
+	if dbPath != "" {
+		db, err := history.Open(dbPath)
+		if err != nil {
+			return false, fmt.Errorf("failed to open database: %w", err)
+		}
+		defer db.Close()
+		records, err := db.GetAllRecords()
+		if err != nil {
+			return false, err
+		}
+		out.Affected = affectedRecords(out.Diff, taxonomies[0], taxonomies[1], records)
+	}
+
+	if err := write(os.Stdout, out); err != nil {
+		return false, err
+	}
+	return !out.Empty(), nil
+}
+
+// affectedRecords returns the records whose category was removed, renamed
+// or moved, or lies below a renamed or moved category, which changes its
+// full path too.
+func affectedRecords(d *taxonomy.Diff, older, newer *taxonomy.Taxonomy, records []history.ClassificationRecord) []affected {
+	var out []affected
+	for _, r := range records {
+		kind, change, ok := d.Changed(r.CategoryID)
+		var via string
+		if !ok {
+			if kind, via, ok = changedAncestor(d, older, r.CategoryID); !ok {
+				continue
+			}
+			if node := newer.FindByID(r.CategoryID); node != nil {
+				change.NewFullName = node.FullName
+			}
+		}
+		out = append(out, affected{
+			RecordID:    r.ID,
+			Timestamp:   r.Timestamp,
+			Description: r.ProductDesc,
+			CategoryID:  r.CategoryID,
+			Category:    r.Category,
+			Change:      kind,
+			Via:         via,
+			NewFullName: change.NewFullName,
+		})
+	}
+	return out
+}
+
+// changedAncestor returns the change of the nearest ancestor of id in the
+// older taxonomy that was renamed or moved, and that ancestor's ID.
+func changedAncestor(d *taxonomy.Diff, older *taxonomy.Taxonomy, id string) (string, string, bool) {
+	node := older.FindByID(id)
+	if node == nil {
+		return "", "", false
+	}
+	for parent := range node.Parents() {
+		if parent.ID == "" {
+			continue
+		}
+		if kind, _, ok := d.Changed(parent.ID); ok && kind != "removed" {
+			return kind, parent.ID, true
+		}
+	}
+	return "", "", false
+}
+
+func writeJSON(w io.Writer, r report) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(r)
+}
+
+func writeText(w io.Writer, r report) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Taxonomy %s -> %s\n", versionLabel(r.OldVersion), versionLabel(r.NewVersion))
+	if r.Empty() {
+		b.WriteString("No changes.\n")
+	}
+	section := func(title string, changes []taxonomy.Change, line func(taxonomy.Change) string) {
+		if len(changes) == 0 {
+			return
+		}
+		fmt.Fprintf(&b, "\n%s (%d):\n", title, len(changes))
+		for _, c := range changes {
+			fmt.Fprintf(&b, "  %s\n", line(c))
+		}
+	}
+	section("Added", r.Added, func(c taxonomy.Change) string { return "+ " + c.ID + "  " + c.NewFullName })
+	section("Removed", r.Removed, func(c taxonomy.Change) string { return "- " + c.ID + "  " + c.OldFullName })
+	section("Renamed", r.Renamed, func(c taxonomy.Change) string { return "~ " + c.ID + "  " + c.OldName + " -> " + c.NewName })
+	section("Moved", r.Moved, func(c taxonomy.Change) string { return "> " + c.ID + "  " + c.OldFullName + " -> " + c.NewFullName })
+	if len(r.Affected) > 0 {
+		fmt.Fprintf(&b, "\nAffected classifications (%d):\n", len(r.Affected))
+		for _, a := range r.Affected {
+			fmt.Fprintf(&b, "  #%d  %s  %-8s %s%s  %s\n", a.RecordID, a.Timestamp.Format("2006-01-02 15:04:05"), a.Change, a.CategoryID, viaLabel(a.Via), truncate(a.Description, 60))
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeMarkdown(w io.Writer, r report) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Taxonomy %s → %s\n", versionLabel(r.OldVersion), versionLabel(r.NewVersion))
+	if r.Empty() {
+		b.WriteString("\nNo changes.\n")
+	}
+	table := func(title, header string, changes []taxonomy.Change, row func(taxonomy.Change) string) {
+		if len(changes) == 0 {
+			return
+		}
+		fmt.Fprintf(&b, "\n## %s (%d)\n\n%s\n", title, len(changes), header)
+		for _, c := range changes {
+			b.WriteString(row(c) + "\n")
+		}
+	}
+	table("Added", "| ID | Category |\n| --- | --- |", r.Added, func(c taxonomy.Change) string {
+		return "| `" + c.ID + "` | " + markdownCell(c.NewFullName) + " |"
+	})
+	table("Removed", "| ID | Category |\n| --- | --- |", r.Removed, func(c taxonomy.Change) string {
+		return "| `" + c.ID + "` | " + markdownCell(c.OldFullName) + " |"
+	})
+	table("Renamed", "| ID | Old name | New name |\n| --- | --- | --- |", r.Renamed, func(c taxonomy.Change) string {
+		return "| `" + c.ID + "` | " + markdownCell(c.OldName) + " | " + markdownCell(c.NewName) + " |"
+	})
+	table("Moved", "| ID | Old path | New path |\n| --- | --- | --- |", r.Moved, func(c taxonomy.Change) string {
+		return "| `" + c.ID + "` | " + markdownCell(c.OldFullName) + " | " + markdownCell(c.NewFullName) + " |"
+	})
+	if len(r.Affected) > 0 {
+		fmt.Fprintf(&b, "\n## Affected classifications (%d)\n\n| Record | Timestamp | Change | Category ID | Description |\n| --- | --- | --- | --- | --- |\n", len(r.Affected))
+		for _, a := range r.Affected {
+			fmt.Fprintf(&b, "| %d | %s | %s%s | `%s` | %s |\n", a.RecordID, a.Timestamp.Format("2006-01-02 15:04:05"), a.Change, viaLabel(a.Via), a.CategoryID, markdownCell(truncate(a.Description, 80)))
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func viaLabel(via string) string {
+	if via == "" {
+		return ""
+	}
+	return " (via " + via + ")"
+}
+
+func versionLabel(v string) string {
+	if v == "" {
+		return "(unversioned)"
+	}
+	return v
+}
+
+func markdownCell(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	return strings.ReplaceAll(s, "\n", " ")
+}
+
+func truncate(s string, n int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if r := []rune(s); len(r) > n {
+		return string(r[:n-3]) + "..."
+	}
+	return s
+}
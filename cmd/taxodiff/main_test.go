@@ -0,0 +1,69 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"taxowalk/internal/history"
+	"taxowalk/internal/taxonomy"
+)
+
+func testTaxonomy(version, parentName string, moved bool) *taxonomy.Taxonomy {
+	leaf := &taxonomy.Node{ID: "aa-1-1", Name: "Shirts", FullName: parentName + " > Shirts"}
+	other := &taxonomy.Node{ID: "aa-2", Name: "Shoes", FullName: "Apparel > Shoes"}
+	parent := &taxonomy.Node{ID: "aa-1", Name: parentName, FullName: "Apparel > " + parentName, Children: []*taxonomy.Node{leaf}}
+	root := &taxonomy.Node{ID: "aa", Name: "Apparel", FullName: "Apparel", Children: []*taxonomy.Node{parent, other}}
+	if moved {
+		root.Children = []*taxonomy.Node{other}
+		other.Children = []*taxonomy.Node{parent}
+	}
+	tax := &taxonomy.Taxonomy{Version: version, Roots: []*taxonomy.Node{root}}
+	tax.Index()
+	return tax
+}
+
+func TestAffectedRecordsIncludesDescendants(t *testing.T) {
+	db, err := history.Open(filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+	defer db.Close()
+	for _, id := range []string{"aa-1-1", "aa-1", "aa-2"} {
+		if err := db.RecordClassification("product "+id, "name", id, 1, 1, 2); err != nil {
+			t.Fatalf("RecordClassification returned error: %v", err)
+		}
+	}
+	records, err := db.GetAllRecords()
+	if err != nil {
+		t.Fatalf("GetAllRecords returned error: %v", err)
+	}
+
+	older := testTaxonomy("1", "Tops", false)
+	newer := testTaxonomy("2", "Upper Body", false)
+	got := affectedRecords(taxonomy.Compare(older, newer), older, newer, records)
+	byID := map[string]affected{}
+	for _, a := range got {
+		byID[a.CategoryID] = a
+	}
+	if len(got) != 2 {
+		t.Fatalf("affected = %+v, want aa-1 and aa-1-1", got)
+	}
+	if a := byID["aa-1"]; a.Change != "renamed" || a.Via != "" {
+		t.Fatalf("renamed category reported as %+v", a)
+	}
+	if a := byID["aa-1-1"]; a.Change != "renamed" || a.Via != "aa-1" || a.NewFullName != "Upper Body > Shirts" {
+		t.Fatalf("child of a renamed category reported as %+v", a)
+	}
+
+	// Moving the parent changes the path of the grandchild's record too.
+	newer = testTaxonomy("2", "Tops", true)
+	got = affectedRecords(taxonomy.Compare(older, newer), older, newer, records)
+	if len(got) != 2 {
+		t.Fatalf("affected after a move = %+v, want aa-1 and aa-1-1", got)
+	}
+	for _, a := range got {
+		if a.Change != "moved" || (a.CategoryID == "aa-1-1") != (a.Via == "aa-1") {
+			t.Fatalf("unexpected affected record after a move: %+v", a)
+		}
+	}
+}
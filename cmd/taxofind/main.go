@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"taxowalk/internal/cmdutil"
+	"taxowalk/internal/locale"
+	"taxowalk/internal/taxonomy"
+)
+
+var version = "dev"
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, "taxofind:", err)
+		os.Exit(1)
+	}
+}
+
+type jsonMatch struct {
+	ID       string  `json:"id"`
+	Name     string  `json:"name"`
+	FullName string  `json:"full_name"`
+	Score    float64 `json:"score"`
+	Depth    int     `json:"depth"`
+	Leaf     bool    `json:"leaf"`
+}
+
+func run() error {
+	var (
+		showVersion bool
+		limit       int
+		leavesOnly  bool
+		under       string
+		jsonOutput  bool
+	)
+	taxFlags := cmdutil.NewTaxonomyFlags()
+	taxFlags.Register(flag.CommandLine)
+	flag.IntVar(&limit, "limit", 10, "maximum number of matches to print (0 prints all)")
+	flag.BoolVar(&leavesOnly, "leaves", false, "only match leaf categories")
+	flag.StringVar(&under, "under", "", "only match the category with this ID and its descendants")
+	flag.BoolVar(&jsonOutput, "json", false, "print the matches as a JSON array")
+	flag.BoolVar(&showVersion, "version", false, "print the taxofind version and the embedded taxonomy version, then exit")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "taxofind - search taxonomy categories by name\n\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] <query>\n\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "Flags:\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if showVersion {
+		fmt.Println(cmdutil.VersionLine("taxofind", version))
+		return nil
+	}
+
+	query := strings.TrimSpace(strings.Join(flag.Args(), " "))
+	if query == "" {
+		return errors.New("a search query must be provided")
+	}
+	if locale.Normalize(taxFlags.Locale) == locale.Auto {
+		taxFlags.Locale = locale.Detect(query)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	tax, err := taxFlags.Fetch(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load taxonomy: %w", err)
+	}
+
+	opts := []taxonomy.SearchOption{taxonomy.WithLimit(limit)}
+	if leavesOnly {
+		opts = append(opts, taxonomy.WithLeavesOnly())
+	}
+	if under != "" {
+		root := tax.FindByID(under)
+		if root == nil {
+			return fmt.Errorf("taxonomy category %q not found", under)
+		}
+		opts = append(opts, taxonomy.WithSubtree(root))
+	}
+	matches := tax.Search(query, opts...)
+
+	if jsonOutput {
+		out := make([]jsonMatch, len(matches))
+		for i, m := range matches {
+			out[i] = jsonMatch{ID: m.Node.ID, Name: m.Node.Name, FullName: m.Node.FullName, Score: math.Round(m.Score*1000) / 1000, Depth: m.Node.Depth, Leaf: m.Node.Leaf}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(out); err != nil {
+			return err
+		}
+	} else if len(matches) > 0 {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "SCORE\tID\tCATEGORY")
+		for _, m := range matches {
+			fmt.Fprintf(w, "%.2f\t%s\t%s\n", m.Score, m.Node.ID, m.Node.FullName)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+	if len(matches) == 0 {
+		return fmt.Errorf("no categories match %q", query)
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"taxowalk/internal/cmdutil"
+	"taxowalk/internal/taxonomy"
+)
+
+var version = "dev"
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, "taxomap:", err)
+		os.Exit(1)
+	}
+}
+
+type jsonResult struct {
+	From    string        `json:"from"`
+	To      string        `json:"to"`
+	Results []jsonMapping `json:"results"`
+}
+
+type jsonMapping struct {
+	ID     string                    `json:"id"`
+	Mapped []taxonomy.MappedCategory `json:"mapped"`
+}
+
+func run() error {
+	var (
+		showVersion bool
+		mappingURL  string
+		loc         string
+		refresh     bool
+		maxAge      time.Duration
+		from        string
+		to          string
+		list        bool
+		useStdin    bool
+		jsonOutput  bool
+	)
+	flag.StringVar(&mappingURL, "mapping-url", cmdutil.DefaultMappingURL, "URL or file path for the Shopify mapping file")
+	flag.StringVar(&loc, "locale", "", "mapping locale to load, e.g. en, fr, de")
+	flag.BoolVar(&refresh, "refresh-taxonomy", false, "ignore cached mapping data and fetch a fresh copy")
+	cmdutil.RegisterCacheMaxAge(flag.CommandLine, &maxAge)
+	flag.StringVar(&from, "from", "shopify", "taxonomy to map from, optionally with a version (e.g. shopify/2024-07)")
+	flag.StringVar(&to, "to", "google", "taxonomy to map to, optionally with a version (e.g. google or shopify/2025-01)")
+	flag.BoolVar(&list, "list", false, "list the mappings in the mapping file and exit")
+	flag.BoolVar(&useStdin, "stdin", false, "read category IDs from standard input, one per line")
+	flag.BoolVar(&jsonOutput, "json", false, "print the mapped categories as JSON")
+	flag.BoolVar(&showVersion, "version", false, "print the taxomap version and exit")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "taxomap - translate taxonomy IDs between taxonomy versions and systems\n\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] <taxonomy id>...\n\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "Flags:\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if showVersion {
+		fmt.Printf("taxomap %s\n", cmdutil.ResolveVersion(version))
+		return nil
+	}
+
+	ids := flag.Args()
+	if useStdin {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			if id := strings.TrimSpace(scanner.Text()); id != "" {
+				ids = append(ids, id)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return fmt.Errorf("failed to read standard input: %w", err)
+		}
+	}
+	if len(ids) == 0 && !list {
+		return errors.New("at least one taxonomy category ID must be provided")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	mappings, err := taxonomy.FetchMappings(ctx, cmdutil.LocaleURL(mappingURL, loc), cmdutil.FetchOptions(refresh, maxAge)...)
+	if err != nil {
+		return fmt.Errorf("failed to load mappings: %w", err)
+	}
+	if list {
+		for _, name := range mappings.Names() {
+			fmt.Println(name)
+		}
+		return nil
+	}
+	mapping, err := mappings.Find(from, to)
+	if err != nil {
+		return err
+	}
+
+	out := jsonResult{From: mapping.Input, To: mapping.Output}
+	unmapped := 0
+	for _, id := range ids {
+		mapped := mapping.Map(id)
+		if len(mapped) == 0 {
+			unmapped++
+			mapped = []taxonomy.MappedCategory{}
+		}
+		out.Results = append(out.Results, jsonMapping{ID: id, Mapped: mapped})
+	}
+
+	if jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(out); err != nil {
+			return err
+		}
+	} else {
+		for _, r := range out.Results {
+			if len(r.Mapped) == 0 {
+				fmt.Printf("%s\t-\tunmapped\n", r.ID)
+			}
+			for _, m := range r.Mapped {
+				fmt.Printf("%s\t%s\t%s\t%s\n", r.ID, m.ID, m.Confidence, m.FullName)
+			}
+		}
+	}
+	if unmapped > 0 {
+		return fmt.Errorf("%d of %d ID(s) have no mapping to %s", unmapped, len(ids), mapping.Output)
+	}
+	return nil
+}
@@ -25,7 +25,7 @@ func run() error {
 	var showVersion bool
 	taxFlags := cmdutil.NewTaxonomyFlags()
 	taxFlags.Register(flag.CommandLine)
-	flag.BoolVar(&showVersion, "version", false, "print the taxoname version and exit")
+	flag.BoolVar(&showVersion, "version", false, "print the taxoname version and the embedded taxonomy version, then exit")
 	flag.Usage = func() {
 		fmt.Fprintf(flag.CommandLine.Output(), "taxoname - resolve taxonomy IDs to their full path\n\n")
 		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] <taxonomy id>\n\n", os.Args[0])
@@ -35,7 +35,7 @@ func run() error {
 	flag.Parse()
 
 	if showVersion {
-		fmt.Printf("taxoname %s\n", cmdutil.ResolveVersion(version))
+		fmt.Println(cmdutil.VersionLine("taxoname", version))
 		return nil
 	}
 
@@ -29,7 +29,7 @@ func run() error {
 	)
 	taxFlags := cmdutil.NewTaxonomyFlags()
 	taxFlags.Register(flag.CommandLine)
-	flag.BoolVar(&showVersion, "version", false, "print the taxopath version and exit")
+	flag.BoolVar(&showVersion, "version", false, "print the taxopath version and the embedded taxonomy version, then exit")
 	flag.BoolVar(&showMaximum, "maximum", false, "print the largest number used in any taxonomy path")
 	flag.Usage = func() {
 		fmt.Fprintf(flag.CommandLine.Output(), "taxopath - convert taxonomy IDs to dot-separated paths\n\n")
@@ -40,7 +40,26 @@ func run() error {
 	flag.Parse()
 
 	if showVersion {
-		fmt.Printf("taxopath %s\n", cmdutil.ResolveVersion(version))
+		fmt.Println(cmdutil.VersionLine("taxopath", version))
+		return nil
+	}
+
+	if !showMaximum {
+		if flag.NArg() != 1 {
+			return errors.New("a single taxonomy category ID must be provided")
+		}
+		if strings.TrimSpace(flag.Arg(0)) == "" {
+			return errors.New("taxonomy category ID is empty")
+		}
+	}
+
+	// Shopify IDs have fixed numbers and need no download.
+	if !showMaximum && taxFlags.Shopify() {
+		path, err := taxopath.Path(flag.Arg(0))
+		if err != nil {
+			return err
+		}
+		fmt.Println(path)
 		return nil
 	}
 
@@ -64,20 +83,7 @@ func run() error {
 		return nil
 	}
 
-	if flag.NArg() != 1 {
-		return errors.New("a single taxonomy category ID must be provided")
-	}
-	id := strings.TrimSpace(flag.Arg(0))
-	if id == "" {
-		return errors.New("taxonomy category ID is empty")
-	}
-
-	node := tax.FindByID(id)
-	if node == nil {
-		return fmt.Errorf("taxonomy category %q not found", id)
-	}
-
-	path, err := taxopath.Path(id)
+	path, err := taxopath.PathIn(tax, strings.TrimSpace(flag.Arg(0)))
 	if err != nil {
 		return err
 	}
@@ -21,12 +21,14 @@ func run() error {
 		showAll     bool
 		check24h    bool
 		limitTokens int64
+		discount    float64
 	)
 
 	flag.StringVar(&dbPath, "db", "", "SQLite database path (required)")
 	flag.BoolVar(&showAll, "all", false, "show all classification records")
 	flag.BoolVar(&check24h, "check-24h", false, "check if token usage in last 24 hours exceeds limit")
 	flag.Int64Var(&limitTokens, "limit", 5000000, "token limit for 24-hour check (default: 5000000)")
+	flag.Float64Var(&discount, "cache-discount", 0.9, "fraction of the normal prompt price saved on cached prompt tokens, used to estimate savings")
 	flag.Usage = func() {
 		fmt.Fprintf(flag.CommandLine.Output(), "taxowalk-report - report on token usage from taxowalk history\n\n")
 		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags]\n\n", os.Args[0])
@@ -53,10 +55,10 @@ func run() error {
 		return showAllRecords(db)
 	}
 
-	return showSummary(db)
+	return showSummary(db, discount)
 }
 
-func showSummary(db *history.DB) error {
+func showSummary(db *history.DB, discount float64) error {
 	total, err := db.GetTotalTokens()
 	if err != nil {
 		return err
@@ -72,19 +74,49 @@ func showSummary(db *history.DB) error {
 	fmt.Printf("Total tokens (all time): %d\n", total)
 	fmt.Printf("Tokens (last 24 hours):  %d\n", last24h)
 
+	totals, err := db.GetUsageTotals()
+	if err != nil {
+		return err
+	}
+	if totals.CachedPromptTokens > 0 || totals.ReasoningTokens > 0 {
+		fmt.Printf("\nPrompt tokens:           %d\n", totals.PromptTokens)
+		fmt.Printf("Cached prompt tokens:    %d (%.1f%% of prompt tokens)\n", totals.CachedPromptTokens, percent(totals.CachedPromptTokens, totals.PromptTokens))
+		fmt.Printf("Est. cache savings:      %d prompt-token equivalents at a %.0f%% discount\n", int64(float64(totals.CachedPromptTokens)*discount), discount*100)
+		fmt.Printf("Reasoning tokens:        %d (%.1f%% of completion tokens)\n", totals.ReasoningTokens, percent(totals.ReasoningTokens, totals.CompletionTokens))
+	}
+
+	byModel, err := db.GetTokensByModel()
+	if err != nil {
+		return err
+	}
+	if len(byModel) > 0 {
+		fmt.Printf("\nTokens by model\n")
+		fmt.Printf("%-30s %12s %12s %12s %12s %12s\n", "Model", "Prompt", "Cached", "Compl", "Reasoning", "Total")
+		for _, m := range byModel {
+			fmt.Printf("%-30s %12d %12d %12d %12d %12d\n", m.Model, m.PromptTokens, m.CachedPromptTokens, m.CompletionTokens, m.ReasoningTokens, m.TotalTokens)
+		}
+	}
+
 	return nil
 }
 
+func percent(part, whole int64) float64 {
+	if whole == 0 {
+		return 0
+	}
+	return float64(part) * 100 / float64(whole)
+}
+
 func showAllRecords(db *history.DB) error {
 	records, err := db.GetAllRecords()
 	if err != nil {
 		return err
 	}
 
-	fmt.Printf("%-20s %-40s %-50s %-15s %10s %10s %10s\n",
-		"Timestamp", "Product", "Category", "Category ID",
+	fmt.Printf("%-20s %-40s %-50s %-15s %-12s %10s %10s %10s\n",
+		"Timestamp", "Product", "Category", "Category ID", "Prompt Ver",
 		"Prompt", "Compl", "Total")
-	fmt.Println("------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------")
+	fmt.Println("-------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------")
 
 	for _, r := range records {
 		productDesc := r.ProductDesc
@@ -100,11 +132,12 @@ func showAllRecords(db *history.DB) error {
 			categoryID = categoryID[:12] + "..."
 		}
 
-		fmt.Printf("%-20s %-40s %-50s %-15s %10d %10d %10d\n",
+		fmt.Printf("%-20s %-40s %-50s %-15s %-12s %10d %10d %10d\n",
 			r.Timestamp.Format("2006-01-02 15:04:05"),
 			productDesc,
 			category,
 			categoryID,
+			r.PromptVersion,
 			r.PromptTokens,
 			r.CompletionTokens,
 			r.TotalTokens,
@@ -112,7 +145,7 @@ func showAllRecords(db *history.DB) error {
 	}
 
 	total, _ := db.GetTotalTokens()
-	fmt.Println("------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------")
+	fmt.Println("-------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------")
 	fmt.Printf("Total tokens: %d\n", total)
 
 	return nil
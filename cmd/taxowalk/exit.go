@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+
+	"taxowalk/internal/classifier"
+	"taxowalk/internal/history"
+	"taxowalk/internal/llm"
+)
+
+// Exit codes. They are part of the command's interface and documented in
+// docs/taxowalk.1; do not renumber them.
+const (
+	exitFailure         = 1
+	exitUsage           = 2
+	exitNoMatch         = 3
+	exitAuth            = 4
+	exitRateLimited     = 5
+	exitTimeout         = 6
+	exitRefusal         = 7
+	exitInvalidResponse = 8
+	exitUnavailable     = 9
+	exitTaxonomy        = 10
+	exitBadRequest      = 11
+	exitBudget          = 12
+)
+
+// usageError marks invalid flags, arguments or input files.
+type usageError struct{ err error }
+
+func (e usageError) Error() string { return e.err.Error() }
+func (e usageError) Unwrap() error { return e.err }
+
+// taxonomyError marks a failure to load the taxonomy.
+type taxonomyError struct{ err error }
+
+func (e taxonomyError) Error() string { return e.err.Error() }
+func (e taxonomyError) Unwrap() error { return e.err }
+
+// llmClasses maps the llm error classes onto error codes and exit codes.
+var llmClasses = map[error]struct {
+	code string
+	exit int
+}{
+	llm.ErrAuth:            {"auth", exitAuth},
+	llm.ErrRateLimited:     {"rate_limited", exitRateLimited},
+	llm.ErrTimeout:         {"timeout", exitTimeout},
+	llm.ErrRefusal:         {"refusal", exitRefusal},
+	llm.ErrInvalidResponse: {"invalid_response", exitInvalidResponse},
+	llm.ErrUnavailable:     {"unavailable", exitUnavailable},
+	llm.ErrBadRequest:      {"bad_request", exitBadRequest},
+}
+
+// errorCode returns the machine-readable code and exit code for err.
+func errorCode(err error) (string, int) {
+	var usageErr usageError
+	var taxErr taxonomyError
+	switch {
+	case errors.As(err, &usageErr):
+		return "usage", exitUsage
+	case errors.As(err, &taxErr):
+		return "taxonomy", exitTaxonomy
+	case errors.Is(err, classifier.ErrNoMatch):
+		return "no_match", exitNoMatch
+	case errors.Is(err, classifier.ErrInvalidSelection):
+		return "invalid_response", exitInvalidResponse
+	case errors.Is(err, history.ErrBudgetExhausted):
+		return "budget", exitBudget
+	}
+	if class, ok := llmClasses[llm.Classify(err)]; ok {
+		return class.code, class.exit
+	}
+	return "failure", exitFailure
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"taxowalk/internal/classifier"
+	"taxowalk/internal/history"
+	"taxowalk/internal/llm"
+)
+
+func TestErrorCode(t *testing.T) {
+	cases := []struct {
+		err  error
+		code string
+		exit int
+	}{
+		{errors.New("boom"), "failure", exitFailure},
+		{usageError{errors.New("no product description provided")}, "usage", exitUsage},
+		{taxonomyError{fmt.Errorf("failed to load taxonomy: %w", context.DeadlineExceeded)}, "taxonomy", exitTaxonomy},
+		{classifier.ErrNoMatch, "no_match", exitNoMatch},
+		{&classifier.LevelError{Err: fmt.Errorf("%w: bad", classifier.ErrInvalidSelection)}, "invalid_response", exitInvalidResponse},
+		{fmt.Errorf("%w: missing key", llm.ErrAuth), "auth", exitAuth},
+		{&classifier.LevelError{Err: llm.ErrRateLimited}, "rate_limited", exitRateLimited},
+		{fmt.Errorf("timed out: %w", context.DeadlineExceeded), "timeout", exitTimeout},
+		{&llm.RefusalError{}, "refusal", exitRefusal},
+		{llm.ErrUnavailable, "unavailable", exitUnavailable},
+		{llm.ErrBadRequest, "bad_request", exitBadRequest},
+		{&classifier.LevelError{Err: &history.BudgetError{}}, "budget", exitBudget},
+	}
+	for _, tc := range cases {
+		code, exit := errorCode(tc.err)
+		if code != tc.code || exit != tc.exit {
+			t.Errorf("errorCode(%v) = %s, %d; want %s, %d", tc.err, code, exit, tc.code, tc.exit)
+		}
+	}
+}
+
+func TestBudgetIsSharedBetweenDatabaseHandles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.db")
+	limits := history.BudgetLimits{Tokens: 100, Window: time.Hour}
+	first, err := history.Open(path)
+	if err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+	defer first.Close()
+	second, err := history.Open(path)
+	if err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+	defer second.Close()
+
+	id, err := first.Budget(limits).Reserve(context.Background(), 80, 0)
+	if err != nil {
+		t.Fatalf("Reserve returned error: %v", err)
+	}
+	if _, err := second.Budget(limits).Reserve(context.Background(), 30, 0); !errors.Is(err, history.ErrBudgetExhausted) {
+		t.Fatalf("Reserve over the limit = %v, want ErrBudgetExhausted", err)
+	}
+	// Settling below the reservation frees budget for the other handle.
+	if err := first.Budget(limits).Settle(context.Background(), id, 50, 0); err != nil {
+		t.Fatalf("Settle returned error: %v", err)
+	}
+	if _, err := second.Budget(limits).Reserve(context.Background(), 30, 0); err != nil {
+		t.Fatalf("Reserve after settling returned error: %v", err)
+	}
+}
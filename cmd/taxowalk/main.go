@@ -3,12 +3,14 @@ package main
 import (
 	"bufio"
 	"context"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
@@ -16,6 +18,8 @@ import (
 	"taxowalk/internal/cmdutil"
 	"taxowalk/internal/history"
 	"taxowalk/internal/llm"
+	"taxowalk/internal/locale"
+	"taxowalk/internal/taxonomy"
 )
 
 var (
@@ -25,12 +29,15 @@ var (
 
 func main() {
 	if err := run(); err != nil {
-		fmt.Fprintln(os.Stderr, "taxowalk:", err)
-		os.Exit(1)
+		_, exit := errorCode(err)
+		if !errors.Is(err, classifier.ErrNoMatch) {
+			fmt.Fprintln(os.Stderr, "taxowalk:", err)
+		}
+		os.Exit(exit)
 	}
 }
 
-func run() error {
+func run() (err error) {
 	var (
 		useStdin     bool
 		apiKeyFlag   string
@@ -39,18 +46,109 @@ func run() error {
 		dbPath       string
 		showVersion  bool
 		showLeafName bool
+		showMapped   bool
 		timeout      time.Duration
+		imagePaths   cmdutil.StringList
+		imageLevels  int
+		imageMaxDim  int
+		outputLocale string
+		modelName    string
+		provider     string
+		anthropicKey string
+		anthropicURL string
+		ollamaURL    string
+		ollamaPull   bool
+		ollamaWarmup bool
+		routes       cmdutil.StringList
+		fallbacks    cmdutil.StringList
+		breaker      llm.BreakerConfig
+		tracePath    string
+		transport    string
+		cassettePath string
+		cassetteMode string
+		templatePath string
+		examplesPath string
+		jsonOutput   bool
+		failNoMatch  bool
+		genConfig    string
+		temperature  float64
+		topP         float64
+		seed         int
+		extraBody    string
+		generation   llm.GenerationSettings
+		azureKey     string
+		azureURL     string
+		azureVersion string
+		deployments  cmdutil.StringList
+		headers      cmdutil.StringList
+		queryParams  cmdutil.StringList
+		tlsConfig    llm.TLSConfig
+		retry        llm.RetryConfig
+		budget       history.BudgetLimits
+		prices       cmdutil.StringList
+		mapTo        string
+		mappingURL   string
 	)
 
 	flag.BoolVar(&useStdin, "stdin", false, "read the product description from standard input")
 	flag.StringVar(&apiKeyFlag, "openai-key", "", "OpenAI API key (overrides defaults)")
 	flag.StringVar(&baseURL, "openai-base-url", "", "override the OpenAI API base URL")
+	flag.StringVar(&transport, "openai-transport", string(llm.TransportAuto), "how OpenAI-compatible endpoints return the selection: auto, tool, json_schema or text")
+	flag.StringVar(&cassettePath, "llm-cassette", "", "record chat-completion exchanges to, or replay them from, this file")
+	flag.StringVar(&cassetteMode, "llm-cassette-mode", string(llm.CassetteReplay), "cassette mode: record, replay (record unmatched requests) or strict (fail on unmatched requests)")
+	flag.StringVar(&provider, "provider", providerOpenAI, "LLM provider: openai, azure, anthropic or ollama")
+	flag.StringVar(&anthropicKey, "anthropic-key", "", "Anthropic API key (overrides defaults)")
+	flag.StringVar(&anthropicURL, "anthropic-base-url", "", "override the Anthropic API base URL")
+	flag.StringVar(&azureKey, "azure-key", "", "Azure OpenAI API key (overrides defaults)")
+	flag.StringVar(&azureURL, "azure-endpoint", "", "Azure OpenAI resource endpoint (defaults to $AZURE_OPENAI_ENDPOINT)")
+	flag.StringVar(&azureVersion, "azure-api-version", llm.DefaultAzureAPIVersion, "Azure OpenAI api-version query parameter")
+	flag.Var(&deployments, "azure-deployment", "Azure deployment serving a model: MODEL=DEPLOYMENT (repeatable; defaults to the model name)")
+	flag.Var(&headers, "header", "extra request header for the primary endpoint: \"Name: value\" (repeatable; replaces the API key header of the same name)")
+	flag.Var(&queryParams, "query", "extra query parameter for the primary endpoint: name=value (repeatable)")
+	flag.StringVar(&tlsConfig.CertFile, "tls-cert", "", "PEM client certificate presented to every endpoint")
+	flag.StringVar(&tlsConfig.KeyFile, "tls-key", "", "PEM private key for --tls-cert")
+	flag.StringVar(&tlsConfig.CAFile, "ca-bundle", "", "PEM CA certificates trusted in addition to the system roots")
+	flag.StringVar(&ollamaURL, "ollama-url", "", "Ollama server URL (defaults to $OLLAMA_HOST or http://localhost:11434)")
+	flag.BoolVar(&ollamaPull, "ollama-pull", false, "pull the Ollama model if it is not available locally")
+	flag.BoolVar(&ollamaWarmup, "ollama-warmup", false, "check that the Ollama model is available and load it before classifying")
+	flag.StringVar(&modelName, "model", llm.DefaultOpenAIModel, "chat model used for classification (anthropic default: "+llm.DefaultAnthropicModel+", ollama default: "+llm.DefaultOllamaModel+")")
+	flag.StringVar(&genConfig, "generation-config", "", "JSON file of generation settings (temperature, top_p, max_completion_tokens, reasoning_effort, seed, service_tier, extra)")
+	flag.Float64Var(&temperature, "temperature", 0, "sampling temperature (dropped for models that reject it)")
+	flag.Float64Var(&topP, "top-p", 0, "nucleus sampling probability mass (dropped for models that reject it)")
+	flag.IntVar(&generation.MaxCompletionTokens, "max-completion-tokens", 0, "upper bound on generated tokens, including reasoning tokens")
+	flag.StringVar(&generation.ReasoningEffort, "reasoning-effort", "", "reasoning effort for reasoning models: none, minimal, low, medium, high or xhigh")
+	flag.IntVar(&seed, "seed", 0, "sampling seed for best-effort deterministic output")
+	flag.StringVar(&generation.ServiceTier, "service-tier", "", "OpenAI service tier: auto, default, flex, scale or priority")
+	flag.StringVar(&extraBody, "extra-body", "", "JSON object of extra fields merged into every request body")
+	flag.Var(&routes, "route", "route some prompts to another model: depth>=N:MODEL, options>=N:MODEL or retry:MODEL (repeatable)")
+	flag.Var(&fallbacks, "fallback", "fallback endpoint tried when earlier ones fail: url=URL[,name=NAME][,provider=P][,key-env=VAR][,model=MODEL] (repeatable)")
+	flag.IntVar(&breaker.Threshold, "breaker-threshold", llm.DefaultBreakerThreshold, "consecutive 429/5xx failures before a fallback endpoint is skipped")
+	flag.DurationVar(&breaker.Cooldown, "breaker-cooldown", llm.DefaultBreakerCooldown, "how long a tripped endpoint is skipped before it is probed again")
+	flag.IntVar(&retry.MaxAttempts, "retry-attempts", 3, "requests made per prompt before a transient failure (429/5xx) is reported, including the first")
+	flag.DurationVar(&retry.BaseDelay, "retry-base-delay", time.Second, "backoff cap before the first retry; it doubles per retry and the actual delay is jittered below it")
+	flag.DurationVar(&retry.MaxDelay, "retry-max-delay", 30*time.Second, "longest backoff or Retry-After wait; longer Retry-After values end the retries")
+	flag.StringVar(&tracePath, "trace", "", "write a JSON trace of each taxonomy level to this file (- for standard error)")
 	flag.StringVar(&dbPath, "history-db", "", "SQLite database path to track token usage history")
+	flag.Int64Var(&budget.Tokens, "budget-tokens", 0, "rolling token budget shared by every process using --history-db (0 is unlimited)")
+	flag.Float64Var(&budget.CostUSD, "budget-usd", 0, "rolling USD budget shared by every process using --history-db, priced with --price (0 is unlimited)")
+	flag.DurationVar(&budget.Window, "budget-window", 24*time.Hour, "rolling window of --budget-tokens and --budget-usd")
+	flag.Var(&prices, "price", "model price in USD per million tokens: MODEL=INPUT:OUTPUT[:CACHED_INPUT] (repeatable)")
 	flag.BoolVar(&debugEnabled, "debug", false, "enable verbose debug logging to standard error")
 	flag.DurationVar(&timeout, "timeout", 5*time.Minute, "overall timeout for taxonomy fetch + classification (e.g. 2m, 30s)")
-	flag.BoolVar(&showVersion, "version", false, "print the taxowalk version and exit")
+	flag.BoolVar(&showVersion, "version", false, "print the taxowalk version and the embedded taxonomy version, then exit")
 	flag.BoolVar(&showPath, "show-path", false, "print the full taxonomy path before the category ID")
 	flag.BoolVar(&showLeafName, "show-leaf-name", false, "print the final taxonomy name after classification")
+	flag.BoolVar(&showMapped, "show-mapped", false, "print the comma-separated --map-to category IDs on a line after the category ID")
+	flag.Var(&imagePaths, "image", "product image file path or URL to send with the description (repeatable)")
+	flag.IntVar(&imageLevels, "image-levels", 0, "only send images for the first N taxonomy levels (0 sends them at every level)")
+	flag.IntVar(&imageMaxDim, "image-max-dimension", llm.DefaultImageMaxDimension, "downscale local images so their longest side is at most this many pixels")
+	flag.StringVar(&templatePath, "prompt-template", "", "text/template file defining the \"system\" and \"user\" prompts (defaults to the built-in template)")
+	flag.StringVar(&examplesPath, "prompt-examples", "", "JSON file of {\"description\", \"category\"} examples made available to the prompt template")
+	flag.BoolVar(&jsonOutput, "json", false, "print the result as a JSON object including the prompt version and token usage")
+	flag.BoolVar(&failNoMatch, "fail-on-no-match", false, "exit with status 3 (no_match) instead of 0 when no category is found")
+	flag.StringVar(&outputLocale, "output-locale", "", "locale for printed category names (defaults to the classification locale)")
+	flag.StringVar(&mapTo, "map-to", "", "report the category of another taxonomy the result maps to in --json output or with --show-mapped, e.g. google or shopify/2025-01")
+	flag.StringVar(&mappingURL, "mapping-url", cmdutil.DefaultMappingURL, "URL or file path for the Shopify mapping file used by --map-to")
 	taxFlags := cmdutil.NewTaxonomyFlags()
 	taxFlags.Register(flag.CommandLine)
 	flag.Usage = func() {
@@ -60,21 +158,74 @@ func run() error {
 		flag.PrintDefaults()
 	}
 	flag.Parse()
+	jsonWritten := false
+	defer func() {
+		if err != nil && jsonOutput && !jsonWritten {
+			if writeErr := writeJSONResult(jsonResult{}, nil, err); writeErr != nil {
+				fmt.Fprintf(os.Stderr, "Warning: failed to write JSON result: %v\n", writeErr)
+			}
+		}
+	}()
 
 	if showVersion {
-		fmt.Printf("taxowalk %s\n", cmdutil.ResolveVersion(version))
+		fmt.Println(cmdutil.VersionLine("taxowalk", version))
 		return nil
 	}
 
 	debugf("Arguments: %s", strings.Join(os.Args[1:], " "))
 
+	if showMapped && mapTo == "" {
+		return usageError{errors.New("--show-mapped needs --map-to")}
+	}
+
 	description, err := loadDescription(useStdin, flag.Args())
 	if err != nil {
-		return err
+		return usageError{err}
 	}
 
 	debugf("Product description (%d chars)", len(description))
 
+	images := make([]llm.Image, 0, len(imagePaths))
+	for _, source := range imagePaths {
+		img, err := llm.LoadImage(source, llm.ImageOptions{MaxDimension: imageMaxDim})
+		if err != nil {
+			return usageError{fmt.Errorf("failed to load image: %w", err)}
+		}
+		images = append(images, img)
+		debugf("Loaded image %s", source)
+	}
+
+	promptTemplate := llm.DefaultPromptTemplate()
+	if templatePath != "" {
+		if promptTemplate, err = llm.LoadPromptTemplate(templatePath); err != nil {
+			return usageError{err}
+		}
+	}
+	debugf("Using prompt template %s (version %s)", promptTemplate.Name(), promptTemplate.Version())
+	var examples []llm.Example
+	if examplesPath != "" {
+		if examples, err = llm.LoadExamples(examplesPath); err != nil {
+			return usageError{err}
+		}
+		debugf("Loaded %d prompt example(s)", len(examples))
+	}
+
+	budgeted := budget.Tokens > 0 || budget.CostUSD > 0
+	pricing := make(llm.Pricing, len(prices))
+	for _, spec := range prices {
+		name, price, err := llm.ParsePrice(spec)
+		if err != nil {
+			return usageError{err}
+		}
+		pricing[name] = price
+	}
+	if budgeted && dbPath == "" {
+		return usageError{errors.New("--budget-tokens and --budget-usd need --history-db")}
+	}
+	if budget.CostUSD > 0 && len(pricing) == 0 {
+		return usageError{errors.New("--budget-usd needs at least one --price")}
+	}
+
 	ctx := context.Background()
 	cancel := func() {}
 	if timeout > 0 {
@@ -82,43 +233,177 @@ func run() error {
 	}
 	defer cancel()
 
+	if locale.Normalize(taxFlags.Locale) == locale.Auto {
+		taxFlags.Locale = locale.Detect(description)
+		debugf("Detected description locale: %s", taxFlags.Locale)
+	}
+
 	start := time.Now()
-	debugf("Fetching taxonomy from %s", taxFlags.URL)
+	debugf("Fetching taxonomy from %s", taxFlags.Source(taxFlags.Locale))
 	tax, err := taxFlags.Fetch(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to load taxonomy: %w", err)
+		return taxonomyError{fmt.Errorf("failed to load taxonomy: %w", err)}
 	}
-	debugf("Fetched taxonomy in %s (%d root categories)", time.Since(start), len(tax.Roots))
+	debugf("Fetched taxonomy in %s (%d root categories, locale %s)", time.Since(start), len(tax.Roots), tax.Locale)
 
-	apiKey, err := resolveAPIKey(apiKeyFlag)
+	// Load the mapping before classifying so that a bad --map-to does not
+	// cost any tokens.
+	var mapping *taxonomy.Mapping
+	if mapTo != "" {
+		mapping, err = loadMapping(ctx, taxFlags, mappingURL, tax, mapTo)
+		if err != nil {
+			return taxonomyError{err}
+		}
+		debugf("Mapping results from %s to %s", mapping.Input, mapping.Output)
+	}
+
+	provider = strings.ToLower(strings.TrimSpace(provider))
+	keyFlag := apiKeyFlag
+	switch provider {
+	case providerOpenAI:
+	case providerAzure:
+		keyFlag = azureKey
+		baseURL = azureURL
+		if baseURL == "" {
+			baseURL = strings.TrimSpace(os.Getenv("AZURE_OPENAI_ENDPOINT"))
+		}
+		if baseURL == "" {
+			return usageError{errors.New("the azure provider needs --azure-endpoint or AZURE_OPENAI_ENDPOINT")}
+		}
+	case providerAnthropic:
+		keyFlag = anthropicKey
+		baseURL = anthropicURL
+	case providerOllama:
+		baseURL = ollamaURL
+	default:
+		return usageError{fmt.Errorf("unknown provider %q (want openai, azure, anthropic or ollama)", provider)}
+	}
+	if flagWasSet("temperature") {
+		generation.Temperature = &temperature
+	}
+	if flagWasSet("top-p") {
+		generation.TopP = &topP
+	}
+	if flagWasSet("seed") {
+		generation.Seed = &seed
+	}
+	if extraBody != "" {
+		if err := json.Unmarshal([]byte(extraBody), &generation.Extra); err != nil {
+			return usageError{fmt.Errorf("invalid --extra-body: %w", err)}
+		}
+	}
+	generation, err = loadGenerationSettings(genConfig, generation)
 	if err != nil {
-		return err
+		return usageError{err}
 	}
-	debugf("Resolved API key")
 
-	var opts []llm.OptionFunc
-	if baseURL != "" {
-		opts = append(opts, llm.WithBaseURL(baseURL))
-		debugf("Using custom OpenAI base URL: %s", baseURL)
+	selectionTransport, err := llm.ParseTransport(transport)
+	if err != nil {
+		return usageError{err}
+	}
+	if !flagWasSet("model") {
+		modelName = defaultModelName(provider)
+	}
+	var cassette *llm.Cassette
+	if cassettePath != "" {
+		if provider != providerOpenAI && provider != providerAzure {
+			return usageError{fmt.Errorf("--llm-cassette only supports the %s and %s providers", providerOpenAI, providerAzure)}
+		}
+		mode, err := llm.ParseCassetteMode(cassetteMode)
+		if err != nil {
+			return usageError{err}
+		}
+		if cassette, err = llm.OpenCassette(cassettePath, mode); err != nil {
+			return usageError{err}
+		}
+		debugf("Using %s cassette %s", mode, cassettePath)
+	}
+	gateway, err := gatewayOptions(headers, queryParams)
+	if err != nil {
+		return usageError{err}
 	}
-	model, err := llm.NewOpenAIModel(apiKey, opts...)
+	azureDeployments, err := parseDeployments(deployments)
+	if err != nil {
+		return usageError{err}
+	}
+	var apiKey string
+	switch {
+	case cassette != nil && cassette.Mode() == llm.CassetteStrict && strings.TrimSpace(apiKeyFlag) == "":
+		// Strict replay never reaches the endpoint, so no real key is needed.
+		apiKey = "cassette"
+	case provider != providerOllama:
+		apiKey, err = resolveAPIKey(provider, keyFlag)
+		if err != nil && len(headers) > 0 {
+			// A gateway authenticated through --header may need no key.
+			debugf("No API key found; relying on --header authentication")
+			apiKey, err = "", nil
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %w", llm.ErrAuth, err)
+		}
+		debugf("Resolved API key")
+	}
+
+	var db *history.DB
+	if budgeted {
+		// The budget is enforced, so an unusable database is an error
+		// rather than the warning given when only recording history.
+		if db, err = history.Open(dbPath); err != nil {
+			return err
+		}
+		defer db.Close()
+	}
+
+	model, err := newModel(ctx, modelConfig{
+		provider:     provider,
+		ollamaPull:   ollamaPull,
+		ollamaWarmup: ollamaWarmup,
+		transport:    selectionTransport,
+		cassette:     cassette,
+		template:     promptTemplate,
+		generation:   generation,
+		azure:        llm.AzureConfig{APIVersion: azureVersion, Deployments: azureDeployments},
+		gateway:      gateway,
+		tls:          tlsConfig,
+		retry:        retry,
+		apiKey:       apiKey,
+		baseURL:      baseURL,
+		name:         modelName,
+		routes:       routes,
+		fallbacks:    fallbacks,
+		breaker:      breaker,
+	})
 	if err != nil {
 		return err
 	}
-	debugf("Initialised OpenAI model")
+	debugf("Initialised %s model %s with %d route(s)", provider, modelName, len(routes))
+	if budgeted {
+		if model, err = llm.NewBudgetModel(model, db.Budget(budget), pricing); err != nil {
+			return err
+		}
+		debugf("Enforcing budget of %d tokens and $%.2f per %s", budget.Tokens, budget.CostUSD, budget.Window)
+	}
 
 	clf, err := classifier.New(model, tax)
 	if err != nil {
 		return err
 	}
 
+	clf.SetImageLevels(imageLevels)
+	clf.SetExamples(examples)
+
 	if debugEnabled {
 		clf.SetDebugLogger(func(format string, args ...interface{}) {
 			debugf("classifier: "+format, args...)
 		})
 	}
 
-	node, err := clf.Classify(ctx, description)
+	node, err := clf.ClassifyWithImages(ctx, description, images)
+	if tracePath != "" {
+		if traceErr := writeTrace(tracePath, clf.Trace()); traceErr != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to write trace: %v\n", traceErr)
+		}
+	}
 	if err != nil {
 		if timeout > 0 && errors.Is(err, context.DeadlineExceeded) {
 			return fmt.Errorf("timed out after %s (try increasing --timeout): %w", timeout, err)
@@ -126,24 +411,72 @@ func run() error {
 		return err
 	}
 
+	// Sources without a dist/<locale>/ segment have only one language.
+	if node != nil && outputLocale != "" && tax.Locale != "" && locale.Normalize(outputLocale) != tax.Locale {
+		debugf("Fetching %s taxonomy for output names", locale.Normalize(outputLocale))
+		outTax, err := taxFlags.FetchLocale(ctx, outputLocale)
+		if err != nil {
+			return taxonomyError{fmt.Errorf("failed to load output taxonomy: %w", err)}
+		}
+		if translated := outTax.FindByID(node.ID); translated != nil {
+			node = translated
+		} else {
+			fmt.Fprintf(os.Stderr, "Warning: category %s not found in %s taxonomy; using %s names\n", node.ID, outTax.Locale, tax.Locale)
+		}
+	}
+
 	usage := clf.Usage()
-	debugf("Token usage - prompt: %d, completion: %d, total: %d", usage.PromptTokens, usage.CompletionTokens, usage.TotalTokens)
+	debugf("Token usage - prompt: %d (cached: %d), completion: %d (reasoning: %d), total: %d", usage.PromptTokens, usage.CachedPromptTokens, usage.CompletionTokens, usage.ReasoningTokens, usage.TotalTokens)
+	usageByModel := clf.UsageByModel()
+	modelNames := make([]string, 0, len(usageByModel))
+	for name := range usageByModel {
+		modelNames = append(modelNames, name)
+	}
+	sort.Strings(modelNames)
+	modelUsage := make([]history.ModelUsage, 0, len(modelNames))
+	for _, name := range modelNames {
+		u := usageByModel[name]
+		debugf("Token usage for %s - prompt: %d, completion: %d, total: %d", name, u.PromptTokens, u.CompletionTokens, u.TotalTokens)
+		modelUsage = append(modelUsage, history.ModelUsage{
+			Model:              name,
+			PromptTokens:       u.PromptTokens,
+			CompletionTokens:   u.CompletionTokens,
+			TotalTokens:        u.TotalTokens,
+			CachedPromptTokens: u.CachedPromptTokens,
+			ReasoningTokens:    u.ReasoningTokens,
+		})
+	}
 
 	if dbPath != "" {
 		debugf("Recording classification history in %s", dbPath)
-		db, err := history.Open(dbPath)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: failed to open history database: %v\n", err)
-		} else {
-			defer db.Close()
+		if db == nil {
+			if opened, err := history.Open(dbPath); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: failed to open history database: %v\n", err)
+			} else {
+				db = opened
+				defer db.Close()
+			}
+		}
+		if db != nil {
 			categoryName := ""
 			categoryID := ""
 			if node != nil {
 				categoryName = node.FullName
 				categoryID = node.ID
 			}
-			if err := db.RecordClassification(description, categoryName, categoryID,
-				usage.PromptTokens, usage.CompletionTokens, usage.TotalTokens); err != nil {
+			if err := db.Record(history.ClassificationRecord{
+				ProductDesc:        description,
+				Category:           categoryName,
+				CategoryID:         categoryID,
+				PromptTokens:       usage.PromptTokens,
+				CompletionTokens:   usage.CompletionTokens,
+				TotalTokens:        usage.TotalTokens,
+				CachedPromptTokens: usage.CachedPromptTokens,
+				ReasoningTokens:    usage.ReasoningTokens,
+				PromptVersion:      promptTemplate.Version(),
+				OverlayHash:        tax.Overlay,
+				Models:             modelUsage,
+			}); err != nil {
 				fmt.Fprintf(os.Stderr, "Warning: failed to record classification: %v\n", err)
 			} else {
 				debugf("Classification history recorded")
@@ -151,10 +484,39 @@ func run() error {
 		}
 	}
 
+	var result error
 	if node == nil {
 		debugf("Classifier returned nil node")
+		if failNoMatch {
+			result = classifier.ErrNoMatch
+		}
+	}
+	var mapped *jsonMapped
+	if mapping != nil && node != nil {
+		mapped = &jsonMapped{Taxonomy: mapping.Output, Categories: mapping.Map(node.ID)}
+		if len(mapped.Categories) == 0 {
+			mapped.Categories = []taxonomy.MappedCategory{}
+			fmt.Fprintf(os.Stderr, "Warning: category %s has no mapping to %s\n", node.ID, mapping.Output)
+		}
+	}
+	if jsonOutput {
+		jsonWritten = true
+		out := jsonResult{
+			PromptVersion: promptTemplate.Version(),
+			Overlay:       tax.Overlay,
+			Usage:         usage,
+			UsageByModel:  usageByModel,
+			Mapped:        mapped,
+		}
+		if err := writeJSONResult(out, node, result); err != nil {
+			return err
+		}
+		return result
+	}
+
+	if node == nil {
 		fmt.Println("No matching Shopify category found.")
-		return nil
+		return result
 	}
 
 	debugf("Classification result: %s (%s)", node.FullName, node.ID)
@@ -162,12 +524,109 @@ func run() error {
 		fmt.Println(node.FullName)
 	}
 	fmt.Println(node.ID)
+	if showMapped {
+		ids := make([]string, len(mapped.Categories))
+		for i, c := range mapped.Categories {
+			ids[i] = c.ID
+		}
+		fmt.Println(strings.Join(ids, ","))
+	}
 	if showLeafName {
 		fmt.Println(node.Name)
 	}
 	return nil
 }
 
+// jsonResult is the --json output.
+type jsonResult struct {
+	Matched       bool                 `json:"matched"`
+	ID            string               `json:"id,omitempty"`
+	Name          string               `json:"name,omitempty"`
+	FullName      string               `json:"full_name,omitempty"`
+	PromptVersion string               `json:"prompt_version"`
+	Overlay       string               `json:"overlay,omitempty"`
+	Usage         llm.Usage            `json:"usage"`
+	UsageByModel  map[string]llm.Usage `json:"usage_by_model,omitempty"`
+	Mapped        *jsonMapped          `json:"mapped,omitempty"`
+	Error         *jsonError           `json:"error,omitempty"`
+}
+
+// jsonMapped holds the categories of another taxonomy that the result maps
+// to, for --map-to.
+type jsonMapped struct {
+	Taxonomy   string                    `json:"taxonomy"`
+	Categories []taxonomy.MappedCategory `json:"categories"`
+}
+
+// jsonError describes a failed run; Code is one of the codes listed under
+// EXIT STATUS in docs/taxowalk.1.
+type jsonError struct {
+	Code     string `json:"code"`
+	ExitCode int    `json:"exit_code"`
+	Message  string `json:"message"`
+}
+
+// writeJSONResult completes out with the matched node and the failure, if
+// any, and prints it.
+func writeJSONResult(out jsonResult, node *taxonomy.Node, failure error) error {
+	if failure != nil {
+		code, exit := errorCode(failure)
+		out.Error = &jsonError{Code: code, ExitCode: exit, Message: failure.Error()}
+	}
+	if node != nil {
+		out.Matched = true
+		out.ID = node.ID
+		out.Name = node.Name
+		out.FullName = node.FullName
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+// loadMapping loads the mapping from tax to the taxonomy named to,
+// preferring one whose input is the version of tax. Another version's
+// mapping is used with a warning, since category IDs may differ between
+// releases.
+func loadMapping(ctx context.Context, taxFlags cmdutil.TaxonomyFlags, source string, tax *taxonomy.Taxonomy, to string) (*taxonomy.Mapping, error) {
+	mappings, err := taxonomy.FetchMappings(ctx, cmdutil.LocaleURL(source, tax.Locale), taxFlags.FetchOptions()...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load mappings: %w", err)
+	}
+	from := tax.Format
+	if from == "" {
+		from = taxonomy.FormatShopify
+	}
+	if tax.Version != "" {
+		if m, err := mappings.Find(from+"/"+tax.Version, to); err == nil {
+			return m, nil
+		}
+	}
+	m, err := mappings.Find(from, to)
+	if err != nil {
+		return nil, err
+	}
+	version := tax.Version
+	if version == "" {
+		version = "unversioned"
+	}
+	fmt.Fprintf(os.Stderr, "Warning: no mapping from %s/%s to %s; using %s -> %s, whose category IDs may differ from this taxonomy's\n", from, version, to, m.Input, m.Output)
+	return m, nil
+}
+
+func writeTrace(path string, steps []classifier.Step) error {
+	data, err := json.MarshalIndent(steps, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if path == "-" {
+		_, err = os.Stderr.Write(data)
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
 func loadDescription(useStdin bool, args []string) (string, error) {
 	if useStdin {
 		debugf("Reading product description from standard input")
@@ -191,20 +650,27 @@ func loadDescription(useStdin bool, args []string) (string, error) {
 	return strings.TrimSpace(strings.Join(args, " ")), nil
 }
 
-func resolveAPIKey(explicit string) (string, error) {
+func resolveAPIKey(provider, explicit string) (string, error) {
+	flagName, envName, fileName := "--openai-key", "OPENAI_API_KEY", ".openai.key"
+	switch provider {
+	case providerAnthropic:
+		flagName, envName, fileName = "--anthropic-key", "ANTHROPIC_API_KEY", ".anthropic.key"
+	case providerAzure:
+		flagName, envName, fileName = "--azure-key", "AZURE_OPENAI_API_KEY", ".azure-openai.key"
+	}
 	if strings.TrimSpace(explicit) != "" {
-		debugf("Using API key provided via --openai-key flag")
+		debugf("Using API key provided via %s flag", flagName)
 		return strings.TrimSpace(explicit), nil
 	}
-	if key := strings.TrimSpace(os.Getenv("OPENAI_API_KEY")); key != "" {
-		debugf("Using API key from OPENAI_API_KEY environment variable")
+	if key := strings.TrimSpace(os.Getenv(envName)); key != "" {
+		debugf("Using API key from %s environment variable", envName)
 		return key, nil
 	}
 	home, err := os.UserHomeDir()
 	if err != nil {
 		return "", fmt.Errorf("unable to determine home directory: %w", err)
 	}
-	path := filepath.Join(home, ".openai.key")
+	path := filepath.Join(home, fileName)
 	debugf("Reading API key from %s", path)
 	data, err := os.ReadFile(path)
 	if err != nil {
@@ -217,6 +683,16 @@ func resolveAPIKey(explicit string) (string, error) {
 	return key, nil
 }
 
+func flagWasSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
 func debugf(format string, args ...interface{}) {
 	if !debugEnabled {
 		return
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"taxowalk/internal/llm"
+)
+
+const (
+	providerOpenAI    = "openai"
+	providerAnthropic = "anthropic"
+	providerOllama    = "ollama"
+	providerAzure     = "azure"
+)
+
+// backendSpec is a parsed --fallback value.
+type backendSpec struct {
+	Name     string
+	Provider string
+	URL      string
+	KeyEnv   string
+	Model    string
+}
+
+// parseBackendSpec parses comma-separated key=value pairs such as
+// "name=azure,url=https://gw.example.com/v1,key-env=GW_KEY,model=gpt-5.4-mini".
+// The url field may be omitted for non-OpenAI providers, which then use
+// their default endpoint.
+func parseBackendSpec(raw string) (backendSpec, error) {
+	var spec backendSpec
+	for _, field := range strings.Split(raw, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			return backendSpec{}, fmt.Errorf("fallback %q: field %q is not key=value", raw, field)
+		}
+		value = strings.TrimSpace(value)
+		switch strings.TrimSpace(key) {
+		case "name":
+			spec.Name = value
+		case "provider":
+			spec.Provider = strings.ToLower(value)
+		case "url":
+			spec.URL = value
+		case "key-env":
+			spec.KeyEnv = value
+		case "model":
+			spec.Model = value
+		default:
+			return backendSpec{}, fmt.Errorf("fallback %q: unknown field %q", raw, key)
+		}
+	}
+	switch spec.Provider {
+	case "":
+		spec.Provider = providerOpenAI
+	case providerOpenAI, providerAnthropic, providerOllama, providerAzure:
+	default:
+		return backendSpec{}, fmt.Errorf("fallback %q: unknown provider %q", raw, spec.Provider)
+	}
+	if spec.URL == "" && (spec.Provider == providerOpenAI || spec.Provider == providerAzure) {
+		return backendSpec{}, fmt.Errorf("fallback %q: url is required", raw)
+	}
+	if spec.Name == "" {
+		spec.Name = spec.URL
+		if spec.Name == "" {
+			spec.Name = spec.Provider
+		}
+	}
+	return spec, nil
+}
+
+// modelConfig gathers the command-line settings that shape the model chain.
+type modelConfig struct {
+	provider     string
+	ollamaPull   bool
+	ollamaWarmup bool
+	transport    llm.Transport
+	cassette     *llm.Cassette
+	template     *llm.PromptTemplate
+	generation   llm.GenerationSettings
+	azure        llm.AzureConfig
+	gateway      []llm.OptionFunc
+	tls          llm.TLSConfig
+	retry        llm.RetryConfig
+	apiKey       string
+	baseURL      string
+	name         string
+	routes       []string
+	fallbacks    []string
+	breaker      llm.BreakerConfig
+}
+
+// newModel builds the primary model and, when fallbacks are configured, a
+// fallback chain with one routed model per backend.
+func newModel(ctx context.Context, cfg modelConfig) (llm.Model, error) {
+	var opts []llm.OptionFunc
+	if cfg.baseURL != "" {
+		opts = append(opts, llm.WithBaseURL(cfg.baseURL))
+		debugf("Using custom %s base URL: %s", cfg.provider, cfg.baseURL)
+	}
+	opts = append(opts, cfg.gateway...)
+	primary, err := newRoutedModel(ctx, cfg, cfg.provider, cfg.apiKey, opts, cfg.name)
+	if err != nil {
+		return nil, err
+	}
+	if len(cfg.fallbacks) == 0 {
+		return primary, nil
+	}
+
+	primaryName := cfg.provider
+	if cfg.baseURL != "" {
+		primaryName = cfg.baseURL
+	}
+	backends := []llm.Backend{{Name: primaryName, Model: primary}}
+	for _, raw := range cfg.fallbacks {
+		spec, err := parseBackendSpec(raw)
+		if err != nil {
+			return nil, err
+		}
+		key := cfg.apiKey
+		switch {
+		case spec.KeyEnv != "":
+			key = strings.TrimSpace(os.Getenv(spec.KeyEnv))
+			if key == "" {
+				return nil, fmt.Errorf("fallback %s: environment variable %s is empty", spec.Name, spec.KeyEnv)
+			}
+		case spec.Provider != cfg.provider && spec.Provider != providerOllama:
+			if key, err = resolveAPIKey(spec.Provider, ""); err != nil {
+				return nil, fmt.Errorf("fallback %s: %w", spec.Name, err)
+			}
+		}
+		name := cfg.name
+		if spec.Provider != cfg.provider {
+			name = defaultModelName(spec.Provider)
+		}
+		if spec.Model != "" {
+			name = spec.Model
+		}
+		m, err := newRoutedModel(ctx, cfg, spec.Provider, key, []llm.OptionFunc{llm.WithBaseURL(spec.URL)}, name)
+		if err != nil {
+			return nil, err
+		}
+		backends = append(backends, llm.Backend{Name: spec.Name, Model: m})
+		debugf("Added fallback backend %s (%s, model %s)", spec.Name, spec.URL, name)
+	}
+	return llm.NewFallbackModel(backends, cfg.breaker)
+}
+
+func defaultModelName(provider string) string {
+	switch provider {
+	case providerAnthropic:
+		return llm.DefaultAnthropicModel
+	case providerOllama:
+		return llm.DefaultOllamaModel
+	default:
+		return llm.DefaultOpenAIModel
+	}
+}
+
+// newProviderModel constructs a single model for the named provider. With
+// --ollama-pull or --ollama-warmup, Ollama models are checked, optionally
+// pulled, and warmed up before use; in a fallback chain a failure to do so
+// is only a warning, and the circuit breaker takes the backend out of use.
+func newProviderModel(ctx context.Context, cfg modelConfig, provider, apiKey string, opts []llm.OptionFunc) (llm.Model, error) {
+	opts = append(opts, llm.WithPromptTemplate(cfg.template), llm.WithGeneration(cfg.generation), llm.WithTLS(cfg.tls), llm.WithRetry(cfg.retry))
+	var (
+		m   llm.Model
+		err error
+	)
+	switch provider {
+	case providerAnthropic:
+		m, err = llm.NewAnthropicModel(apiKey, opts...)
+	case providerOllama:
+		var om *llm.OllamaModel
+		if om, err = llm.NewOllamaModel(opts...); err == nil && (cfg.ollamaPull || cfg.ollamaWarmup) {
+			debugf("Preparing Ollama model %s", om.Name())
+			if err = om.Prepare(ctx, cfg.ollamaPull); err != nil && len(cfg.fallbacks) > 0 {
+				fmt.Fprintf(os.Stderr, "Warning: %v; trying the fallback endpoints if it fails\n", err)
+				err = nil
+			}
+		}
+		m = om
+	case providerAzure:
+		m, err = llm.NewOpenAIModel(apiKey, append(opts, llm.WithAzure(cfg.azure), llm.WithTransport(cfg.transport), llm.WithCassette(cfg.cassette))...)
+	default:
+		m, err = llm.NewOpenAIModel(apiKey, append(opts, llm.WithTransport(cfg.transport), llm.WithCassette(cfg.cassette))...)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if d, ok := m.(interface{ DroppedSettings() []string }); ok && len(d.DroppedSettings()) > 0 {
+		fmt.Fprintf(os.Stderr, "Warning: %s %s does not accept %s; leaving it out of requests\n", provider, modelDisplayName(m), strings.Join(d.DroppedSettings(), ", "))
+	}
+	return m, nil
+}
+
+// gatewayOptions turns --header "Name: value" and --query name=value flags
+// into model options.
+func gatewayOptions(headers, query []string) ([]llm.OptionFunc, error) {
+	var opts []llm.OptionFunc
+	for _, raw := range headers {
+		name, value, err := llm.ParseHeader(raw)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, llm.WithHeader(name, value))
+	}
+	for _, raw := range query {
+		name, value, err := llm.ParseQueryParam(raw)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, llm.WithQueryParam(name, value))
+	}
+	return opts, nil
+}
+
+// parseDeployments parses --azure-deployment MODEL=DEPLOYMENT flags.
+func parseDeployments(raw []string) (map[string]string, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	deployments := make(map[string]string, len(raw))
+	for _, entry := range raw {
+		model, deployment, ok := strings.Cut(entry, "=")
+		model, deployment = strings.TrimSpace(model), strings.TrimSpace(deployment)
+		if !ok || model == "" || deployment == "" {
+			return nil, fmt.Errorf("invalid --azure-deployment %q (want MODEL=DEPLOYMENT)", entry)
+		}
+		deployments[model] = deployment
+	}
+	return deployments, nil
+}
+
+func modelDisplayName(m llm.Model) string {
+	if n, ok := m.(interface{ Name() string }); ok {
+		return n.Name()
+	}
+	return "model"
+}
+
+// loadGenerationSettings reads the generation config file at path, if any,
+// and lets the settings given on the command line override it.
+func loadGenerationSettings(path string, flags llm.GenerationSettings) (llm.GenerationSettings, error) {
+	var settings llm.GenerationSettings
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return llm.GenerationSettings{}, fmt.Errorf("failed to read generation config: %w", err)
+		}
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&settings); err != nil {
+			return llm.GenerationSettings{}, fmt.Errorf("failed to parse generation config %s: %w", path, err)
+		}
+	}
+	settings = settings.Merge(flags)
+	if err := settings.Validate(); err != nil {
+		return llm.GenerationSettings{}, err
+	}
+	return settings, nil
+}
+
+// newRoutedModel builds the provider model for name and, when routes are
+// given, wraps it in a routing model that shares one client per distinct
+// model name.
+func newRoutedModel(ctx context.Context, cfg modelConfig, provider, apiKey string, opts []llm.OptionFunc, name string) (llm.Model, error) {
+	routes := cfg.routes
+	models := make(map[string]llm.Model)
+	get := func(name string) (llm.Model, error) {
+		if m, ok := models[name]; ok {
+			return m, nil
+		}
+		m, err := newProviderModel(ctx, cfg, provider, apiKey, append(append([]llm.OptionFunc{}, opts...), llm.WithModel(name)))
+		if err != nil {
+			return nil, err
+		}
+		models[name] = m
+		return m, nil
+	}
+
+	base, err := get(name)
+	if err != nil {
+		return nil, err
+	}
+	if len(routes) == 0 {
+		return base, nil
+	}
+
+	var rules []llm.RoutingRule
+	var escalate llm.Model
+	for _, raw := range routes {
+		spec, err := llm.ParseRouteSpec(raw)
+		if err != nil {
+			return nil, err
+		}
+		m, err := get(spec.Model)
+		if err != nil {
+			return nil, err
+		}
+		if spec.Escalate {
+			escalate = m
+			debugf("Escalating low-confidence answers to %s", spec.Model)
+			continue
+		}
+		rules = append(rules, llm.RoutingRule{MinDepth: spec.MinDepth, MinOptions: spec.MinOptions, Model: m})
+		debugf("Routing prompts with depth>=%d and options>=%d to %s", spec.MinDepth, spec.MinOptions, spec.Model)
+	}
+	return llm.NewRoutingModel(base, rules, escalate)
+}
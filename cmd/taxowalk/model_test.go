@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"taxowalk/internal/llm"
+)
+
+func TestParseBackendSpec(t *testing.T) {
+	spec, err := parseBackendSpec("name=azure, url=https://gw.example.com/v1, key-env=GW_KEY, model=gpt-5.4")
+	if err != nil {
+		t.Fatalf("parseBackendSpec returned error: %v", err)
+	}
+	want := backendSpec{Name: "azure", Provider: providerOpenAI, URL: "https://gw.example.com/v1", KeyEnv: "GW_KEY", Model: "gpt-5.4"}
+	if spec != want {
+		t.Fatalf("parseBackendSpec = %#v, want %#v", spec, want)
+	}
+
+	spec, err = parseBackendSpec("url=https://gw.example.com/v1")
+	if err != nil {
+		t.Fatalf("parseBackendSpec returned error: %v", err)
+	}
+	if spec.Name != "https://gw.example.com/v1" {
+		t.Fatalf("default name = %q", spec.Name)
+	}
+
+	spec, err = parseBackendSpec("provider=anthropic")
+	if err != nil {
+		t.Fatalf("parseBackendSpec returned error: %v", err)
+	}
+	if spec.Provider != providerAnthropic || spec.Name != providerAnthropic || spec.URL != "" {
+		t.Fatalf("unexpected anthropic spec: %#v", spec)
+	}
+
+	spec, err = parseBackendSpec("provider=azure,url=https://acme.openai.azure.com")
+	if err != nil {
+		t.Fatalf("parseBackendSpec returned error: %v", err)
+	}
+	if spec.Provider != providerAzure {
+		t.Fatalf("unexpected azure spec: %#v", spec)
+	}
+
+	for _, bad := range []string{"", "name=x", "url", "url=x,colour=blue", "provider=acme,url=x", "provider=azure"} {
+		if _, err := parseBackendSpec(bad); err == nil {
+			t.Fatalf("parseBackendSpec(%q) expected error", bad)
+		}
+	}
+}
+
+func TestLoadGenerationSettings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "generation.json")
+	if err := os.WriteFile(path, []byte(`{"temperature": 0.2, "reasoning_effort": "high", "extra": {"user": "a"}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	settings, err := loadGenerationSettings(path, llm.GenerationSettings{ReasoningEffort: "low", Extra: map[string]any{"metadata": "b"}})
+	if err != nil {
+		t.Fatalf("loadGenerationSettings returned error: %v", err)
+	}
+	if settings.Temperature == nil || *settings.Temperature != 0.2 || settings.ReasoningEffort != "low" {
+		t.Fatalf("unexpected settings: %#v", settings)
+	}
+	if settings.Extra["user"] != "a" || settings.Extra["metadata"] != "b" {
+		t.Fatalf("extra fields = %#v", settings.Extra)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"temprature": 0.2}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadGenerationSettings(path, llm.GenerationSettings{}); err == nil {
+		t.Fatal("expected error for unknown field")
+	}
+}
+
+func TestParseDeployments(t *testing.T) {
+	got, err := parseDeployments([]string{"gpt-5.4-mini = prod-mini", "gpt-5.4=prod"})
+	if err != nil {
+		t.Fatalf("parseDeployments returned error: %v", err)
+	}
+	if len(got) != 2 || got["gpt-5.4-mini"] != "prod-mini" || got["gpt-5.4"] != "prod" {
+		t.Fatalf("parseDeployments = %#v", got)
+	}
+	for _, bad := range []string{"gpt-5.4", "=prod", "gpt-5.4="} {
+		if _, err := parseDeployments([]string{bad}); err == nil {
+			t.Fatalf("parseDeployments(%q) expected error", bad)
+		}
+	}
+}
+
+func TestGatewayOptions(t *testing.T) {
+	opts, err := gatewayOptions([]string{"Authorization: Bearer t"}, []string{"tenant=acme"})
+	if err != nil || len(opts) != 2 {
+		t.Fatalf("gatewayOptions = %d options, %v", len(opts), err)
+	}
+	if _, err := gatewayOptions([]string{"Authorization"}, nil); err == nil {
+		t.Fatal("expected error for a header without a value")
+	}
+	if _, err := gatewayOptions(nil, []string{"tenant"}); err == nil {
+		t.Fatal("expected error for a query parameter without a value")
+	}
+}
+
+func TestNewModelPreparesOllamaOnlyOnRequest(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.Error(w, "down", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	cfg := modelConfig{provider: providerOllama, baseURL: server.URL, name: llm.DefaultOllamaModel}
+
+	if _, err := newModel(context.Background(), cfg); err != nil {
+		t.Fatalf("newModel returned error: %v", err)
+	}
+	if n := requests.Load(); n != 0 {
+		t.Fatalf("newModel sent %d requests without --ollama-warmup", n)
+	}
+
+	cfg.ollamaWarmup = true
+	if _, err := newModel(context.Background(), cfg); err == nil {
+		t.Fatal("expected an error when the warm-up fails without fallbacks")
+	}
+
+	// A fallback chain keeps the unreachable backend for the breaker.
+	cfg.fallbacks = []string{"provider=ollama,url=" + server.URL}
+	if _, err := newModel(context.Background(), cfg); err != nil {
+		t.Fatalf("newModel with fallbacks returned error: %v", err)
+	}
+}
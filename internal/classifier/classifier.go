@@ -10,11 +10,62 @@ import (
 	"taxowalk/internal/taxonomy"
 )
 
+var (
+	// ErrNoMatch reports a walk that ended without a category because the
+	// model rejected every top-level option. Classify itself returns a nil
+	// node and no error in that case; callers that treat it as a failure
+	// report ErrNoMatch.
+	ErrNoMatch = errors.New("no matching category")
+	// ErrInvalidSelection reports a model answer that does not pick
+	// exactly one of the offered options.
+	ErrInvalidSelection = errors.New("invalid selection")
+)
+
+// LevelError reports a failure at one level of a classification walk.
+type LevelError struct {
+	// Depth is the number of categories selected before the failure.
+	Depth int
+	Path  []string
+	Err   error
+}
+
+func (e *LevelError) Error() string {
+	if len(e.Path) == 0 {
+		return fmt.Sprintf("top level: %v", e.Err)
+	}
+	return fmt.Sprintf("level %d (%s): %v", e.Depth, strings.Join(e.Path, " > "), e.Err)
+}
+
+func (e *LevelError) Unwrap() error { return e.Err }
+
+// Step records one level of a classification walk.
+type Step struct {
+	Depth   int       `json:"depth"`
+	Path    []string  `json:"path"`
+	Options int       `json:"options"`
+	Choice  string    `json:"choice"`
+	Model   string    `json:"model,omitempty"`
+	Backend string    `json:"backend,omitempty"`
+	Usage   llm.Usage `json:"usage"`
+	// PromptVersion is the hash of the prompt template used for the step.
+	PromptVersion string `json:"prompt_version,omitempty"`
+	// PromptFingerprint is the hash of the rendered request; see
+	// llm.Result.PromptFingerprint.
+	PromptFingerprint string `json:"prompt_fingerprint,omitempty"`
+	// Retries lists the requests of the step that failed and were retried.
+	Retries []llm.Retry `json:"retries,omitempty"`
+}
+
 type Classifier struct {
 	model      llm.Model
 	taxonomy   *taxonomy.Taxonomy
 	totalUsage llm.Usage
+	modelUsage map[string]llm.Usage
+	trace      []Step
 	debugf     func(format string, args ...interface{})
+
+	imageLevels int
+	examples    []llm.Example
 }
 
 func New(model llm.Model, tax *taxonomy.Taxonomy) (*Classifier, error) {
@@ -31,6 +82,17 @@ func (c *Classifier) SetDebugLogger(fn func(format string, args ...interface{}))
 	c.debugf = fn
 }
 
+// SetImageLevels limits image attachments to the first n levels of the walk.
+// Zero or a negative value attaches images at every level.
+func (c *Classifier) SetImageLevels(n int) {
+	c.imageLevels = n
+}
+
+// SetExamples supplies labelled products that are passed to every prompt.
+func (c *Classifier) SetExamples(examples []llm.Example) {
+	c.examples = examples
+}
+
 func (c *Classifier) logf(format string, args ...interface{}) {
 	if c != nil && c.debugf != nil {
 		c.debugf(format, args...)
@@ -38,11 +100,19 @@ func (c *Classifier) logf(format string, args ...interface{}) {
 }
 
 func (c *Classifier) Classify(ctx context.Context, description string) (*taxonomy.Node, error) {
+	return c.ClassifyWithImages(ctx, description, nil)
+}
+
+// ClassifyWithImages walks the taxonomy like Classify, attaching the given
+// product images to the prompts allowed by SetImageLevels.
+func (c *Classifier) ClassifyWithImages(ctx context.Context, description string, images []llm.Image) (*taxonomy.Node, error) {
 	if strings.TrimSpace(description) == "" {
 		return nil, errors.New("description is empty")
 	}
 
 	c.totalUsage = llm.Usage{}
+	c.modelUsage = make(map[string]llm.Usage)
+	c.trace = nil
 	var current *taxonomy.Node
 	options := c.taxonomy.Roots
 	var path []string
@@ -50,7 +120,9 @@ func (c *Classifier) Classify(ctx context.Context, description string) (*taxonom
 	c.logf("Starting classification with %d root options", len(options))
 
 	for {
-		available := nextLevelOptions(current, options)
+		// Options are the children of the current node, so each level is
+		// one step down the real tree whatever the IDs look like.
+		available := options
 		if len(available) == 0 {
 			break
 		}
@@ -63,9 +135,16 @@ func (c *Classifier) Classify(ctx context.Context, description string) (*taxonom
 			Description: description,
 			Path:        append([]string{}, path...),
 			Options:     make([]llm.Option, len(available)),
+			Locale:      c.taxonomy.Locale,
+			Taxonomy:    c.taxonomy.Format,
+			Examples:    c.examples,
 		}
 		for i, opt := range available {
-			prompt.Options[i] = llm.Option{Name: opt.Name, FullName: opt.FullName, ID: opt.ID}
+			prompt.Options[i] = llm.Option{Name: opt.Name, FullName: opt.FullName, ID: opt.ID, Description: opt.Description, Aliases: opt.Aliases}
+		}
+		if len(images) > 0 && (c.imageLevels <= 0 || len(path) < c.imageLevels) {
+			prompt.Images = images
+			c.logf("Attaching %d image(s)", len(images))
 		}
 
 		optionSummaries := make([]string, len(available))
@@ -77,41 +156,63 @@ func (c *Classifier) Classify(ctx context.Context, description string) (*taxonom
 		c.logf("Requesting model choice")
 		result, err := c.model.ChooseOption(ctx, prompt)
 		if err != nil {
-			return nil, err
+			return nil, &LevelError{Depth: len(path), Path: prompt.Path, Err: err}
 		}
 
-		c.logf("Model returned choice %q (prompt tokens: %d, completion tokens: %d, total: %d)",
-			result.Choice, result.Usage.PromptTokens, result.Usage.CompletionTokens, result.Usage.TotalTokens)
-
-		c.totalUsage.PromptTokens += result.Usage.PromptTokens
-		c.totalUsage.CompletionTokens += result.Usage.CompletionTokens
-		c.totalUsage.TotalTokens += result.Usage.TotalTokens
+		if result.Backend != "" {
+			c.logf("Backend %s answered", result.Backend)
+		}
+		if result.PromptFingerprint != "" {
+			c.logf("Prompt fingerprint %s", result.PromptFingerprint)
+		}
+		for _, r := range result.Retries {
+			c.logf("Retried attempt %d after %dms: %s", r.Attempt, r.DelayMS, r.Error)
+		}
+		c.logf("Model %s returned choice %q (prompt tokens: %d, completion tokens: %d, total: %d)",
+			result.Model, result.Choice, result.Usage.PromptTokens, result.Usage.CompletionTokens, result.Usage.TotalTokens)
+		c.trace = append(c.trace, Step{
+			Depth:             len(path),
+			Path:              prompt.Path,
+			Options:           len(available),
+			Choice:            result.Choice,
+			Model:             result.Model,
+			Backend:           result.Backend,
+			Usage:             result.Usage,
+			PromptVersion:     result.PromptVersion,
+			PromptFingerprint: result.PromptFingerprint,
+			Retries:           result.Retries,
+		})
+
+		c.totalUsage = c.totalUsage.Add(result.Usage)
+		for name, usage := range result.ModelUsage() {
+			c.modelUsage[name] = c.modelUsage[name].Add(usage)
+		}
 
 		if result.ChoiceIndex == nil {
 			if strings.EqualFold(strings.TrimSpace(result.Choice), "none of these") {
 				c.logf("Model selected 'none of these'; stopping classification")
 				break
 			}
-			return current, fmt.Errorf("model returned unstructured selection %q", result.Choice)
+			return current, &LevelError{Depth: len(path), Path: prompt.Path, Err: fmt.Errorf("%w: model returned unstructured selection %q", ErrInvalidSelection, result.Choice)}
 		}
 
 		idx := *result.ChoiceIndex
 		if idx < 0 || idx >= len(available) {
-			return current, fmt.Errorf("model selected out-of-range option index %d", idx)
+			return current, &LevelError{Depth: len(path), Path: prompt.Path, Err: fmt.Errorf("%w: model selected out-of-range option index %d", ErrInvalidSelection, idx)}
 		}
 		next := available[idx]
 		if next == nil {
-			return current, fmt.Errorf("model selected empty option index %d", idx)
+			return current, &LevelError{Depth: len(path), Path: prompt.Path, Err: fmt.Errorf("%w: model selected empty option index %d", ErrInvalidSelection, idx)}
 		}
 		if strings.EqualFold(strings.TrimSpace(result.Choice), "none of these") {
 			c.logf("Model selected 'none of these'; stopping classification")
-			return current, errors.New("model returned conflicting selection: index with 'none of these'")
+			return current, &LevelError{Depth: len(path), Path: prompt.Path, Err: fmt.Errorf("%w: model returned conflicting selection: index with 'none of these'", ErrInvalidSelection)}
 		}
 
 		current = next
 		path = append(path, current.Name)
 		options = current.Children
-		c.logf("Descending to %s (%s) with %d child options", current.FullName, current.ID, len(nextLevelOptions(current, options)))
+		c.logf("Descending to %s (%s) with %d child options", current.FullName, current.ID, len(options))
 	}
 
 	if current == nil {
@@ -126,39 +227,17 @@ func (c *Classifier) Usage() llm.Usage {
 	return c.totalUsage
 }
 
-func nextLevelOptions(parent *taxonomy.Node, options []*taxonomy.Node) []*taxonomy.Node {
-	if len(options) == 0 {
-		return options
-	}
-	if parent == nil {
-		return options
-	}
-	parentDepth := optionDepth(parent.ID)
-	minDepth := 0
-	for _, opt := range options {
-		depth := optionDepth(opt.ID)
-		if depth <= parentDepth {
-			continue
-		}
-		if minDepth == 0 || depth < minDepth {
-			minDepth = depth
-		}
-	}
-	if minDepth == 0 {
-		return options
-	}
-	filtered := make([]*taxonomy.Node, 0, len(options))
-	for _, opt := range options {
-		if optionDepth(opt.ID) == minDepth {
-			filtered = append(filtered, opt)
-		}
-	}
-	return filtered
+// Trace returns one Step per model call made by the last classification.
+func (c *Classifier) Trace() []Step {
+	return append([]Step(nil), c.trace...)
 }
 
-func optionDepth(id string) int {
-	if id == "" {
-		return 0
+// UsageByModel returns the token usage of the last classification split by
+// the model that served each request.
+func (c *Classifier) UsageByModel() map[string]llm.Usage {
+	out := make(map[string]llm.Usage, len(c.modelUsage))
+	for name, usage := range c.modelUsage {
+		out[name] = usage
 	}
-	return strings.Count(id, "-") + 1
+	return out
 }
@@ -102,14 +102,15 @@ func TestClassifierPropagatesModelError(t *testing.T) {
 	}
 }
 
-func TestClassifierOffersOnlyNextLevelOptions(t *testing.T) {
-	leaf := &taxonomy.Node{ID: "aa-1", Name: "Sub", FullName: "Top > Category > Sub"}
-	grandChild := &taxonomy.Node{ID: "aa-1-1", Name: "SubSub", FullName: "Top > Category > Sub > SubSub"}
-	parent := &taxonomy.Node{ID: "aa", Name: "Category", FullName: "Top > Category", Children: []*taxonomy.Node{leaf, grandChild}}
-	root := &taxonomy.Node{ID: "", Name: "Top", FullName: "Top", Children: []*taxonomy.Node{parent}}
+func TestClassifierOffersChildrenRegardlessOfIDs(t *testing.T) {
+	// IDs from an in-house taxonomy whose dashes say nothing about depth.
+	boots := &taxonomy.Node{ID: "shoes-boots-and-wellies", Name: "Boots", FullName: "Top > Shoes > Boots"}
+	sneakers := &taxonomy.Node{ID: "sneakers", Name: "Sneakers", FullName: "Top > Shoes > Sneakers"}
+	shoes := &taxonomy.Node{ID: "shoes", Name: "Shoes", FullName: "Top > Shoes", Children: []*taxonomy.Node{boots, sneakers}}
+	root := &taxonomy.Node{ID: "", Name: "Top", FullName: "Top", Children: []*taxonomy.Node{shoes}}
 	tax := &taxonomy.Taxonomy{Version: "test", Roots: []*taxonomy.Node{root}}
 
-	model := &mockModel{responseIndexes: []*int{intPtr(0), intPtr(0), intPtr(0)}}
+	model := &mockModel{responseIndexes: []*int{intPtr(0), intPtr(0), intPtr(1)}}
 	clf, err := New(model, tax)
 	if err != nil {
 		t.Fatalf("New returned error: %v", err)
@@ -118,18 +119,15 @@ func TestClassifierOffersOnlyNextLevelOptions(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Classify returned error: %v", err)
 	}
-	if node != leaf {
-		t.Fatalf("expected leaf node, got %#v", node)
+	if node != sneakers {
+		t.Fatalf("expected sneakers, got %#v", node)
 	}
-	if len(model.prompts) < 3 {
-		t.Fatalf("expected at least 3 prompts, got %d", len(model.prompts))
+	if len(model.prompts) != 3 {
+		t.Fatalf("expected 3 prompts, got %d", len(model.prompts))
 	}
 	last := model.prompts[2]
-	if len(last.Options) != 1 {
-		t.Fatalf("expected 1 option at third level, got %d", len(last.Options))
-	}
-	if last.Options[0].FullName != leaf.FullName {
-		t.Fatalf("unexpected option at third level: %#v", last.Options[0])
+	if len(last.Options) != 2 || last.Options[0].ID != boots.ID || last.Options[1].ID != sneakers.ID {
+		t.Fatalf("unexpected options at third level: %#v", last.Options)
 	}
 }
 
@@ -169,6 +167,9 @@ func TestClassifierRejectsOutOfRangeChoiceIndex(t *testing.T) {
 	if !strings.Contains(err.Error(), "out-of-range option index") {
 		t.Fatalf("unexpected error: %v", err)
 	}
+	if !errors.Is(err, ErrInvalidSelection) {
+		t.Fatalf("expected ErrInvalidSelection, got %v", err)
+	}
 }
 
 func TestClassifierRejectsUnstructuredSelection(t *testing.T) {
@@ -188,3 +189,135 @@ func TestClassifierRejectsUnstructuredSelection(t *testing.T) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 }
+
+func TestClassifierLimitsImagesToConfiguredLevels(t *testing.T) {
+	root := &taxonomy.Node{ID: "root", Name: "Root", FullName: "Root"}
+	child := &taxonomy.Node{ID: "child", Name: "Child", FullName: "Root > Child"}
+	root.Children = []*taxonomy.Node{child}
+	tax := &taxonomy.Taxonomy{Version: "test", Roots: []*taxonomy.Node{root}}
+
+	model := &mockModel{responseIndexes: []*int{intPtr(0), intPtr(0)}}
+	clf, err := New(model, tax)
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	clf.SetImageLevels(1)
+	images := []llm.Image{{URL: "https://example.com/photo.jpg"}}
+	if _, err := clf.ClassifyWithImages(context.Background(), "example", images); err != nil {
+		t.Fatalf("ClassifyWithImages returned error: %v", err)
+	}
+	if len(model.prompts) != 2 {
+		t.Fatalf("expected 2 prompts, got %d", len(model.prompts))
+	}
+	if len(model.prompts[0].Images) != 1 {
+		t.Fatalf("expected images on first level, got %d", len(model.prompts[0].Images))
+	}
+	if len(model.prompts[1].Images) != 0 {
+		t.Fatalf("expected no images on second level, got %d", len(model.prompts[1].Images))
+	}
+}
+
+type namedModel struct {
+	name string
+}
+
+func (m namedModel) ChooseOption(_ context.Context, prompt llm.Prompt) (*llm.Result, error) {
+	idx := 0
+	return &llm.Result{Choice: "picked", ChoiceIndex: &idx, Model: m.name, Usage: llm.Usage{TotalTokens: len(prompt.Path) + 1}}, nil
+}
+
+func TestClassifierReportsUsageByModel(t *testing.T) {
+	root := &taxonomy.Node{ID: "root", Name: "Root", FullName: "Root"}
+	child := &taxonomy.Node{ID: "child", Name: "Child", FullName: "Root > Child"}
+	root.Children = []*taxonomy.Node{child}
+	tax := &taxonomy.Taxonomy{Version: "test", Roots: []*taxonomy.Node{root}}
+
+	router, err := llm.NewRoutingModel(namedModel{name: "cheap"}, []llm.RoutingRule{{MinDepth: 1, Model: namedModel{name: "strong"}}}, nil)
+	if err != nil {
+		t.Fatalf("NewRoutingModel returned error: %v", err)
+	}
+	clf, err := New(router, tax)
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	if _, err := clf.Classify(context.Background(), "example"); err != nil {
+		t.Fatalf("Classify returned error: %v", err)
+	}
+	byModel := clf.UsageByModel()
+	if byModel["cheap"].TotalTokens != 1 || byModel["strong"].TotalTokens != 2 {
+		t.Fatalf("unexpected usage by model: %#v", byModel)
+	}
+	if clf.Usage().TotalTokens != 3 {
+		t.Fatalf("total tokens = %d, want 3", clf.Usage().TotalTokens)
+	}
+}
+
+func TestClassifierTraceRecordsBackend(t *testing.T) {
+	root := &taxonomy.Node{ID: "root", Name: "Root", FullName: "Root"}
+	tax := &taxonomy.Taxonomy{Version: "test", Roots: []*taxonomy.Node{root}}
+
+	fallback, err := llm.NewFallbackModel([]llm.Backend{
+		{Name: "down", Model: &mockModel{err: errors.New("unreachable")}},
+		{Name: "up", Model: namedModel{name: "m"}},
+	}, llm.BreakerConfig{})
+	if err != nil {
+		t.Fatalf("NewFallbackModel returned error: %v", err)
+	}
+	clf, err := New(fallback, tax)
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	if _, err := clf.Classify(context.Background(), "example"); err != nil {
+		t.Fatalf("Classify returned error: %v", err)
+	}
+	trace := clf.Trace()
+	if len(trace) != 1 {
+		t.Fatalf("expected 1 trace step, got %d", len(trace))
+	}
+	if trace[0].Backend != "up" || trace[0].Model != "m" || trace[0].Depth != 0 {
+		t.Fatalf("unexpected trace step: %#v", trace[0])
+	}
+}
+
+func TestClassifierWrapsModelErrorsWithLevel(t *testing.T) {
+	shoes := &taxonomy.Node{ID: "aa-1", Name: "Shoes", FullName: "Apparel & Accessories > Shoes"}
+	apparel := &taxonomy.Node{ID: "aa", Name: "Apparel & Accessories", FullName: "Apparel & Accessories", Children: []*taxonomy.Node{shoes}}
+	tax := &taxonomy.Taxonomy{Version: "test", Roots: []*taxonomy.Node{apparel}}
+
+	model := &failingAfterModel{inner: &mockModel{responses: []string{"aa"}, responseIndexes: []*int{intPtr(0)}}, failAt: 1, err: llm.ErrRateLimited}
+	clf, err := New(model, tax)
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	_, err = clf.Classify(context.Background(), "example")
+	var levelErr *LevelError
+	if !errors.As(err, &levelErr) {
+		t.Fatalf("expected LevelError, got %v", err)
+	}
+	if levelErr.Depth != 1 || len(levelErr.Path) != 1 || levelErr.Path[0] != "Apparel & Accessories" {
+		t.Fatalf("unexpected level error: %+v", levelErr)
+	}
+	if !errors.Is(err, llm.ErrRateLimited) {
+		t.Fatalf("expected the model error to be preserved, got %v", err)
+	}
+	if got := err.Error(); got != "level 1 (Apparel & Accessories): rate limited" {
+		t.Fatalf("Error() = %q", got)
+	}
+}
+
+// failingAfterModel delegates to inner until call failAt, which fails.
+type failingAfterModel struct {
+	inner  llm.Model
+	calls  int
+	failAt int
+	err    error
+}
+
+func (m *failingAfterModel) ChooseOption(ctx context.Context, prompt llm.Prompt) (*llm.Result, error) {
+	call := m.calls
+	m.calls++
+	if call == m.failAt {
+		return nil, m.err
+	}
+	return m.inner.ChooseOption(ctx, prompt)
+}
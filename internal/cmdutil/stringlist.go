@@ -0,0 +1,19 @@
+package cmdutil
+
+import "strings"
+
+// StringList is a flag.Value that collects every occurrence of a repeatable
+// string flag.
+type StringList []string
+
+func (l *StringList) String() string {
+	if l == nil {
+		return ""
+	}
+	return strings.Join(*l, ",")
+}
+
+func (l *StringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
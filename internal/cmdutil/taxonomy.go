@@ -3,33 +3,166 @@ package cmdutil
 import (
 	"context"
 	"flag"
+	"fmt"
+	"strings"
+	"time"
 
+	"taxowalk/internal/locale"
 	"taxowalk/internal/taxonomy"
 )
 
 const DefaultTaxonomyURL = "https://raw.githubusercontent.com/Shopify/product-taxonomy/refs/heads/main/dist/en/taxonomy.json"
 
+// DefaultMappingURL is Shopify's file of mappings between taxonomy versions
+// and to other taxonomies such as Google's.
+const DefaultMappingURL = "https://raw.githubusercontent.com/Shopify/product-taxonomy/refs/heads/main/dist/en/integrations/all_mappings.json"
+
 type TaxonomyFlags struct {
 	URL     string
+	Locale  string
+	Format  string
+	Overlay string
 	Refresh bool
+	MaxAge  time.Duration
 }
 
 func NewTaxonomyFlags() TaxonomyFlags {
-	return TaxonomyFlags{URL: DefaultTaxonomyURL}
+	return TaxonomyFlags{URL: DefaultTaxonomyURL, Locale: locale.Default, Format: taxonomy.FormatAuto, MaxAge: taxonomy.DefaultCacheMaxAge}
 }
 
 func (f *TaxonomyFlags) Register(fs *flag.FlagSet) {
 	if f.URL == "" {
 		f.URL = DefaultTaxonomyURL
 	}
-	fs.StringVar(&f.URL, "taxonomy-url", f.URL, "URL or file path for the Shopify taxonomy JSON")
+	if f.Locale == "" {
+		f.Locale = locale.Default
+	}
+	if f.Format == "" {
+		f.Format = taxonomy.FormatAuto
+	}
+	fs.StringVar(&f.URL, "taxonomy-url", f.URL, "URL or file path for the taxonomy, or builtin: for the embedded snapshot")
+	fs.StringVar(&f.Locale, "locale", f.Locale, "taxonomy locale to load, e.g. en, fr, de, or auto to match the description")
+	fs.StringVar(&f.Format, "taxonomy-format", f.Format, "taxonomy file format: "+strings.Join(append([]string{taxonomy.FormatAuto}, taxonomy.Formats()...), ", "))
+	fs.StringVar(&f.Overlay, "overlay", f.Overlay, "YAML or JSON file of categories to hide, rename, annotate, alias or add")
 	fs.BoolVar(&f.Refresh, "refresh-taxonomy", false, "ignore cached taxonomy data and fetch a fresh copy")
+	RegisterCacheMaxAge(fs, &f.MaxAge)
+}
+
+// Source returns the taxonomy location for the given locale. When the
+// configured URL follows Shopify's dist/<locale>/ layout the locale segment is
+// replaced; any other URL or file path is returned unchanged. Selecting the
+// google format without a URL loads Google's English taxonomy.
+func (f *TaxonomyFlags) Source(loc string) string {
+	if f.google() && f.URL == DefaultTaxonomyURL {
+		return taxonomy.GoogleTaxonomyURL
+	}
+	loc = locale.Normalize(loc)
+	if loc == "" || loc == locale.Auto {
+		loc = locale.Default
+	}
+	return LocaleURL(f.URL, loc)
 }
 
 func (f *TaxonomyFlags) Fetch(ctx context.Context) (*taxonomy.Taxonomy, error) {
-	var opts []taxonomy.FetchOption
-	if f.Refresh {
-		opts = append(opts, taxonomy.WithCacheDisabled())
+	return f.FetchLocale(ctx, f.Locale)
+}
+
+// FetchLocale loads the taxonomy distribution for loc, recording the locale
+// on the returned taxonomy and applying the overlay, if any. The default
+// Shopify taxonomy falls back to the embedded snapshot when it can be
+// neither downloaded nor read from the cache.
+func (f *TaxonomyFlags) FetchLocale(ctx context.Context, loc string) (*taxonomy.Taxonomy, error) {
+	opts := f.FetchOptions()
+	if f.Format != "" {
+		opts = append(opts, taxonomy.WithFormat(f.Format))
+	}
+	if f.Shopify() {
+		opts = append(opts, taxonomy.WithBuiltinFallback())
+	}
+	loc = locale.Normalize(loc)
+	if loc == "" || loc == locale.Auto || (f.google() && f.URL == DefaultTaxonomyURL) {
+		loc = locale.Default
+	}
+	source := f.Source(loc)
+	tax, err := taxonomy.Fetch(ctx, source, opts...)
+	if err != nil {
+		return nil, err
+	}
+	// Only sources in Shopify's dist/<locale>/ layout say which language
+	// their names are in; other files and URLs are left without a locale.
+	tax.Locale = sourceLocale(source)
+	if tax.Source == taxonomy.BuiltinSource || source == taxonomy.GoogleTaxonomyURL {
+		// The snapshot and Google's default taxonomy are English.
+		tax.Locale = locale.Default
+	}
+	if f.Overlay != "" {
+		overlay, err := taxonomy.LoadOverlay(ctx, f.Overlay, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load overlay %s: %w", f.Overlay, err)
+		}
+		if err := overlay.Apply(tax); err != nil {
+			return nil, fmt.Errorf("failed to apply overlay %s: %w", f.Overlay, err)
+		}
+	}
+	return tax, nil
+}
+
+// FetchOptions returns the cache options for downloads made on behalf of
+// these flags, such as mapping files.
+func (f *TaxonomyFlags) FetchOptions() []taxonomy.FetchOption {
+	return FetchOptions(f.Refresh, f.MaxAge)
+}
+
+// FetchOptions returns the options for --refresh-taxonomy and
+// --cache-max-age.
+func FetchOptions(refresh bool, maxAge time.Duration) []taxonomy.FetchOption {
+	if refresh {
+		return []taxonomy.FetchOption{taxonomy.WithCacheDisabled()}
+	}
+	return []taxonomy.FetchOption{taxonomy.WithCacheMaxAge(maxAge)}
+}
+
+// RegisterCacheMaxAge registers --cache-max-age for commands that download
+// taxonomy or mapping files.
+func RegisterCacheMaxAge(fs *flag.FlagSet, maxAge *time.Duration) {
+	if *maxAge == 0 {
+		*maxAge = taxonomy.DefaultCacheMaxAge
+	}
+	fs.DurationVar(maxAge, "cache-max-age", *maxAge, "use cached downloads this long before revalidating them with the server (0 revalidates every run)")
+}
+
+// Shopify reports whether the flags select the upstream Shopify taxonomy.
+func (f *TaxonomyFlags) Shopify() bool {
+	return f.URL == DefaultTaxonomyURL && !f.google()
+}
+
+func (f *TaxonomyFlags) google() bool {
+	return strings.EqualFold(strings.TrimSpace(f.Format), taxonomy.FormatGoogle)
+}
+
+// sourceLocale returns the locale named by the dist/<locale>/ path segment
+// of a Shopify taxonomy URL, or "" when source has no such segment.
+func sourceLocale(source string) string {
+	const marker = "/dist/"
+	idx := strings.LastIndex(source, marker)
+	if idx < 0 {
+		return ""
+	}
+	rest := source[idx+len(marker):]
+	slash := strings.Index(rest, "/")
+	if slash <= 0 {
+		return ""
+	}
+	return rest[:slash]
+}
+
+// LocaleURL rewrites the dist/<locale>/ path segment of a Shopify taxonomy
+// URL. Sources without such a segment are returned unchanged.
+func LocaleURL(source, loc string) string {
+	current := sourceLocale(source)
+	if current == "" || loc == "" {
+		return source
 	}
-	return taxonomy.Fetch(ctx, f.URL, opts...)
+	idx := strings.LastIndex(source, "/dist/") + len("/dist/")
+	return source[:idx] + loc + source[idx+len(current):]
 }
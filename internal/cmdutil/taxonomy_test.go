@@ -0,0 +1,84 @@
+package cmdutil
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"taxowalk/internal/taxonomy"
+)
+
+func TestLocaleURL(t *testing.T) {
+	cases := []struct {
+		source, locale, want string
+	}{
+		{DefaultTaxonomyURL, "fr", "https://raw.githubusercontent.com/Shopify/product-taxonomy/refs/heads/main/dist/fr/taxonomy.json"},
+		{DefaultTaxonomyURL, "en", DefaultTaxonomyURL},
+		{"https://mirror.example.com/dist/de/taxonomy.json", "pt-BR", "https://mirror.example.com/dist/pt-BR/taxonomy.json"},
+		{"testdata/sample.json", "fr", "testdata/sample.json"},
+	}
+	for _, tc := range cases {
+		if got := LocaleURL(tc.source, tc.locale); got != tc.want {
+			t.Fatalf("LocaleURL(%q, %q) = %q, want %q", tc.source, tc.locale, got, tc.want)
+		}
+	}
+}
+
+func TestTaxonomyFlagsSourceNormalisesLocale(t *testing.T) {
+	f := NewTaxonomyFlags()
+	want := "https://raw.githubusercontent.com/Shopify/product-taxonomy/refs/heads/main/dist/zh-CN/taxonomy.json"
+	if got := f.Source("zh"); got != want {
+		t.Fatalf("Source(zh) = %q, want %q", got, want)
+	}
+	if got := f.Source("auto"); got != DefaultTaxonomyURL {
+		t.Fatalf("Source(auto) = %q, want default URL", got)
+	}
+}
+
+func TestTaxonomyFlagsGoogleFormatUsesGoogleURL(t *testing.T) {
+	f := NewTaxonomyFlags()
+	f.Format = "google"
+	if got := f.Source("fr"); got != taxonomy.GoogleTaxonomyURL {
+		t.Fatalf("Source(fr) = %q, want Google taxonomy URL", got)
+	}
+	f.URL = "testdata/google.txt"
+	if got := f.Source("fr"); got != f.URL {
+		t.Fatalf("Source(fr) = %q, want configured path", got)
+	}
+}
+
+func TestFetchLocaleSetsLocaleOnlyForLocalisedSources(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	sample, err := os.ReadFile(filepath.Join("..", "taxonomy", "testdata", "sample.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		w.Write(sample)
+	}))
+	defer server.Close()
+
+	f := NewTaxonomyFlags()
+	f.URL = server.URL + "/dist/en/taxonomy.json"
+	tax, err := f.FetchLocale(context.Background(), "fr")
+	if err != nil {
+		t.Fatalf("FetchLocale returned error: %v", err)
+	}
+	if tax.Locale != "fr" || len(paths) != 1 || paths[0] != "/dist/fr/taxonomy.json" {
+		t.Fatalf("locale %q from %q, want fr from /dist/fr/taxonomy.json", tax.Locale, paths)
+	}
+
+	// A file keeps its own names whatever --locale asks for.
+	f.URL = filepath.Join("..", "taxonomy", "testdata", "sample.json")
+	if tax, err = f.FetchLocale(context.Background(), "fr"); err != nil {
+		t.Fatalf("FetchLocale returned error: %v", err)
+	}
+	if tax.Locale != "" {
+		t.Fatalf("file source locale = %q, want none", tax.Locale)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	versioninfo "taxowalk"
+	"taxowalk/internal/taxonomy"
 )
 
 func ResolveVersion(override string) string {
@@ -21,3 +22,14 @@ func ResolveVersion(override string) string {
 	}
 	return "dev"
 }
+
+// VersionLine is the --version output of commands that load a taxonomy:
+// the command name, its version and the version of the embedded taxonomy
+// snapshot.
+func VersionLine(name, override string) string {
+	line := name + " " + ResolveVersion(override)
+	if v := taxonomy.BuiltinVersion(); v != "" {
+		return line + " (embedded taxonomy " + v + ")"
+	}
+	return line + " (no embedded taxonomy)"
+}
@@ -0,0 +1,189 @@
+package history
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrBudgetExhausted is matched by the BudgetError returned when a
+// reservation would take spend in the budget window over a limit.
+var ErrBudgetExhausted = errors.New("budget exhausted")
+
+// defaultReservationTTL is how long an unsettled reservation counts against
+// the budget, so that a crashed process does not hold it forever.
+const defaultReservationTTL = 10 * time.Minute
+
+// BudgetLimits is a rolling spend limit shared by every process using the
+// same database. A zero Tokens or CostUSD leaves that dimension unlimited.
+type BudgetLimits struct {
+	Tokens  int64
+	CostUSD float64
+	Window  time.Duration
+}
+
+// BudgetError reports a reservation refused because it would exceed a limit.
+type BudgetError struct {
+	Limits BudgetLimits
+	// UsedTokens and UsedCostUSD are the spend already settled or reserved
+	// in the window; the Requested fields are the refused reservation.
+	UsedTokens       int64
+	UsedCostUSD      float64
+	RequestedTokens  int64
+	RequestedCostUSD float64
+}
+
+func (e *BudgetError) Error() string {
+	if e.Limits.Tokens > 0 && e.UsedTokens+e.RequestedTokens > e.Limits.Tokens {
+		return fmt.Sprintf("token budget exhausted: %d of %d tokens used in the last %s, %d more needed", e.UsedTokens, e.Limits.Tokens, e.Limits.Window, e.RequestedTokens)
+	}
+	return fmt.Sprintf("cost budget exhausted: $%.4f of $%.4f used in the last %s, $%.4f more needed", e.UsedCostUSD, e.Limits.CostUSD, e.Limits.Window, e.RequestedCostUSD)
+}
+
+func (e *BudgetError) Is(target error) bool { return target == ErrBudgetExhausted }
+
+// Budget reserves and settles spend against limits recorded in the
+// database. Reservations are checked and inserted in one IMMEDIATE
+// transaction, so concurrent processes cannot overshoot the limits together.
+type Budget struct {
+	db     *DB
+	limits BudgetLimits
+	ttl    time.Duration
+	now    func() time.Time
+}
+
+// Budget returns a Budget enforcing limits. A zero Window means 24 hours.
+func (d *DB) Budget(limits BudgetLimits) *Budget {
+	if limits.Window <= 0 {
+		limits.Window = 24 * time.Hour
+	}
+	return &Budget{db: d, limits: limits, ttl: defaultReservationTTL, now: time.Now}
+}
+
+// Reserve records a reservation of tokens and cost if, together with the
+// spend in the current window, it stays within the limits. It returns the
+// reservation ID to Settle, or a *BudgetError. Cancelling ctx stops the
+// wait for another process's write lock.
+func (b *Budget) Reserve(ctx context.Context, tokens int64, costUSD float64) (int64, error) {
+	conn, err := b.db.db.Conn(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to reserve budget: %w", err)
+	}
+	defer conn.Close()
+	if err := beginImmediate(ctx, conn); err != nil {
+		return 0, fmt.Errorf("failed to reserve budget: %w", err)
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			// Roll back even when ctx is done, so the connection does not
+			// go back to the pool inside the transaction.
+			conn.ExecContext(context.Background(), "ROLLBACK")
+		}
+	}()
+
+	now := b.now()
+	var usedTokens int64
+	var usedCost float64
+	err = conn.QueryRowContext(ctx, `
+		SELECT COALESCE(SUM(tokens), 0), COALESCE(SUM(cost_usd), 0)
+		FROM budget_reservations
+		WHERE created_at >= ? AND (settled = 1 OR expires_at > ?)`,
+		now.Add(-b.limits.Window).UnixMilli(), now.UnixMilli(),
+	).Scan(&usedTokens, &usedCost)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read budget usage: %w", err)
+	}
+	if (b.limits.Tokens > 0 && usedTokens+tokens > b.limits.Tokens) ||
+		(b.limits.CostUSD > 0 && usedCost+costUSD > b.limits.CostUSD) {
+		return 0, &BudgetError{Limits: b.limits, UsedTokens: usedTokens, UsedCostUSD: usedCost, RequestedTokens: tokens, RequestedCostUSD: costUSD}
+	}
+
+	res, err := conn.ExecContext(ctx, `
+		INSERT INTO budget_reservations (created_at, expires_at, tokens, cost_usd)
+		VALUES (?, ?, ?, ?)`,
+		now.UnixMilli(), now.Add(b.ttl).UnixMilli(), tokens, costUSD,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to reserve budget: %w", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to reserve budget: %w", err)
+	}
+	if _, err := conn.ExecContext(ctx, "COMMIT"); err != nil {
+		return 0, fmt.Errorf("failed to reserve budget: %w", err)
+	}
+	committed = true
+	return id, nil
+}
+
+// beginImmediate starts a write transaction on conn. SQLite's own busy
+// handler cannot be interrupted, so it is turned off while this waits for
+// other processes' write locks, for up to the connection's busy timeout or
+// until ctx is done.
+func beginImmediate(ctx context.Context, conn *sql.Conn) error {
+	var timeout int64
+	if err := conn.QueryRowContext(ctx, "PRAGMA busy_timeout").Scan(&timeout); err != nil {
+		return err
+	}
+	if _, err := conn.ExecContext(ctx, "PRAGMA busy_timeout = 0"); err != nil {
+		return err
+	}
+	defer conn.ExecContext(context.Background(), fmt.Sprintf("PRAGMA busy_timeout = %d", timeout))
+
+	deadline := time.Now().Add(time.Duration(timeout) * time.Millisecond)
+	delay := 5 * time.Millisecond
+	for {
+		_, err := conn.ExecContext(ctx, "BEGIN IMMEDIATE")
+		if err == nil || !isBusy(err) || time.Now().After(deadline) {
+			return err
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		delay = min(2*delay, 100*time.Millisecond)
+	}
+}
+
+// isBusy reports whether err is SQLITE_BUSY, in any of its extended forms.
+func isBusy(err error) bool {
+	var sqliteErr interface{ Code() int }
+	return errors.As(err, &sqliteErr) && sqliteErr.Code()&0xff == 5
+}
+
+// Settle replaces a reservation with the spend actually incurred.
+func (b *Budget) Settle(ctx context.Context, id int64, tokens int64, costUSD float64) error {
+	_, err := b.db.db.ExecContext(ctx, `
+		UPDATE budget_reservations SET tokens = ?, cost_usd = ?, settled = 1
+		WHERE id = ?`,
+		tokens, costUSD, id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to settle budget reservation: %w", err)
+	}
+	return nil
+}
+
+// Usage returns the spend settled or reserved in the current window.
+func (b *Budget) Usage() (int64, float64, error) {
+	now := b.now()
+	var tokens int64
+	var cost float64
+	err := b.db.db.QueryRow(`
+		SELECT COALESCE(SUM(tokens), 0), COALESCE(SUM(cost_usd), 0)
+		FROM budget_reservations
+		WHERE created_at >= ? AND (settled = 1 OR expires_at > ?)`,
+		now.Add(-b.limits.Window).UnixMilli(), now.UnixMilli(),
+	).Scan(&tokens, &cost)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read budget usage: %w", err)
+	}
+	return tokens, cost, nil
+}
@@ -0,0 +1,160 @@
+package history
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func openTestDB(t *testing.T, path string) *DB {
+	t.Helper()
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// clock is a settable time source for Budget.now.
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time { return c.t }
+
+func TestBudgetSharesTheLastAllowance(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.db")
+	limits := BudgetLimits{Tokens: 10, Window: time.Hour}
+	budgets := []*Budget{openTestDB(t, path).Budget(limits), openTestDB(t, path).Budget(limits)}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	granted, refused := 0, 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(b *Budget) {
+			defer wg.Done()
+			_, err := b.Reserve(context.Background(), 1, 0)
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				granted++
+			case errors.Is(err, ErrBudgetExhausted):
+				refused++
+			default:
+				t.Errorf("Reserve returned error: %v", err)
+			}
+		}(budgets[i%2])
+	}
+	wg.Wait()
+	if granted != 10 || refused != 10 {
+		t.Fatalf("granted %d and refused %d reservations, want 10 each", granted, refused)
+	}
+	if tokens, _, err := budgets[0].Usage(); err != nil || tokens != 10 {
+		t.Fatalf("Usage = %d, %v; want 10", tokens, err)
+	}
+}
+
+func TestBudgetWindowRollsOff(t *testing.T) {
+	c := &clock{t: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)}
+	b := openTestDB(t, filepath.Join(t.TempDir(), "history.db")).Budget(BudgetLimits{CostUSD: 1, Window: time.Hour})
+	b.now = c.now
+
+	id, err := b.Reserve(context.Background(), 100, 0.8)
+	if err != nil {
+		t.Fatalf("Reserve returned error: %v", err)
+	}
+	if err := b.Settle(context.Background(), id, 100, 0.8); err != nil {
+		t.Fatalf("Settle returned error: %v", err)
+	}
+	c.t = c.t.Add(30 * time.Minute)
+	var budgetErr *BudgetError
+	if _, err := b.Reserve(context.Background(), 100, 0.5); !errors.As(err, &budgetErr) {
+		t.Fatalf("Reserve within the window = %v, want *BudgetError", err)
+	}
+	if budgetErr.UsedCostUSD != 0.8 || budgetErr.RequestedCostUSD != 0.5 {
+		t.Fatalf("unexpected BudgetError: %+v", budgetErr)
+	}
+
+	c.t = c.t.Add(31 * time.Minute)
+	if _, err := b.Reserve(context.Background(), 100, 0.5); err != nil {
+		t.Fatalf("Reserve after the window returned error: %v", err)
+	}
+	if _, cost, err := b.Usage(); err != nil || cost != 0.5 {
+		t.Fatalf("Usage = $%v, %v; want $0.5", cost, err)
+	}
+}
+
+func TestBudgetUnsettledReservationExpires(t *testing.T) {
+	c := &clock{t: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)}
+	b := openTestDB(t, filepath.Join(t.TempDir(), "history.db")).Budget(BudgetLimits{Tokens: 100, Window: 24 * time.Hour})
+	b.now = c.now
+
+	if _, err := b.Reserve(context.Background(), 90, 0); err != nil {
+		t.Fatalf("Reserve returned error: %v", err)
+	}
+	c.t = c.t.Add(defaultReservationTTL - time.Second)
+	if _, err := b.Reserve(context.Background(), 20, 0); !errors.Is(err, ErrBudgetExhausted) {
+		t.Fatalf("Reserve before expiry = %v, want ErrBudgetExhausted", err)
+	}
+	// A process that never settled no longer holds its reservation.
+	c.t = c.t.Add(2 * time.Second)
+	if tokens, _, err := b.Usage(); err != nil || tokens != 0 {
+		t.Fatalf("Usage after expiry = %d, %v; want 0", tokens, err)
+	}
+	if _, err := b.Reserve(context.Background(), 20, 0); err != nil {
+		t.Fatalf("Reserve after expiry returned error: %v", err)
+	}
+}
+
+func TestBudgetSettleReplacesEstimate(t *testing.T) {
+	c := &clock{t: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)}
+	b := openTestDB(t, filepath.Join(t.TempDir(), "history.db")).Budget(BudgetLimits{Tokens: 100, Window: time.Hour})
+	b.now = c.now
+
+	id, err := b.Reserve(context.Background(), 80, 0.4)
+	if err != nil {
+		t.Fatalf("Reserve returned error: %v", err)
+	}
+	if err := b.Settle(context.Background(), id, 25, 0.1); err != nil {
+		t.Fatalf("Settle returned error: %v", err)
+	}
+	if tokens, cost, err := b.Usage(); err != nil || tokens != 25 || cost != 0.1 {
+		t.Fatalf("Usage = %d, $%v, %v; want 25, $0.1", tokens, cost, err)
+	}
+	// Settled spend does not expire with the reservation TTL.
+	c.t = c.t.Add(defaultReservationTTL + time.Minute)
+	if tokens, _, err := b.Usage(); err != nil || tokens != 25 {
+		t.Fatalf("Usage after the TTL = %d, %v; want 25", tokens, err)
+	}
+}
+
+func TestBudgetReserveStopsWaitingForLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.db")
+	holder := openTestDB(t, path)
+	b := openTestDB(t, path).Budget(BudgetLimits{Tokens: 100})
+
+	// Another process holds the write lock for longer than the timeout.
+	conn, err := holder.db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Conn returned error: %v", err)
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(context.Background(), "BEGIN IMMEDIATE"); err != nil {
+		t.Fatalf("BEGIN IMMEDIATE returned error: %v", err)
+	}
+	defer conn.ExecContext(context.Background(), "ROLLBACK")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := b.Reserve(ctx, 1, 0); err == nil {
+		t.Fatal("Reserve succeeded while another connection held the lock")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("Reserve waited %s for the lock despite the timeout", elapsed)
+	}
+}
@@ -3,6 +3,7 @@ package history
 import (
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 
 	_ "modernc.org/sqlite"
@@ -21,10 +22,45 @@ type ClassificationRecord struct {
 	PromptTokens     int
 	CompletionTokens int
 	TotalTokens      int
+	// CachedPromptTokens is the part of PromptTokens served from the
+	// provider's prompt cache; ReasoningTokens the part of
+	// CompletionTokens spent on hidden reasoning.
+	CachedPromptTokens int
+	ReasoningTokens    int
+	// PromptVersion is the hash of the prompt template used, if known.
+	PromptVersion string
+	// OverlayHash identifies the taxonomy overlay applied, if any.
+	OverlayHash string
+	Models      []ModelUsage
+}
+
+// ModelUsage is the share of a classification's tokens spent on one model.
+type ModelUsage struct {
+	Model              string
+	PromptTokens       int
+	CompletionTokens   int
+	TotalTokens        int
+	CachedPromptTokens int
+	ReasoningTokens    int
+}
+
+// UsageTotals sums token usage over every recorded classification.
+type UsageTotals struct {
+	PromptTokens       int64
+	CompletionTokens   int64
+	TotalTokens        int64
+	CachedPromptTokens int64
+	ReasoningTokens    int64
 }
 
 func Open(dbPath string) (*DB, error) {
-	db, err := sql.Open("sqlite", dbPath)
+	// Several processes may share the database; wait for their write
+	// locks instead of failing with SQLITE_BUSY.
+	dsn := dbPath
+	if !strings.Contains(dsn, "?") {
+		dsn += "?_pragma=busy_timeout(10000)"
+	}
+	db, err := sql.Open("sqlite", dsn)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
@@ -55,11 +91,72 @@ func createTables(db *sql.DB) error {
 		total_tokens INTEGER DEFAULT 0
 	);
 	CREATE INDEX IF NOT EXISTS idx_timestamp ON classifications(timestamp);
+	CREATE TABLE IF NOT EXISTS classification_models (
+		classification_id INTEGER NOT NULL REFERENCES classifications(id),
+		model TEXT NOT NULL,
+		prompt_tokens INTEGER DEFAULT 0,
+		completion_tokens INTEGER DEFAULT 0,
+		total_tokens INTEGER DEFAULT 0
+	);
+	CREATE INDEX IF NOT EXISTS idx_classification_models ON classification_models(classification_id);
+	CREATE TABLE IF NOT EXISTS budget_reservations (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		created_at INTEGER NOT NULL,
+		expires_at INTEGER NOT NULL,
+		tokens INTEGER NOT NULL DEFAULT 0,
+		cost_usd REAL NOT NULL DEFAULT 0,
+		settled INTEGER NOT NULL DEFAULT 0
+	);
+	CREATE INDEX IF NOT EXISTS idx_budget_reservations_created ON budget_reservations(created_at);
 	`
 	_, err := db.Exec(schema)
 	if err != nil {
 		return fmt.Errorf("failed to create tables: %w", err)
 	}
+	for _, col := range []struct{ table, column, decl string }{
+		{"classifications", "prompt_version", "TEXT"},
+		{"classifications", "overlay_hash", "TEXT"},
+		{"classifications", "cached_prompt_tokens", "INTEGER DEFAULT 0"},
+		{"classifications", "reasoning_tokens", "INTEGER DEFAULT 0"},
+		{"classification_models", "cached_prompt_tokens", "INTEGER DEFAULT 0"},
+		{"classification_models", "reasoning_tokens", "INTEGER DEFAULT 0"},
+	} {
+		if err := ensureColumn(db, col.table, col.column, col.decl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ensureColumn adds a column to databases created before it existed.
+func ensureColumn(db *sql.DB, table, column, decl string) error {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return fmt.Errorf("failed to inspect %s: %w", table, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			cid        int
+			name, typ  string
+			notNull    int
+			dflt       sql.NullString
+			primaryKey int
+		)
+		if err := rows.Scan(&cid, &name, &typ, &notNull, &dflt, &primaryKey); err != nil {
+			return fmt.Errorf("failed to inspect %s: %w", table, err)
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to inspect %s: %w", table, err)
+	}
+	rows.Close()
+	if _, err := db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, decl)); err != nil {
+		return fmt.Errorf("failed to add %s.%s: %w", table, column, err)
+	}
 	return nil
 }
 
@@ -71,14 +168,49 @@ func (d *DB) Close() error {
 }
 
 func (d *DB) RecordClassification(productDesc, categoryName, categoryID string, promptTokens, completionTokens, totalTokens int) error {
-	_, err := d.db.Exec(`
-		INSERT INTO classifications (product_description, category_name, category_id, prompt_tokens, completion_tokens, total_tokens)
-		VALUES (?, ?, ?, ?, ?, ?)`,
-		productDesc, categoryName, categoryID, promptTokens, completionTokens, totalTokens,
+	return d.Record(ClassificationRecord{
+		ProductDesc:      productDesc,
+		Category:         categoryName,
+		CategoryID:       categoryID,
+		PromptTokens:     promptTokens,
+		CompletionTokens: completionTokens,
+		TotalTokens:      totalTokens,
+	})
+}
+
+// Record stores a classification together with its per-model usage. The ID
+// and Timestamp fields of r are ignored.
+func (d *DB) Record(r ClassificationRecord) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to record classification: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`
+		INSERT INTO classifications (product_description, category_name, category_id, prompt_tokens, completion_tokens, total_tokens, cached_prompt_tokens, reasoning_tokens, prompt_version, overlay_hash)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		r.ProductDesc, r.Category, r.CategoryID, r.PromptTokens, r.CompletionTokens, r.TotalTokens, r.CachedPromptTokens, r.ReasoningTokens, r.PromptVersion, r.OverlayHash,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to record classification: %w", err)
 	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to record classification: %w", err)
+	}
+	for _, m := range r.Models {
+		if _, err := tx.Exec(`
+			INSERT INTO classification_models (classification_id, model, prompt_tokens, completion_tokens, total_tokens, cached_prompt_tokens, reasoning_tokens)
+			VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			id, m.Model, m.PromptTokens, m.CompletionTokens, m.TotalTokens, m.CachedPromptTokens, m.ReasoningTokens,
+		); err != nil {
+			return fmt.Errorf("failed to record model usage: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to record classification: %w", err)
+	}
 	return nil
 }
 
@@ -106,11 +238,52 @@ func (d *DB) GetTokensLast24Hours() (int64, error) {
 	return total, nil
 }
 
+// GetUsageTotals sums every token counter over all classifications.
+func (d *DB) GetUsageTotals() (UsageTotals, error) {
+	var t UsageTotals
+	err := d.db.QueryRow(`
+		SELECT COALESCE(SUM(prompt_tokens), 0), COALESCE(SUM(completion_tokens), 0), COALESCE(SUM(total_tokens), 0),
+		       COALESCE(SUM(cached_prompt_tokens), 0), COALESCE(SUM(reasoning_tokens), 0)
+		FROM classifications
+	`).Scan(&t.PromptTokens, &t.CompletionTokens, &t.TotalTokens, &t.CachedPromptTokens, &t.ReasoningTokens)
+	if err != nil {
+		return UsageTotals{}, fmt.Errorf("failed to get usage totals: %w", err)
+	}
+	return t, nil
+}
+
+// GetTokensByModel sums recorded usage per model, ordered by model name.
+func (d *DB) GetTokensByModel() ([]ModelUsage, error) {
+	rows, err := d.db.Query(`
+		SELECT model, COALESCE(SUM(prompt_tokens), 0), COALESCE(SUM(completion_tokens), 0), COALESCE(SUM(total_tokens), 0),
+		       COALESCE(SUM(cached_prompt_tokens), 0), COALESCE(SUM(reasoning_tokens), 0)
+		FROM classification_models
+		GROUP BY model
+		ORDER BY model
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query model usage: %w", err)
+	}
+	defer rows.Close()
+
+	var usage []ModelUsage
+	for rows.Next() {
+		var m ModelUsage
+		if err := rows.Scan(&m.Model, &m.PromptTokens, &m.CompletionTokens, &m.TotalTokens, &m.CachedPromptTokens, &m.ReasoningTokens); err != nil {
+			return nil, fmt.Errorf("failed to scan model usage: %w", err)
+		}
+		usage = append(usage, m)
+	}
+	return usage, rows.Err()
+}
+
 func (d *DB) GetAllRecords() ([]ClassificationRecord, error) {
 	rows, err := d.db.Query(`
 		SELECT id, timestamp, product_description,
 		       COALESCE(category_name, ''), COALESCE(category_id, ''),
-		       prompt_tokens, completion_tokens, total_tokens
+		       prompt_tokens, completion_tokens, total_tokens,
+		       COALESCE(cached_prompt_tokens, 0), COALESCE(reasoning_tokens, 0), COALESCE(prompt_version, ''),
+		       COALESCE(overlay_hash, '')
 		FROM classifications
 		ORDER BY timestamp DESC
 	`)
@@ -123,7 +296,8 @@ func (d *DB) GetAllRecords() ([]ClassificationRecord, error) {
 	for rows.Next() {
 		var r ClassificationRecord
 		err := rows.Scan(&r.ID, &r.Timestamp, &r.ProductDesc, &r.Category, &r.CategoryID,
-			&r.PromptTokens, &r.CompletionTokens, &r.TotalTokens)
+			&r.PromptTokens, &r.CompletionTokens, &r.TotalTokens,
+			&r.CachedPromptTokens, &r.ReasoningTokens, &r.PromptVersion, &r.OverlayHash)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan record: %w", err)
 		}
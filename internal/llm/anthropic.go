@@ -0,0 +1,326 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultAnthropicModel is the Messages API model used when WithModel
+	// is not supplied.
+	DefaultAnthropicModel   = "claude-haiku-4-5"
+	defaultAnthropicBaseURL = "https://api.anthropic.com"
+	anthropicVersion        = "2023-06-01"
+	anthropicMaxTokens      = 256
+)
+
+// AnthropicModel implements Model on top of the Anthropic Messages API,
+// forcing a call to the selection tool.
+type AnthropicModel struct {
+	apiKey         string
+	baseURL        string
+	model          string
+	httpClient     *http.Client
+	template       *PromptTemplate
+	generation     GenerationSettings
+	dropped        []string
+	maxAttempts    int
+	retryBaseDelay time.Duration
+	retryMaxDelay  time.Duration
+	limiter        *rateLimiter
+	sleep          func(ctx context.Context, d time.Duration) error
+}
+
+func NewAnthropicModel(apiKey string, opts ...OptionFunc) (*AnthropicModel, error) {
+	cfg := newConfig(DefaultAnthropicModel, opts)
+	if strings.TrimSpace(apiKey) == "" && len(cfg.headers) == 0 {
+		return nil, errors.New("anthropic api key is empty")
+	}
+	if err := cfg.generation.Validate(); err != nil {
+		return nil, err
+	}
+	generation, dropped := cfg.generation.forAnthropic()
+	baseURL := cfg.baseURL
+	if baseURL == "" {
+		baseURL = defaultAnthropicBaseURL
+	}
+	httpClient, err := cfg.client()
+	if err != nil {
+		return nil, err
+	}
+	retry := cfg.retry.withDefaults()
+	limiter := defaultRateLimiters.get(baseURL, apiKey, cfg.headers)
+	client := withExtraFields(withRateLimits(httpClient, limiter), "/v1/messages", generation.Extra)
+	return &AnthropicModel{
+		apiKey:         strings.TrimSpace(apiKey),
+		baseURL:        strings.TrimRight(baseURL, "/"),
+		model:          cfg.model,
+		httpClient:     client,
+		template:       cfg.template,
+		generation:     generation,
+		dropped:        dropped,
+		maxAttempts:    retry.MaxAttempts,
+		retryBaseDelay: retry.BaseDelay,
+		retryMaxDelay:  retry.MaxDelay,
+		limiter:        limiter,
+		sleep:          sleepWithContext,
+	}, nil
+}
+
+// Name returns the model name used for requests.
+func (m *AnthropicModel) Name() string {
+	if m == nil {
+		return ""
+	}
+	return m.model
+}
+
+// DroppedSettings names the generation parameters the Messages API does not
+// accept and that are therefore left out of requests.
+func (m *AnthropicModel) DroppedSettings() []string {
+	if m == nil {
+		return nil
+	}
+	return m.dropped
+}
+
+func (m *AnthropicModel) maxTokens() int {
+	if m.generation.MaxCompletionTokens > 0 {
+		return m.generation.MaxCompletionTokens
+	}
+	return anthropicMaxTokens
+}
+
+type anthropicRequest struct {
+	Model       string              `json:"model"`
+	MaxTokens   int                 `json:"max_tokens"`
+	System      string              `json:"system,omitempty"`
+	Temperature *float64            `json:"temperature,omitempty"`
+	TopP        *float64            `json:"top_p,omitempty"`
+	Messages    []anthropicMessage  `json:"messages"`
+	Tools       []anthropicTool     `json:"tools"`
+	ToolChoice  anthropicToolChoice `json:"tool_choice"`
+}
+
+type anthropicMessage struct {
+	Role    string             `json:"role"`
+	Content []anthropicContent `json:"content"`
+}
+
+type anthropicContent struct {
+	Type   string                `json:"type"`
+	Text   string                `json:"text,omitempty"`
+	Source *anthropicImageSource `json:"source,omitempty"`
+	Name   string                `json:"name,omitempty"`
+	Input  json.RawMessage       `json:"input,omitempty"`
+
+	CacheControl *anthropicCacheControl `json:"cache_control,omitempty"`
+}
+
+type anthropicCacheControl struct {
+	Type string `json:"type"`
+}
+
+type anthropicImageSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type,omitempty"`
+	Data      string `json:"data,omitempty"`
+	URL       string `json:"url,omitempty"`
+}
+
+type anthropicTool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	InputSchema map[string]any `json:"input_schema"`
+}
+
+type anthropicToolChoice struct {
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
+}
+
+type anthropicResponse struct {
+	Content    []anthropicContent `json:"content"`
+	StopReason string             `json:"stop_reason"`
+	Usage      struct {
+		InputTokens              int `json:"input_tokens"`
+		OutputTokens             int `json:"output_tokens"`
+		CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+		CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+	} `json:"usage"`
+}
+
+type anthropicErrorResponse struct {
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (m *AnthropicModel) ChooseOption(ctx context.Context, prompt Prompt) (*Result, error) {
+	if m == nil {
+		return nil, errors.New("model is nil")
+	}
+	if len(prompt.Options) == 0 {
+		return nil, errors.New("prompt has no options")
+	}
+
+	rendered, err := m.template.render(prompt, toolInstruction)
+	if err != nil {
+		return nil, err
+	}
+	var content []anthropicContent
+	if rendered.context != "" {
+		content = append(content, anthropicContent{Type: "text", Text: rendered.context})
+	}
+	for _, img := range prompt.Images {
+		content = append(content, anthropicContent{Type: "image", Source: anthropicImage(img)})
+	}
+	if len(content) > 0 {
+		// Everything up to here repeats at every level; ask for it to be
+		// cached so later levels only pay for the candidates.
+		content[len(content)-1].CacheControl = &anthropicCacheControl{Type: "ephemeral"}
+	}
+	content = append(content, anthropicContent{Type: "text", Text: rendered.user})
+
+	req := anthropicRequest{
+		Model:       m.model,
+		MaxTokens:   m.maxTokens(),
+		System:      rendered.system,
+		Temperature: m.generation.Temperature,
+		TopP:        m.generation.TopP,
+		Messages:    []anthropicMessage{{Role: "user", Content: content}},
+		Tools: []anthropicTool{{
+			Name:        selectionToolName,
+			Description: "Select the best matching taxonomy option.",
+			InputSchema: selectionSchema(len(prompt.Options)),
+		}},
+		ToolChoice: anthropicToolChoice{Type: "tool", Name: selectionToolName},
+	}
+
+	if req.Temperature == nil && req.TopP == nil {
+		zero := 0.0
+		req.Temperature = &zero
+	}
+
+	var resp anthropicResponse
+	retries, err := withRetries(ctx, m.retryPolicy(), shouldRetryStatus, func() error {
+		var err error
+		resp, err = m.createMessage(ctx, req)
+		return err
+	})
+	if err != nil {
+		return nil, withRetryLog(err, retries)
+	}
+
+	selection, err := parseAnthropicSelection(resp)
+	if err != nil {
+		return nil, err
+	}
+	result, err := selectionResult(selection, prompt)
+	if err != nil {
+		return nil, err
+	}
+	result.Model = m.model
+	result.PromptVersion = m.template.Version()
+	result.PromptFingerprint = rendered.fingerprint(prompt.Images)
+	result.Retries = retries
+	promptTokens := resp.Usage.InputTokens + resp.Usage.CacheCreationInputTokens + resp.Usage.CacheReadInputTokens
+	result.Usage = Usage{
+		PromptTokens:       promptTokens,
+		CompletionTokens:   resp.Usage.OutputTokens,
+		TotalTokens:        promptTokens + resp.Usage.OutputTokens,
+		CachedPromptTokens: resp.Usage.CacheReadInputTokens,
+		CacheWriteTokens:   resp.Usage.CacheCreationInputTokens,
+	}
+	return result, nil
+}
+
+func (m *AnthropicModel) createMessage(ctx context.Context, body anthropicRequest) (anthropicResponse, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return anthropicResponse{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.baseURL+"/v1/messages", bytes.NewReader(payload))
+	if err != nil {
+		return anthropicResponse{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if m.apiKey != "" {
+		req.Header.Set("x-api-key", m.apiKey)
+	}
+	req.Header.Set("anthropic-version", anthropicVersion)
+
+	resp, err := m.httpClient.Do(req)
+	if err != nil {
+		return anthropicResponse{}, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return anthropicResponse{}, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return anthropicResponse{}, describeAnthropicError(resp.StatusCode, data)
+	}
+
+	var out anthropicResponse
+	if err := json.Unmarshal(data, &out); err != nil {
+		return anthropicResponse{}, fmt.Errorf("failed to parse messages response: %w", err)
+	}
+	return out, nil
+}
+
+func describeAnthropicError(status int, body []byte) error {
+	var apiErr anthropicErrorResponse
+	if err := json.Unmarshal(body, &apiErr); err != nil {
+		return &statusError{status: status, err: err, msg: fmt.Sprintf("messages request failed before tool parsing: endpoint returned HTTP %d with a non-JSON error response: %v", status, err)}
+	}
+	if msg := strings.TrimSpace(apiErr.Error.Message); msg != "" {
+		return &statusError{status: status, msg: fmt.Sprintf("messages request failed: endpoint returned HTTP %d: %s", status, msg)}
+	}
+	return &statusError{status: status, msg: fmt.Sprintf("messages request failed: endpoint returned HTTP %d", status)}
+}
+
+// shouldRetryStatus reports whether err carries a transient HTTP status.
+func shouldRetryStatus(err error) bool {
+	var status interface{ StatusCode() int }
+	if errors.As(err, &status) {
+		return retryableHTTPStatus(status.StatusCode())
+	}
+	return false
+}
+
+func parseAnthropicSelection(resp anthropicResponse) (string, error) {
+	for _, block := range resp.Content {
+		if block.Type != "tool_use" || block.Name != selectionToolName {
+			continue
+		}
+		return parseSelectionArgs(string(block.Input))
+	}
+	if resp.StopReason == "refusal" {
+		return "", &RefusalError{}
+	}
+	return "", invalidResponse("model did not return selection tool call")
+}
+
+// anthropicImage converts an Image into a Messages API image source,
+// unpacking data URLs into base64 payloads.
+func anthropicImage(img Image) *anthropicImageSource {
+	if data, ok := base64Payload(img); ok {
+		meta, _, _ := strings.Cut(strings.TrimPrefix(img.URL, "data:"), ",")
+		return &anthropicImageSource{Type: "base64", MediaType: strings.TrimSuffix(meta, ";base64"), Data: data}
+	}
+	return &anthropicImageSource{Type: "url", URL: img.URL}
+}
+
+func (m *AnthropicModel) retryPolicy() retryPolicy {
+	return retryPolicy{attempts: m.maxAttempts, base: m.retryBaseDelay, max: m.retryMaxDelay, sleep: m.sleep, limiter: m.limiter}
+}
@@ -0,0 +1,137 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAnthropicModelForcesSelectionTool(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/messages" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if got := r.Header.Get("x-api-key"); got != "test-key" {
+			t.Errorf("x-api-key = %q", got)
+		}
+		if r.Header.Get("anthropic-version") == "" {
+			t.Error("missing anthropic-version header")
+		}
+		var req anthropicRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		if req.ToolChoice.Type != "tool" || req.ToolChoice.Name != selectionToolName {
+			t.Errorf("unexpected tool choice: %#v", req.ToolChoice)
+		}
+		if len(req.Tools) != 1 || req.Tools[0].Name != selectionToolName {
+			t.Errorf("unexpected tools: %#v", req.Tools)
+		} else {
+			props, _ := req.Tools[0].InputSchema["properties"].(map[string]any)
+			selection, _ := props["selection"].(map[string]any)
+			if enum, _ := selection["enum"].([]any); len(enum) != 4 || enum[3] != noneSelection {
+				t.Errorf("unexpected selection enum: %#v", selection["enum"])
+			}
+		}
+		if len(req.Messages) != 1 || len(req.Messages[0].Content) != 3 {
+			t.Errorf("expected one user message with context, image and candidate content: %#v", req.Messages)
+		} else {
+			content := req.Messages[0].Content
+			if src := content[1].Source; src == nil || src.Type != "base64" || src.MediaType != "image/png" || src.Data != "AAAA" {
+				t.Errorf("unexpected image source: %#v", src)
+			}
+			if content[1].CacheControl == nil || content[0].CacheControl != nil || content[2].CacheControl != nil {
+				t.Errorf("expected the cache breakpoint on the last level-independent block: %#v", content)
+			}
+			if !strings.Contains(content[2].Text, "Candidate categories") || strings.Contains(content[2].Text, "tote") {
+				t.Errorf("unexpected per-level text: %q", content[2].Text)
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"content":[{"type":"tool_use","id":"toolu_1","name":"` + selectionToolName + `","input":{"selection":"2"}}],"stop_reason":"tool_use","usage":{"input_tokens":10,"output_tokens":4,"cache_creation_input_tokens":100,"cache_read_input_tokens":50}}`))
+	}))
+	defer server.Close()
+
+	model, err := NewAnthropicModel("test-key", WithBaseURL(server.URL))
+	if err != nil {
+		t.Fatalf("NewAnthropicModel returned error: %v", err)
+	}
+	result, err := model.ChooseOption(context.Background(), Prompt{
+		Description: "tote",
+		Options:     []Option{{Name: "Backpacks", ID: "lb-1"}, {Name: "Totes", ID: "lb-2"}},
+		Images:      []Image{{URL: "data:image/png;base64,AAAA"}},
+	})
+	if err != nil {
+		t.Fatalf("ChooseOption returned error: %v", err)
+	}
+	if result.Choice != "lb-2" || result.ChoiceIndex == nil || *result.ChoiceIndex != 1 {
+		t.Fatalf("unexpected result: %#v", result)
+	}
+	want := Usage{PromptTokens: 160, CompletionTokens: 4, TotalTokens: 164, CachedPromptTokens: 50, CacheWriteTokens: 100}
+	if result.Usage != want {
+		t.Fatalf("usage = %#v, want %#v", result.Usage, want)
+	}
+	if result.Model != DefaultAnthropicModel {
+		t.Fatalf("model = %q", result.Model)
+	}
+}
+
+func TestAnthropicModelRetriesOverloaded(t *testing.T) {
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if atomic.AddInt32(&hits, 1) == 1 {
+			w.WriteHeader(529)
+			_, _ = w.Write([]byte(`{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"content":[{"type":"tool_use","name":"` + selectionToolName + `","input":{"selection":"none_of_these"}}],"usage":{"input_tokens":1,"output_tokens":1}}`))
+	}))
+	defer server.Close()
+
+	model, err := NewAnthropicModel("test-key", WithBaseURL(server.URL))
+	if err != nil {
+		t.Fatalf("NewAnthropicModel returned error: %v", err)
+	}
+	model.sleep = func(context.Context, time.Duration) error { return nil }
+	result, err := model.ChooseOption(context.Background(), Prompt{Description: "x", Options: []Option{{Name: "A"}}})
+	if err != nil {
+		t.Fatalf("ChooseOption returned error: %v", err)
+	}
+	if result.ChoiceIndex != nil {
+		t.Fatalf("expected none of these, got %#v", result)
+	}
+	if hits != 2 {
+		t.Fatalf("hits = %d, want 2", hits)
+	}
+}
+
+func TestAnthropicModelDescribesErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"type":"error","error":{"type":"invalid_request_error","message":"max_tokens is required"}}`))
+	}))
+	defer server.Close()
+
+	model, err := NewAnthropicModel("test-key", WithBaseURL(server.URL))
+	if err != nil {
+		t.Fatalf("NewAnthropicModel returned error: %v", err)
+	}
+	_, err = model.ChooseOption(context.Background(), Prompt{Description: "x", Options: []Option{{Name: "A"}}})
+	want := "messages request failed: endpoint returned HTTP 400: max_tokens is required"
+	if err == nil || err.Error() != want {
+		t.Fatalf("error = %v, want %q", err, want)
+	}
+}
+
+func TestParseAnthropicSelectionMissingToolUse(t *testing.T) {
+	_, err := parseAnthropicSelection(anthropicResponse{Content: []anthropicContent{{Type: "text", Text: "2"}}})
+	if err == nil {
+		t.Fatal("expected error when tool_use block is missing")
+	}
+}
@@ -0,0 +1,160 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Ledger tracks spend shared with other processes. Reserve refuses a
+// reservation that would exceed the budget; Settle replaces a reservation
+// with the spend actually incurred.
+type Ledger interface {
+	Reserve(ctx context.Context, tokens int64, costUSD float64) (int64, error)
+	Settle(ctx context.Context, id int64, tokens int64, costUSD float64) error
+}
+
+// Price is a model's price in USD per million tokens. CachedInput applies
+// to prompt tokens served from the prompt cache and defaults to Input.
+type Price struct {
+	Input       float64
+	Output      float64
+	CachedInput float64
+}
+
+// Pricing maps model names to prices.
+type Pricing map[string]Price
+
+// ParsePrice parses a "MODEL=INPUT:OUTPUT[:CACHED]" price specification.
+func ParsePrice(spec string) (string, Price, error) {
+	model, rates, ok := strings.Cut(spec, "=")
+	model = strings.TrimSpace(model)
+	if !ok || model == "" {
+		return "", Price{}, fmt.Errorf("price %q must have the form MODEL=INPUT:OUTPUT[:CACHED]", spec)
+	}
+	parts := strings.Split(rates, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return "", Price{}, fmt.Errorf("price %q must have the form MODEL=INPUT:OUTPUT[:CACHED]", spec)
+	}
+	values := make([]float64, len(parts))
+	for i, part := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil || v < 0 {
+			return "", Price{}, fmt.Errorf("price %q has invalid rate %q", spec, part)
+		}
+		values[i] = v
+	}
+	p := Price{Input: values[0], Output: values[1], CachedInput: values[0]}
+	if len(values) == 3 {
+		p.CachedInput = values[2]
+	}
+	return model, p, nil
+}
+
+// price returns the price of model. Models without an entry are charged at
+// the most expensive configured rates so that budgets err on the safe side.
+func (p Pricing) price(model string) Price {
+	if price, ok := p[model]; ok {
+		return price
+	}
+	var top Price
+	for _, price := range p {
+		top.Input = max(top.Input, price.Input)
+		top.Output = max(top.Output, price.Output)
+		top.CachedInput = max(top.CachedInput, price.CachedInput)
+	}
+	return top
+}
+
+// Cost returns the USD cost of usage on model.
+func (p Pricing) Cost(model string, u Usage) float64 {
+	price := p.price(model)
+	uncached := u.PromptTokens - u.CachedPromptTokens
+	return (float64(uncached)*price.Input + float64(u.CachedPromptTokens)*price.CachedInput + float64(u.CompletionTokens)*price.Output) / 1e6
+}
+
+// BudgetModel reserves budget in a Ledger before every prompt and settles
+// the reservation with the usage reported afterwards, failing fast with the
+// ledger's error once the budget is exhausted.
+type BudgetModel struct {
+	model   Model
+	ledger  Ledger
+	pricing Pricing
+	// last is the token count of the previous prompt, which is a better
+	// estimate for the next one than the prompt length alone.
+	last int
+}
+
+// NewBudgetModel wraps model so that its prompts are charged to ledger.
+func NewBudgetModel(model Model, ledger Ledger, pricing Pricing) (*BudgetModel, error) {
+	if model == nil {
+		return nil, errors.New("budget model needs a model")
+	}
+	if ledger == nil {
+		return nil, errors.New("budget model needs a ledger")
+	}
+	return &BudgetModel{model: model, ledger: ledger, pricing: pricing}, nil
+}
+
+func (b *BudgetModel) ChooseOption(ctx context.Context, prompt Prompt) (*Result, error) {
+	if b == nil {
+		return nil, errors.New("model is nil")
+	}
+	tokens := max(estimateTokens(prompt), b.last)
+	// Estimates are split evenly between input and output pricing, which
+	// overstates the cost of the short selection replies.
+	cost := b.pricing.Cost("", Usage{PromptTokens: tokens / 2, CompletionTokens: tokens - tokens/2})
+	id, err := b.ledger.Reserve(ctx, int64(tokens), cost)
+	if err != nil {
+		return nil, err
+	}
+
+	// Spend is settled even when ctx was cancelled during the prompt.
+	settleCtx := context.WithoutCancel(ctx)
+	result, err := b.model.ChooseOption(ctx, prompt)
+	if err != nil {
+		// A reply that could not be used was still paid for; other
+		// failures are assumed not to have reached the model.
+		var spentTokens int64
+		var spentCost float64
+		if errors.Is(err, ErrInvalidResponse) || errors.Is(err, ErrRefusal) {
+			spentTokens, spentCost = int64(tokens), cost
+		}
+		if settleErr := b.ledger.Settle(settleCtx, id, spentTokens, spentCost); settleErr != nil {
+			return nil, errors.Join(err, settleErr)
+		}
+		return nil, err
+	}
+
+	var spentCost float64
+	for model, usage := range result.ModelUsage() {
+		spentCost += b.pricing.Cost(model, usage)
+	}
+	b.last = result.Usage.TotalTokens
+	if err := b.ledger.Settle(settleCtx, id, int64(result.Usage.TotalTokens), spentCost); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// estimateTokens guesses the tokens a prompt will use at about four
+// characters per token, plus the fixed instructions and the reply.
+func estimateTokens(p Prompt) int {
+	chars := len(p.Description)
+	for _, part := range p.Path {
+		chars += len(part) + 3
+	}
+	for _, opt := range p.Options {
+		chars += len(opt.Name) + len(opt.ID) + len(opt.Description) + 8
+		for _, alias := range opt.Aliases {
+			chars += len(alias) + 2
+		}
+	}
+	for _, ex := range p.Examples {
+		chars += len(ex.Description) + len(ex.Category) + 8
+	}
+	const instructions, reply, perImage = 400, 50, 800
+	return chars/4 + instructions + reply + perImage*len(p.Images)
+}
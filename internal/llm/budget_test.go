@@ -0,0 +1,135 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+)
+
+var errFakeBudget = errors.New("fake budget exhausted")
+
+type fakeLedger struct {
+	limit    int64
+	used     int64
+	reserved map[int64]int64
+	settled  map[int64]int64
+	costs    map[int64]float64
+	next     int64
+}
+
+func (l *fakeLedger) Reserve(_ context.Context, tokens int64, _ float64) (int64, error) {
+	if l.limit > 0 && l.used+tokens > l.limit {
+		return 0, errFakeBudget
+	}
+	l.next++
+	l.used += tokens
+	if l.reserved == nil {
+		l.reserved, l.settled, l.costs = map[int64]int64{}, map[int64]int64{}, map[int64]float64{}
+	}
+	l.reserved[l.next] = tokens
+	return l.next, nil
+}
+
+func (l *fakeLedger) Settle(_ context.Context, id int64, tokens int64, costUSD float64) error {
+	l.used += tokens - l.reserved[id]
+	l.settled[id] = tokens
+	l.costs[id] = costUSD
+	return nil
+}
+
+func TestParsePrice(t *testing.T) {
+	model, price, err := ParsePrice("gpt-5-mini=0.25:2")
+	if err != nil {
+		t.Fatalf("ParsePrice returned error: %v", err)
+	}
+	if model != "gpt-5-mini" || price != (Price{Input: 0.25, Output: 2, CachedInput: 0.25}) {
+		t.Fatalf("ParsePrice = %q %+v", model, price)
+	}
+	_, price, err = ParsePrice("m=1:4:0.1")
+	if err != nil || price.CachedInput != 0.1 {
+		t.Fatalf("ParsePrice cached = %+v, %v", price, err)
+	}
+	for _, spec := range []string{"m", "=1:2", "m=1", "m=1:2:3:4", "m=a:2", "m=-1:2"} {
+		if _, _, err := ParsePrice(spec); err == nil {
+			t.Errorf("ParsePrice(%q) succeeded", spec)
+		}
+	}
+}
+
+func TestPricingCost(t *testing.T) {
+	pricing := Pricing{"cheap": {Input: 1, Output: 2, CachedInput: 0.5}, "dear": {Input: 10, Output: 40, CachedInput: 10}}
+	got := pricing.Cost("cheap", Usage{PromptTokens: 1000, CachedPromptTokens: 400, CompletionTokens: 100})
+	want := (600*1 + 400*0.5 + 100*2) / 1e6
+	if math.Abs(got-want) > 1e-12 {
+		t.Fatalf("cost = %v, want %v", got, want)
+	}
+	// Unknown models are charged at the highest configured rates.
+	got = pricing.Cost("unknown", Usage{PromptTokens: 1000, CompletionTokens: 100})
+	want = (1000*10 + 100*40) / 1e6
+	if math.Abs(got-want) > 1e-12 {
+		t.Fatalf("unknown model cost = %v, want %v", got, want)
+	}
+}
+
+func TestBudgetModelSettlesActualUsage(t *testing.T) {
+	inner := &stubModel{name: "cheap", index: routerIndex(0), tokens: 1234}
+	ledger := &fakeLedger{}
+	model, err := NewBudgetModel(inner, ledger, Pricing{"cheap": {Input: 1, Output: 1, CachedInput: 1}})
+	if err != nil {
+		t.Fatalf("NewBudgetModel returned error: %v", err)
+	}
+	if _, err := model.ChooseOption(context.Background(), Prompt{Options: make([]Option, 2)}); err != nil {
+		t.Fatalf("ChooseOption returned error: %v", err)
+	}
+	if ledger.settled[1] != 1234 || ledger.used != 1234 {
+		t.Fatalf("settled %d, used %d, want 1234", ledger.settled[1], ledger.used)
+	}
+	// The next reservation is at least as large as the last prompt.
+	if _, err := model.ChooseOption(context.Background(), Prompt{Options: make([]Option, 2)}); err != nil {
+		t.Fatalf("ChooseOption returned error: %v", err)
+	}
+	if ledger.reserved[2] < 1234 {
+		t.Fatalf("second reservation = %d, want at least 1234", ledger.reserved[2])
+	}
+}
+
+func TestBudgetModelStopsWhenExhausted(t *testing.T) {
+	inner := &stubModel{name: "m", index: routerIndex(0)}
+	model, err := NewBudgetModel(inner, &fakeLedger{limit: 10}, nil)
+	if err != nil {
+		t.Fatalf("NewBudgetModel returned error: %v", err)
+	}
+	_, err = model.ChooseOption(context.Background(), Prompt{Options: make([]Option, 2)})
+	if !errors.Is(err, errFakeBudget) {
+		t.Fatalf("error = %v, want budget error", err)
+	}
+	if inner.calls != 0 {
+		t.Fatalf("inner model called %d times after the budget was exhausted", inner.calls)
+	}
+}
+
+func TestBudgetModelReleasesFailedReservations(t *testing.T) {
+	ledger := &fakeLedger{}
+	model, err := NewBudgetModel(&stubModel{err: outageError(503)}, ledger, nil)
+	if err != nil {
+		t.Fatalf("NewBudgetModel returned error: %v", err)
+	}
+	if _, err := model.ChooseOption(context.Background(), Prompt{}); err == nil {
+		t.Fatal("ChooseOption succeeded")
+	}
+	if ledger.used != 0 {
+		t.Fatalf("used = %d after an outage, want 0", ledger.used)
+	}
+
+	model, err = NewBudgetModel(&stubModel{err: invalidResponse("no choice")}, ledger, nil)
+	if err != nil {
+		t.Fatalf("NewBudgetModel returned error: %v", err)
+	}
+	if _, err := model.ChooseOption(context.Background(), Prompt{}); err == nil {
+		t.Fatal("ChooseOption succeeded")
+	}
+	if ledger.used == 0 {
+		t.Fatal("an invalid response was not charged")
+	}
+}
@@ -0,0 +1,247 @@
+package llm
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// CassetteMode selects how a Cassette treats chat-completion requests.
+type CassetteMode string
+
+const (
+	// CassetteRecord sends every request to the endpoint and records it,
+	// replacing any earlier contents of the cassette.
+	CassetteRecord CassetteMode = "record"
+	// CassetteReplay serves recorded responses and forwards, and records,
+	// requests the cassette has not seen.
+	CassetteReplay CassetteMode = "replay"
+	// CassetteStrict serves recorded responses only and fails on any
+	// request the cassette has not seen.
+	CassetteStrict CassetteMode = "strict"
+)
+
+const cassetteVersion = 1
+
+// ParseCassetteMode validates a cassette mode name.
+func ParseCassetteMode(name string) (CassetteMode, error) {
+	switch m := CassetteMode(strings.ToLower(strings.TrimSpace(name))); m {
+	case CassetteRecord, CassetteReplay, CassetteStrict:
+		return m, nil
+	default:
+		return "", fmt.Errorf("unknown cassette mode %q (want record, replay or strict)", name)
+	}
+}
+
+// Cassette records chat-completion exchanges to a JSON file and plays them
+// back, matching requests on a hash of their normalised JSON form. Repeated
+// identical requests are answered in the order they were recorded.
+type Cassette struct {
+	path string
+	mode CassetteMode
+
+	mu           sync.Mutex
+	interactions []cassetteInteraction
+	served       map[string]int
+}
+
+type cassetteFile struct {
+	Version      int                   `json:"version"`
+	Interactions []cassetteInteraction `json:"interactions"`
+}
+
+type cassetteInteraction struct {
+	Hash     string                         `json:"hash"`
+	Request  json.RawMessage                `json:"request"`
+	Response *openai.ChatCompletionResponse `json:"response,omitempty"`
+	Error    *cassetteError                 `json:"error,omitempty"`
+}
+
+// cassetteError is the recorded form of a failed request.
+type cassetteError struct {
+	Status  int    `json:"status,omitempty"`
+	Message string `json:"message"`
+}
+
+// OpenCassette loads the cassette at path. Record mode starts from an empty
+// cassette, replay mode tolerates a missing file, and strict mode requires
+// the file to exist.
+func OpenCassette(path string, mode CassetteMode) (*Cassette, error) {
+	if strings.TrimSpace(path) == "" {
+		return nil, errors.New("cassette path is empty")
+	}
+	c := &Cassette{path: path, mode: mode, served: make(map[string]int)}
+	if mode == CassetteRecord {
+		return c, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) && mode == CassetteReplay {
+			return c, nil
+		}
+		return nil, fmt.Errorf("failed to read cassette: %w", err)
+	}
+	var file cassetteFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse cassette %s: %w", path, err)
+	}
+	if file.Version != cassetteVersion {
+		return nil, fmt.Errorf("cassette %s has unsupported version %d", path, file.Version)
+	}
+	c.interactions = file.Interactions
+	return c, nil
+}
+
+// Mode returns the mode the cassette was opened with.
+func (c *Cassette) Mode() CassetteMode {
+	if c == nil {
+		return ""
+	}
+	return c.mode
+}
+
+// wrap returns a client that consults the cassette before live.
+func (c *Cassette) wrap(live chatCompletionClient) chatCompletionClient {
+	return &cassetteClient{cassette: c, live: live}
+}
+
+type cassetteClient struct {
+	cassette *Cassette
+	live     chatCompletionClient
+}
+
+func (cc *cassetteClient) CreateChatCompletion(ctx context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	c := cc.cassette
+	raw, hash, err := normalizeRequest(req)
+	if err != nil {
+		return openai.ChatCompletionResponse{}, err
+	}
+	if c.mode != CassetteRecord {
+		if rec, ok := c.next(hash); ok {
+			if rec.Error != nil {
+				return openai.ChatCompletionResponse{}, rec.Error.err()
+			}
+			return *rec.Response, nil
+		}
+		if c.mode == CassetteStrict {
+			return openai.ChatCompletionResponse{}, fmt.Errorf("cassette %s has no recorded response for request %s", c.path, hash[:12])
+		}
+	}
+	if cc.live == nil {
+		return openai.ChatCompletionResponse{}, errors.New("model client is nil")
+	}
+	resp, liveErr := cc.live.CreateChatCompletion(ctx, req)
+	if errors.Is(liveErr, context.Canceled) || errors.Is(liveErr, context.DeadlineExceeded) {
+		return resp, liveErr
+	}
+	rec := cassetteInteraction{Hash: hash, Request: raw}
+	if liveErr != nil {
+		rec.Error = recordError(liveErr)
+	} else {
+		rec.Response = &resp
+	}
+	if err := c.append(rec); err != nil {
+		return openai.ChatCompletionResponse{}, err
+	}
+	return resp, liveErr
+}
+
+// next returns the next unserved recording for hash. Once every recording
+// has been served the last one is repeated.
+func (c *Cassette) next(hash string) (cassetteInteraction, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var matches []int
+	for i, rec := range c.interactions {
+		if rec.Hash == hash && (rec.Response != nil || rec.Error != nil) {
+			matches = append(matches, i)
+		}
+	}
+	if len(matches) == 0 {
+		return cassetteInteraction{}, false
+	}
+	n := c.served[hash]
+	c.served[hash] = n + 1
+	if n >= len(matches) {
+		n = len(matches) - 1
+	}
+	return c.interactions[matches[n]], true
+}
+
+// append adds rec and rewrites the cassette file so that a run that is
+// interrupted still leaves every completed exchange on disk.
+func (c *Cassette) append(rec cassetteInteraction) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.interactions = append(c.interactions, rec)
+	// Recorded interactions are served ahead of later live calls for the
+	// same request, so count this one as already served.
+	c.served[rec.Hash]++
+
+	data, err := json.MarshalIndent(cassetteFile{Version: cassetteVersion, Interactions: c.interactions}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(c.path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("failed to write cassette: %w", err)
+		}
+	}
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o600); err != nil {
+		return fmt.Errorf("failed to write cassette: %w", err)
+	}
+	if err := os.Rename(tmp, c.path); err != nil {
+		return fmt.Errorf("failed to write cassette: %w", err)
+	}
+	return nil
+}
+
+// normalizeRequest renders req as canonical JSON, with object keys sorted
+// and empty fields omitted, and returns it together with its SHA-256 hash.
+func normalizeRequest(req openai.ChatCompletionRequest) (json.RawMessage, string, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to encode request for cassette: %w", err)
+	}
+	var generic any
+	if err := json.Unmarshal(data, &generic); err != nil {
+		return nil, "", fmt.Errorf("failed to encode request for cassette: %w", err)
+	}
+	canonical, err := json.Marshal(generic)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to encode request for cassette: %w", err)
+	}
+	sum := sha256.Sum256(canonical)
+	return canonical, hex.EncodeToString(sum[:]), nil
+}
+
+func recordError(err error) *cassetteError {
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) {
+		return &cassetteError{Status: apiErr.HTTPStatusCode, Message: apiErr.Message}
+	}
+	var reqErr *openai.RequestError
+	if errors.As(err, &reqErr) {
+		return &cassetteError{Status: reqErr.HTTPStatusCode, Message: err.Error()}
+	}
+	return &cassetteError{Message: err.Error()}
+}
+
+// err rebuilds an error that retry and fallback logic classify the same way
+// as the original.
+func (e *cassetteError) err() error {
+	if e.Status != 0 {
+		return &openai.APIError{HTTPStatusCode: e.Status, Message: e.Message}
+	}
+	return errors.New(e.Message)
+}
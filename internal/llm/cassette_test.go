@@ -0,0 +1,126 @@
+package llm
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+func TestCassetteRecordThenStrictReplay(t *testing.T) {
+	hits := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"choices":[{"message":{"role":"assistant","tool_calls":[{"id":"call_1","type":"function","function":{"name":"` + selectionToolName + `","arguments":"{\"selection\":\"2\"}"}}]}}],"usage":{"prompt_tokens":7,"completion_tokens":1,"total_tokens":8}}`))
+	}))
+	path := filepath.Join(t.TempDir(), "run.cassette.json")
+
+	recorder, err := OpenCassette(path, CassetteRecord)
+	if err != nil {
+		t.Fatalf("OpenCassette returned error: %v", err)
+	}
+	model, err := NewOpenAIModel("test-key", WithBaseURL(server.URL+"/v1"), WithCassette(recorder), WithTransport(TransportTool))
+	if err != nil {
+		t.Fatalf("NewOpenAIModel returned error: %v", err)
+	}
+	recorded, err := model.ChooseOption(context.Background(), transportPrompt)
+	if err != nil {
+		t.Fatalf("recording ChooseOption returned error: %v", err)
+	}
+	server.Close()
+
+	player, err := OpenCassette(path, CassetteStrict)
+	if err != nil {
+		t.Fatalf("OpenCassette returned error: %v", err)
+	}
+	model, err = NewOpenAIModel("test-key", WithBaseURL(server.URL+"/v1"), WithCassette(player), WithTransport(TransportTool))
+	if err != nil {
+		t.Fatalf("NewOpenAIModel returned error: %v", err)
+	}
+	replayed, err := model.ChooseOption(context.Background(), transportPrompt)
+	if err != nil {
+		t.Fatalf("replaying ChooseOption returned error: %v", err)
+	}
+	if hits != 1 {
+		t.Fatalf("server hits = %d, want 1", hits)
+	}
+	if replayed.Choice != recorded.Choice || replayed.Usage != recorded.Usage {
+		t.Fatalf("replayed %+v, recorded %+v", replayed, recorded)
+	}
+
+	other := transportPrompt
+	other.Description = "running shoes"
+	_, err = model.ChooseOption(context.Background(), other)
+	if err == nil || !strings.Contains(err.Error(), "no recorded response") {
+		t.Fatalf("unmatched request error = %v, want no recorded response", err)
+	}
+}
+
+func TestCassetteReplaysErrorsInOrder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "retry.cassette.json")
+	live := &fakeChatCompletionClient{
+		responses: []fakeChatCompletionResult{
+			{err: &openai.APIError{HTTPStatusCode: 429, Message: "slow down"}},
+			toolReply("1"),
+		},
+	}
+	recorder, err := OpenCassette(path, CassetteRecord)
+	if err != nil {
+		t.Fatalf("OpenCassette returned error: %v", err)
+	}
+	model := &OpenAIModel{client: recorder.wrap(live), model: "m", transport: TransportTool, maxAttempts: 2, retryBaseDelay: time.Millisecond, sleep: noSleep}
+	if _, err := model.ChooseOption(context.Background(), transportPrompt); err != nil {
+		t.Fatalf("recording ChooseOption returned error: %v", err)
+	}
+
+	player, err := OpenCassette(path, CassetteStrict)
+	if err != nil {
+		t.Fatalf("OpenCassette returned error: %v", err)
+	}
+	sleeps := 0
+	model = &OpenAIModel{client: player.wrap(nil), model: "m", transport: TransportTool, maxAttempts: 2, retryBaseDelay: time.Millisecond, sleep: func(context.Context, time.Duration) error {
+		sleeps++
+		return nil
+	}}
+	result, err := model.ChooseOption(context.Background(), transportPrompt)
+	if err != nil {
+		t.Fatalf("replaying ChooseOption returned error: %v", err)
+	}
+	if result.Choice != "aa-1" || sleeps != 1 {
+		t.Fatalf("choice = %q after %d retries, want aa-1 after 1", result.Choice, sleeps)
+	}
+}
+
+func TestOpenCassetteModes(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing.json")
+	if _, err := OpenCassette(missing, CassetteReplay); err != nil {
+		t.Fatalf("replay with missing file returned error: %v", err)
+	}
+	if _, err := OpenCassette(missing, CassetteStrict); err == nil {
+		t.Fatal("strict with missing file expected error")
+	}
+	if _, err := ParseCassetteMode("rewind"); err == nil {
+		t.Fatal("expected error for unknown cassette mode")
+	}
+}
+
+func toolReply(selection string) fakeChatCompletionResult {
+	return fakeChatCompletionResult{resp: openai.ChatCompletionResponse{
+		Choices: []openai.ChatCompletionChoice{{Message: openai.ChatCompletionMessage{
+			Role: openai.ChatMessageRoleAssistant,
+			ToolCalls: []openai.ToolCall{{
+				ID:       "call_1",
+				Type:     openai.ToolTypeFunction,
+				Function: openai.FunctionCall{Name: selectionToolName, Arguments: `{"selection":"` + selection + `"}`},
+			}},
+		}}},
+	}}
+}
+
+func noSleep(context.Context, time.Duration) error { return nil }
@@ -0,0 +1,128 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// Error classes reported by the models. Match them with errors.Is; every
+// error a model returns matches at most one of them, see Classify.
+var (
+	// ErrAuth reports a rejected or missing credential (HTTP 401 or 403).
+	ErrAuth = errors.New("authentication failed")
+	// ErrRateLimited reports an exhausted rate limit or quota (HTTP 429).
+	ErrRateLimited = errors.New("rate limited")
+	// ErrTimeout reports a request that ran out of time.
+	ErrTimeout = errors.New("request timed out")
+	// ErrUnavailable reports an endpoint that could not be reached or
+	// failed on its side (network errors, HTTP 5xx, open circuit breakers).
+	ErrUnavailable = errors.New("endpoint unavailable")
+	// ErrBadRequest reports a request the endpoint rejected as invalid,
+	// such as an unknown model (other HTTP 4xx statuses).
+	ErrBadRequest = errors.New("request rejected")
+	// ErrRefusal reports a model that declined to answer.
+	ErrRefusal = errors.New("model refused to answer")
+	// ErrInvalidResponse reports a reply that does not contain a usable
+	// selection.
+	ErrInvalidResponse = errors.New("invalid model response")
+	// ErrCircuitOpen reports a fallback backend skipped by its breaker.
+	ErrCircuitOpen = errors.New("circuit open")
+)
+
+// errorClasses is the order in which Classify tests the classes, so that an
+// error joining several failures is reported by its most actionable cause.
+var errorClasses = []error{ErrAuth, ErrRefusal, ErrInvalidResponse, ErrBadRequest, ErrRateLimited, ErrTimeout, ErrUnavailable}
+
+// Classify returns the error class matching err, or nil when err is nil or
+// does not belong to any class.
+func Classify(err error) error {
+	if err == nil {
+		return nil
+	}
+	for _, class := range errorClasses {
+		if errors.Is(err, class) {
+			return class
+		}
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrTimeout
+	}
+	if errors.Is(err, ErrCircuitOpen) {
+		return ErrUnavailable
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return ErrTimeout
+		}
+		return ErrUnavailable
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return ErrUnavailable
+	}
+	return nil
+}
+
+// StatusCode returns the HTTP status carried by err, or 0 if there is none.
+func StatusCode(err error) int {
+	var status interface{ StatusCode() int }
+	if errors.As(err, &status) {
+		return status.StatusCode()
+	}
+	return 0
+}
+
+// statusClass maps an HTTP status onto an error class.
+func statusClass(status int) error {
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return ErrAuth
+	case status == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case status == http.StatusRequestTimeout || status == http.StatusGatewayTimeout:
+		return ErrTimeout
+	case status >= 500:
+		return ErrUnavailable
+	case status >= 400:
+		return ErrBadRequest
+	default:
+		return nil
+	}
+}
+
+// RefusalError reports a model that declined to answer, with the reason it
+// gave when there is one.
+type RefusalError struct {
+	Reason string
+}
+
+func (e *RefusalError) Error() string {
+	if e.Reason == "" {
+		return ErrRefusal.Error()
+	}
+	return fmt.Sprintf("%s: %s", ErrRefusal, e.Reason)
+}
+
+func (e *RefusalError) Is(target error) bool { return target == ErrRefusal }
+
+// responseError marks a reply that could not be turned into a selection,
+// keeping the underlying description as its message.
+type responseError struct {
+	err error
+}
+
+func (e *responseError) Error() string        { return e.err.Error() }
+func (e *responseError) Unwrap() error        { return e.err }
+func (e *responseError) Is(target error) bool { return target == ErrInvalidResponse }
+
+func invalidResponse(msg string) error {
+	return &responseError{err: errors.New(msg)}
+}
+
+func invalidResponsef(format string, args ...any) error {
+	return &responseError{err: fmt.Errorf(format, args...)}
+}
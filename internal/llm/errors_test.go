@@ -0,0 +1,83 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+func TestClassifyStatusErrors(t *testing.T) {
+	cases := map[int]error{
+		http.StatusUnauthorized:        ErrAuth,
+		http.StatusForbidden:           ErrAuth,
+		http.StatusTooManyRequests:     ErrRateLimited,
+		http.StatusGatewayTimeout:      ErrTimeout,
+		http.StatusInternalServerError: ErrUnavailable,
+		529:                            ErrUnavailable,
+		http.StatusNotFound:            ErrBadRequest,
+		http.StatusBadRequest:          ErrBadRequest,
+	}
+	for status, want := range cases {
+		err := describeCreateChatCompletionError(&openai.APIError{HTTPStatusCode: status, Message: "boom"})
+		if got := Classify(err); got != want {
+			t.Errorf("status %d: Classify = %v, want %v", status, got, want)
+		}
+		if StatusCode(err) != status {
+			t.Errorf("status %d: StatusCode = %d", status, StatusCode(err))
+		}
+	}
+}
+
+func TestClassifyOtherErrors(t *testing.T) {
+	cases := []struct {
+		err  error
+		want error
+	}{
+		{nil, nil},
+		{errors.New("plain"), nil},
+		{fmt.Errorf("wrapped: %w", context.DeadlineExceeded), ErrTimeout},
+		{&net.OpError{Op: "dial", Err: errors.New("connection refused")}, ErrUnavailable},
+		{fmt.Errorf("backup: %w", ErrCircuitOpen), ErrUnavailable},
+		{&RefusalError{Reason: "policy"}, ErrRefusal},
+		{invalidResponse("model returned an empty reply"), ErrInvalidResponse},
+		// A joined failure reports its most actionable cause.
+		{errors.Join(&statusError{status: 503}, &statusError{status: 401}), ErrAuth},
+	}
+	for _, tc := range cases {
+		if got := Classify(tc.err); got != tc.want {
+			t.Errorf("Classify(%v) = %v, want %v", tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestChooseOptionReportsRefusal(t *testing.T) {
+	client := &fakeChatCompletionClient{responses: []fakeChatCompletionResult{{resp: openai.ChatCompletionResponse{
+		Choices: []openai.ChatCompletionChoice{{Message: openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Refusal: "I can't help with that."}}},
+	}}}}
+	model := &OpenAIModel{client: client, model: "test-model", transport: TransportJSONSchema, template: DefaultPromptTemplate(), maxAttempts: 1, sleep: noSleep}
+
+	_, err := model.ChooseOption(context.Background(), transportPrompt)
+	var refusal *RefusalError
+	if !errors.As(err, &refusal) || refusal.Reason != "I can't help with that." {
+		t.Fatalf("expected RefusalError, got %v", err)
+	}
+	if !errors.Is(err, ErrRefusal) {
+		t.Fatalf("expected ErrRefusal, got %v", err)
+	}
+}
+
+func TestParseErrorsAreInvalidResponses(t *testing.T) {
+	_, err := parseSelectionArgs(`{"other":"1"}`)
+	if !errors.Is(err, ErrInvalidResponse) || err.Error() != "selection payload missing selection field" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_, err = selectionResult("7", transportPrompt)
+	if !errors.Is(err, ErrInvalidResponse) {
+		t.Fatalf("expected ErrInvalidResponse for an out-of-range selection, got %v", err)
+	}
+}
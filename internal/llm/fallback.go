@@ -0,0 +1,177 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultBreakerThreshold = 3
+	DefaultBreakerCooldown  = time.Minute
+)
+
+// Backend is one named entry in a fallback chain.
+type Backend struct {
+	Name  string
+	Model Model
+}
+
+// BreakerConfig controls when a backend is taken out of rotation. After
+// Threshold consecutive outage errors (HTTP 429, 5xx or network failures)
+// the backend is skipped for Cooldown, then probed with a single request.
+type BreakerConfig struct {
+	Threshold int
+	Cooldown  time.Duration
+}
+
+// FallbackModel tries each backend in order until one answers.
+type FallbackModel struct {
+	backends []*breaker
+	now      func() time.Time
+}
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+type breaker struct {
+	Backend
+
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	state     breakerState
+	failures  int
+	openedAt  time.Time
+}
+
+func NewFallbackModel(backends []Backend, cfg BreakerConfig) (*FallbackModel, error) {
+	if len(backends) == 0 {
+		return nil, errors.New("fallback model needs at least one backend")
+	}
+	if cfg.Threshold <= 0 {
+		cfg.Threshold = DefaultBreakerThreshold
+	}
+	if cfg.Cooldown <= 0 {
+		cfg.Cooldown = DefaultBreakerCooldown
+	}
+	f := &FallbackModel{now: time.Now}
+	for i, b := range backends {
+		if b.Model == nil {
+			return nil, fmt.Errorf("backend %d has no model", i+1)
+		}
+		if strings.TrimSpace(b.Name) == "" {
+			b.Name = fmt.Sprintf("backend-%d", i+1)
+		}
+		f.backends = append(f.backends, &breaker{Backend: b, threshold: cfg.Threshold, cooldown: cfg.Cooldown})
+	}
+	return f, nil
+}
+
+func (f *FallbackModel) ChooseOption(ctx context.Context, prompt Prompt) (*Result, error) {
+	if f == nil {
+		return nil, errors.New("model is nil")
+	}
+	var errs []error
+	for _, b := range f.backends {
+		if !b.allow(f.now()) {
+			errs = append(errs, fmt.Errorf("%s: %w", b.Name, ErrCircuitOpen))
+			continue
+		}
+		result, err := b.Model.ChooseOption(ctx, prompt)
+		if err == nil {
+			b.success()
+			result.Backend = b.Name
+			if len(errs) > 0 {
+				result.Retries = append(RetriesOf(errors.Join(errs...)), result.Retries...)
+			}
+			return result, nil
+		}
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			// The caller gave up, which says nothing about the backend.
+			b.release()
+			return nil, err
+		}
+		if isOutage(err) {
+			b.failure(f.now())
+		} else {
+			// The backend answered, so it is reachable even if it
+			// rejected this request.
+			b.success()
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", b.Name, err))
+	}
+	if len(errs) == 1 {
+		return nil, errs[0]
+	}
+	return nil, fmt.Errorf("all backends failed: %w", errors.Join(errs...))
+}
+
+// allow reports whether a request may be sent. An open breaker whose
+// cooldown has elapsed lets exactly one probe through.
+func (b *breaker) allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case breakerOpen:
+		if now.Sub(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.state = breakerHalfOpen
+		return true
+	case breakerHalfOpen:
+		return false
+	default:
+		return true
+	}
+}
+
+// release gives back a probe that ended without an answer, so that the
+// next request probes the backend again instead of finding it half-open.
+func (b *breaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == breakerHalfOpen {
+		b.state = breakerOpen
+	}
+}
+
+func (b *breaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.state = breakerClosed
+	b.failures = 0
+}
+
+func (b *breaker) failure(now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	if b.state == breakerHalfOpen || b.failures >= b.threshold {
+		b.state = breakerOpen
+		b.openedAt = now
+	}
+}
+
+// isOutage reports whether err looks like the backend being unavailable
+// rather than the request being wrong.
+func isOutage(err error) bool {
+	var status interface{ StatusCode() int }
+	if errors.As(err, &status) {
+		return retryableHTTPStatus(status.StatusCode())
+	}
+	if shouldRetryCreateChatCompletion(err) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
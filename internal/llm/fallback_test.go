@@ -0,0 +1,129 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+func outageError(status int) error {
+	return describeCreateChatCompletionError(&openai.APIError{HTTPStatusCode: status, Message: "unavailable"})
+}
+
+func TestFallbackModelFailsOverOnOutage(t *testing.T) {
+	primary := &stubModel{name: "m", err: outageError(503)}
+	secondary := &stubModel{name: "m", index: routerIndex(0)}
+	fallback, err := NewFallbackModel([]Backend{{Name: "openai", Model: primary}, {Name: "azure", Model: secondary}}, BreakerConfig{})
+	if err != nil {
+		t.Fatalf("NewFallbackModel returned error: %v", err)
+	}
+	result, err := fallback.ChooseOption(context.Background(), Prompt{Options: make([]Option, 1)})
+	if err != nil {
+		t.Fatalf("ChooseOption returned error: %v", err)
+	}
+	if result.Backend != "azure" {
+		t.Fatalf("result backend = %q, want azure", result.Backend)
+	}
+}
+
+func TestFallbackModelOpensBreakerAndProbesAfterCooldown(t *testing.T) {
+	primary := &stubModel{name: "m", err: outageError(429)}
+	secondary := &stubModel{name: "m", index: routerIndex(0)}
+	fallback, err := NewFallbackModel([]Backend{{Name: "openai", Model: primary}, {Name: "azure", Model: secondary}}, BreakerConfig{Threshold: 2, Cooldown: time.Minute})
+	if err != nil {
+		t.Fatalf("NewFallbackModel returned error: %v", err)
+	}
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	fallback.now = func() time.Time { return now }
+
+	prompt := Prompt{Options: make([]Option, 1)}
+	for i := 0; i < 4; i++ {
+		if _, err := fallback.ChooseOption(context.Background(), prompt); err != nil {
+			t.Fatalf("ChooseOption returned error: %v", err)
+		}
+	}
+	if primary.calls != 2 {
+		t.Fatalf("primary calls = %d, want 2 before the breaker opens", primary.calls)
+	}
+
+	now = now.Add(2 * time.Minute)
+	primary.err = nil
+	primary.index = routerIndex(0)
+	result, err := fallback.ChooseOption(context.Background(), prompt)
+	if err != nil {
+		t.Fatalf("ChooseOption returned error: %v", err)
+	}
+	if primary.calls != 3 || result.Backend != "openai" {
+		t.Fatalf("expected probe to reach primary, calls=%d backend=%q", primary.calls, result.Backend)
+	}
+}
+
+func TestFallbackModelReopensAfterFailedProbe(t *testing.T) {
+	primary := &stubModel{name: "m", err: outageError(500)}
+	secondary := &stubModel{name: "m", index: routerIndex(0)}
+	fallback, err := NewFallbackModel([]Backend{{Name: "openai", Model: primary}, {Name: "azure", Model: secondary}}, BreakerConfig{Threshold: 1, Cooldown: time.Minute})
+	if err != nil {
+		t.Fatalf("NewFallbackModel returned error: %v", err)
+	}
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	fallback.now = func() time.Time { return now }
+	prompt := Prompt{Options: make([]Option, 1)}
+
+	_, _ = fallback.ChooseOption(context.Background(), prompt)
+	now = now.Add(2 * time.Minute)
+	_, _ = fallback.ChooseOption(context.Background(), prompt)
+	_, _ = fallback.ChooseOption(context.Background(), prompt)
+	if primary.calls != 2 {
+		t.Fatalf("primary calls = %d, want 2 (initial failure and one probe)", primary.calls)
+	}
+}
+
+func TestFallbackModelReleasesCancelledProbe(t *testing.T) {
+	primary := &stubModel{name: "m", err: outageError(500)}
+	secondary := &stubModel{name: "m", index: routerIndex(0)}
+	fallback, err := NewFallbackModel([]Backend{{Name: "openai", Model: primary}, {Name: "azure", Model: secondary}}, BreakerConfig{Threshold: 1, Cooldown: time.Minute})
+	if err != nil {
+		t.Fatalf("NewFallbackModel returned error: %v", err)
+	}
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	fallback.now = func() time.Time { return now }
+	prompt := Prompt{Options: make([]Option, 1)}
+
+	_, _ = fallback.ChooseOption(context.Background(), prompt)
+	now = now.Add(2 * time.Minute)
+	primary.err = context.Canceled
+	if _, err := fallback.ChooseOption(context.Background(), prompt); !errors.Is(err, context.Canceled) {
+		t.Fatalf("ChooseOption error = %v, want context.Canceled", err)
+	}
+
+	// The cancelled probe must not leave the breaker half-open for good.
+	primary.err = nil
+	primary.index = routerIndex(0)
+	result, err := fallback.ChooseOption(context.Background(), prompt)
+	if err != nil {
+		t.Fatalf("ChooseOption returned error: %v", err)
+	}
+	if primary.calls != 3 || result.Backend != "openai" {
+		t.Fatalf("expected a new probe to reach primary, calls=%d backend=%q", primary.calls, result.Backend)
+	}
+}
+
+func TestFallbackModelReportsAllFailures(t *testing.T) {
+	fallback, err := NewFallbackModel([]Backend{
+		{Name: "a", Model: &stubModel{err: outageError(503)}},
+		{Name: "b", Model: &stubModel{err: errors.New("boom")}},
+	}, BreakerConfig{})
+	if err != nil {
+		t.Fatalf("NewFallbackModel returned error: %v", err)
+	}
+	_, err = fallback.ChooseOption(context.Background(), Prompt{Options: make([]Option, 1)})
+	if err == nil {
+		t.Fatal("expected error when every backend fails")
+	}
+	if !isOutage(err) {
+		t.Fatalf("expected joined error to preserve the outage status: %v", err)
+	}
+}
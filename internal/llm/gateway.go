@@ -0,0 +1,166 @@
+package llm
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// DefaultAzureAPIVersion is the Azure OpenAI api-version used when
+// AzureConfig.APIVersion is empty.
+const DefaultAzureAPIVersion = "2024-10-21"
+
+// AzureConfig switches the OpenAI backend to Azure OpenAI, which addresses
+// models by deployment and authenticates with an api-key header.
+type AzureConfig struct {
+	APIVersion string
+	// Deployments maps model names to deployment names. Models without
+	// an entry are assumed to be deployed under their own name.
+	Deployments map[string]string
+}
+
+func (a AzureConfig) deployment(model string) string {
+	if d, ok := a.Deployments[model]; ok && d != "" {
+		return d
+	}
+	return model
+}
+
+// TLSConfig configures the client side of TLS connections to a gateway.
+type TLSConfig struct {
+	// CertFile and KeyFile hold a PEM client certificate and its key.
+	CertFile string
+	KeyFile  string
+	// CAFile holds PEM certificates trusted in addition to the system pool.
+	CAFile string
+}
+
+func (t TLSConfig) empty() bool {
+	return t.CertFile == "" && t.KeyFile == "" && t.CAFile == ""
+}
+
+func (t TLSConfig) build() (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		return nil, errors.New("a TLS client certificate needs both a certificate and a key file")
+	}
+	if t.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load TLS client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	if t.CAFile != "" {
+		data, err := os.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("CA bundle %s holds no PEM certificates", t.CAFile)
+		}
+		cfg.RootCAs = pool
+	}
+	return cfg, nil
+}
+
+// ParseHeader splits a "Name: value" or "Name=value" header specification
+// at whichever separator comes first, so values may contain either.
+func ParseHeader(spec string) (string, string, error) {
+	sep := strings.IndexAny(spec, ":=")
+	if sep < 0 {
+		return "", "", fmt.Errorf("invalid header %q (want Name: value)", spec)
+	}
+	name, value := strings.TrimSpace(spec[:sep]), spec[sep+1:]
+	if name == "" {
+		return "", "", fmt.Errorf("invalid header %q (want Name: value)", spec)
+	}
+	return http.CanonicalHeaderKey(name), strings.TrimSpace(value), nil
+}
+
+// ParseQueryParam splits a "name=value" query parameter specification.
+func ParseQueryParam(spec string) (string, string, error) {
+	name, value, ok := strings.Cut(spec, "=")
+	name = strings.TrimSpace(name)
+	if !ok || name == "" {
+		return "", "", fmt.Errorf("invalid query parameter %q (want name=value)", spec)
+	}
+	return name, strings.TrimSpace(value), nil
+}
+
+// gatewayTransport adds fixed headers and query parameters to every request.
+// Headers replace any value set by the client library, so a custom
+// Authorization header takes precedence over the API key. Without an API
+// key the client library still sends empty credentials, which are removed
+// so that a gateway authenticating by other headers does not reject them.
+type gatewayTransport struct {
+	base    http.RoundTripper
+	headers http.Header
+	query   map[string]string
+}
+
+func (t *gatewayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	clone := req.Clone(req.Context())
+	if auth := clone.Header.Get("Authorization"); auth != "" && strings.TrimSpace(strings.TrimPrefix(auth, "Bearer")) == "" {
+		clone.Header.Del("Authorization")
+	}
+	if values, ok := clone.Header["Api-Key"]; ok && strings.TrimSpace(strings.Join(values, "")) == "" {
+		clone.Header.Del("Api-Key")
+	}
+	for name, values := range t.headers {
+		clone.Header[name] = append([]string(nil), values...)
+	}
+	if len(t.query) > 0 {
+		q := clone.URL.Query()
+		for name, value := range t.query {
+			q.Set(name, value)
+		}
+		clone.URL.RawQuery = q.Encode()
+	}
+	return t.base.RoundTrip(clone)
+}
+
+// client returns the HTTP client for the configured gateway settings: the
+// WithHTTPClient client or a new one using the TLS settings, wrapped to add
+// headers and query parameters. It returns nil when nothing is configured.
+func (cfg config) client() (*http.Client, error) {
+	client := cfg.httpClient
+	if !cfg.tls.empty() {
+		tlsCfg, err := cfg.tls.build()
+		if err != nil {
+			return nil, err
+		}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = tlsCfg
+		if client == nil {
+			client = &http.Client{}
+		} else {
+			copied := *client
+			client = &copied
+		}
+		client.Transport = transport
+	}
+	if len(cfg.headers) == 0 && len(cfg.query) == 0 {
+		return client, nil
+	}
+	if client == nil {
+		client = &http.Client{}
+	} else {
+		copied := *client
+		client = &copied
+	}
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	client.Transport = &gatewayTransport{base: base, headers: cfg.headers, query: cfg.query}
+	return client, nil
+}
@@ -0,0 +1,250 @@
+package llm
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const gatewayReply = `{"id":"x","object":"chat.completion","choices":[{"index":0,"message":{"role":"assistant","tool_calls":[{"id":"call_1","type":"function","function":{"name":"select_taxonomy_category","arguments":"{\"selection\":\"2\"}"}}]}}],"usage":{"prompt_tokens":5,"total_tokens":5}}`
+
+// writeClientCert writes a self-signed client certificate and key to dir
+// and returns their paths along with the certificate.
+func writeClientCert(t *testing.T, dir string) (string, string, *x509.Certificate) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "taxowalk-test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPath := filepath.Join(dir, "client.crt")
+	keyPath := filepath.Join(dir, "client.key")
+	if err := os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certPath, keyPath, cert
+}
+
+// newGatewayServer starts a TLS server that requires clientCert and hands
+// every request to handler. It returns the server and a CA bundle trusting it.
+func newGatewayServer(t *testing.T, dir string, clientCert *x509.Certificate, handler http.HandlerFunc) (*httptest.Server, string) {
+	t.Helper()
+	srv := httptest.NewUnstartedServer(handler)
+	pool := x509.NewCertPool()
+	pool.AddCert(clientCert)
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: pool}
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	caPath := filepath.Join(dir, "ca.pem")
+	if err := os.WriteFile(caPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return srv, caPath
+}
+
+func TestOpenAIModelAzureOverMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	certPath, keyPath, clientCert := writeClientCert(t, dir)
+	var gotPath, gotVersion, gotKey, gotAuth, gotTenant, gotBody string
+	srv, caPath := newGatewayServer(t, dir, clientCert, func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotVersion = r.URL.Query().Get("api-version")
+		gotKey = r.Header.Get("api-key")
+		gotAuth = r.Header.Get("Authorization")
+		gotTenant = r.URL.Query().Get("tenant")
+		body, _ := io.ReadAll(r.Body)
+		gotBody = string(body)
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, gatewayReply)
+	})
+
+	model, err := NewOpenAIModel("azure-key",
+		WithBaseURL(srv.URL),
+		WithModel("gpt-4.1-mini"),
+		WithAzure(AzureConfig{APIVersion: "2025-01-01-preview", Deployments: map[string]string{"gpt-4.1-mini": "prod-mini"}}),
+		WithTransport(TransportTool),
+		WithQueryParam("tenant", "acme"),
+		WithTLS(TLSConfig{CertFile: certPath, KeyFile: keyPath, CAFile: caPath}),
+	)
+	if err != nil {
+		t.Fatalf("NewOpenAIModel: %v", err)
+	}
+	res, err := model.ChooseOption(context.Background(), transportPrompt)
+	if err != nil {
+		t.Fatalf("ChooseOption: %v", err)
+	}
+	if res.Choice != "aa-2" {
+		t.Fatalf("unexpected result %+v", res)
+	}
+	if gotPath != "/openai/deployments/prod-mini/chat/completions" {
+		t.Errorf("path = %q", gotPath)
+	}
+	if gotVersion != "2025-01-01-preview" {
+		t.Errorf("api-version = %q", gotVersion)
+	}
+	if gotKey != "azure-key" || gotAuth != "" {
+		t.Errorf("api-key = %q, Authorization = %q", gotKey, gotAuth)
+	}
+	if gotTenant != "acme" {
+		t.Errorf("tenant = %q", gotTenant)
+	}
+	if !strings.Contains(gotBody, `"model":"gpt-4.1-mini"`) {
+		t.Errorf("request body lost the model name: %s", gotBody)
+	}
+}
+
+func TestOpenAIModelGatewayHeadersReplaceKey(t *testing.T) {
+	var gotAuth, gotTeam string
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		gotTeam = r.Header.Get("X-Team")
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, gatewayReply)
+	}))
+	defer srv.Close()
+
+	model, err := NewOpenAIModel("",
+		WithBaseURL(srv.URL+"/v1"),
+		WithHTTPClient(srv.Client()),
+		WithTransport(TransportTool),
+		WithHeader("Authorization", "Bearer gateway-token"),
+		WithHeader("X-Team", "catalog"),
+	)
+	if err != nil {
+		t.Fatalf("NewOpenAIModel: %v", err)
+	}
+	if _, err := model.ChooseOption(context.Background(), transportPrompt); err != nil {
+		t.Fatalf("ChooseOption: %v", err)
+	}
+	if gotAuth != "Bearer gateway-token" || gotTeam != "catalog" {
+		t.Fatalf("Authorization = %q, X-Team = %q", gotAuth, gotTeam)
+	}
+}
+
+func TestOpenAIModelHeaderAuthSendsNoEmptyKey(t *testing.T) {
+	var auth []string
+	var sawAuth bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth, sawAuth = r.Header["Authorization"]
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, gatewayReply)
+	}))
+	defer srv.Close()
+
+	model, err := NewOpenAIModel("",
+		WithBaseURL(srv.URL+"/v1"),
+		WithTransport(TransportTool),
+		WithHeader("X-Gateway-Key", "secret"),
+	)
+	if err != nil {
+		t.Fatalf("NewOpenAIModel: %v", err)
+	}
+	if _, err := model.ChooseOption(context.Background(), transportPrompt); err != nil {
+		t.Fatalf("ChooseOption: %v", err)
+	}
+	if sawAuth {
+		t.Fatalf("Authorization = %q, want no header without an API key", auth)
+	}
+}
+
+func TestTLSConfigRejectsUnknownServer(t *testing.T) {
+	dir := t.TempDir()
+	certPath, keyPath, clientCert := writeClientCert(t, dir)
+	srv, _ := newGatewayServer(t, dir, clientCert, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, gatewayReply)
+	})
+	model, err := NewOpenAIModel("key",
+		WithBaseURL(srv.URL),
+		WithTransport(TransportTool),
+		WithTLS(TLSConfig{CertFile: certPath, KeyFile: keyPath}),
+	)
+	if err != nil {
+		t.Fatalf("NewOpenAIModel: %v", err)
+	}
+	model.sleep = noSleep
+	if _, err := model.ChooseOption(context.Background(), transportPrompt); err == nil {
+		t.Fatal("expected certificate verification to fail without the CA bundle")
+	}
+}
+
+func TestTLSConfigValidation(t *testing.T) {
+	if _, err := NewOllamaModel(WithTLS(TLSConfig{CertFile: "client.crt"})); err == nil {
+		t.Error("expected an error for a certificate without a key")
+	}
+	bundle := filepath.Join(t.TempDir(), "empty.pem")
+	if err := os.WriteFile(bundle, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewAnthropicModel("key", WithTLS(TLSConfig{CAFile: bundle})); err == nil {
+		t.Error("expected an error for a CA bundle without certificates")
+	}
+}
+
+func TestParseHeaderAndQueryParam(t *testing.T) {
+	name, value, err := ParseHeader("x-api-key: secret: with colon")
+	if err != nil || name != "X-Api-Key" || value != "secret: with colon" {
+		t.Fatalf("ParseHeader = %q, %q, %v", name, value, err)
+	}
+	// The first separator wins, so values may contain the other one.
+	for spec, want := range map[string][2]string{
+		"X-Token=a:b":        {"X-Token", "a:b"},
+		"X-Token: a=b":       {"X-Token", "a=b"},
+		"x-sig=k=v:w":        {"X-Sig", "k=v:w"},
+		"Authorization: a:b": {"Authorization", "a:b"},
+	} {
+		name, value, err := ParseHeader(spec)
+		if err != nil || name != want[0] || value != want[1] {
+			t.Errorf("ParseHeader(%q) = %q, %q, %v; want %q, %q", spec, name, value, err, want[0], want[1])
+		}
+	}
+	if _, _, err := ParseHeader("novalue"); err == nil {
+		t.Error("expected an error for a header without a value separator")
+	}
+	name, value, err = ParseQueryParam("api-version=2024-10-21")
+	if err != nil || name != "api-version" || value != "2024-10-21" {
+		t.Fatalf("ParseQueryParam = %q, %q, %v", name, value, err)
+	}
+	if _, _, err := ParseQueryParam("=x"); err == nil {
+		t.Error("expected an error for an empty parameter name")
+	}
+}
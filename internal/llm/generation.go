@@ -0,0 +1,264 @@
+package llm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// GenerationSettings are the sampling and reasoning parameters sent with
+// every request. Unset fields are left to the provider's defaults.
+type GenerationSettings struct {
+	Temperature         *float64 `json:"temperature,omitempty"`
+	TopP                *float64 `json:"top_p,omitempty"`
+	MaxCompletionTokens int      `json:"max_completion_tokens,omitempty"`
+	ReasoningEffort     string   `json:"reasoning_effort,omitempty"`
+	Seed                *int     `json:"seed,omitempty"`
+	ServiceTier         string   `json:"service_tier,omitempty"`
+	// Extra holds additional top-level request fields merged into the
+	// JSON body as-is, for parameters this package does not model.
+	Extra map[string]any `json:"extra,omitempty"`
+}
+
+var (
+	reasoningEfforts = []string{"none", "minimal", "low", "medium", "high", "xhigh"}
+	serviceTiers     = []string{"auto", "default", "flex", "scale", "priority"}
+)
+
+// Validate checks that every set field holds a value some provider accepts.
+func (s GenerationSettings) Validate() error {
+	if s.Temperature != nil && (*s.Temperature < 0 || *s.Temperature > 2) {
+		return fmt.Errorf("temperature %g is outside [0, 2]", *s.Temperature)
+	}
+	if s.TopP != nil && (*s.TopP < 0 || *s.TopP > 1) {
+		return fmt.Errorf("top_p %g is outside [0, 1]", *s.TopP)
+	}
+	if s.MaxCompletionTokens < 0 {
+		return fmt.Errorf("max completion tokens %d is negative", s.MaxCompletionTokens)
+	}
+	if s.ReasoningEffort != "" && !containsString(reasoningEfforts, s.ReasoningEffort) {
+		return fmt.Errorf("unknown reasoning effort %q (want %s)", s.ReasoningEffort, strings.Join(reasoningEfforts, ", "))
+	}
+	if s.ServiceTier != "" && !containsString(serviceTiers, s.ServiceTier) {
+		return fmt.Errorf("unknown service tier %q (want %s)", s.ServiceTier, strings.Join(serviceTiers, ", "))
+	}
+	return nil
+}
+
+// Merge returns s with every field that is set in other replacing its own.
+// Extra fields are merged key by key.
+func (s GenerationSettings) Merge(other GenerationSettings) GenerationSettings {
+	if other.Temperature != nil {
+		s.Temperature = other.Temperature
+	}
+	if other.TopP != nil {
+		s.TopP = other.TopP
+	}
+	if other.MaxCompletionTokens != 0 {
+		s.MaxCompletionTokens = other.MaxCompletionTokens
+	}
+	if other.ReasoningEffort != "" {
+		s.ReasoningEffort = other.ReasoningEffort
+	}
+	if other.Seed != nil {
+		s.Seed = other.Seed
+	}
+	if other.ServiceTier != "" {
+		s.ServiceTier = other.ServiceTier
+	}
+	if len(other.Extra) > 0 {
+		extra := make(map[string]any, len(s.Extra)+len(other.Extra))
+		for k, v := range s.Extra {
+			extra[k] = v
+		}
+		for k, v := range other.Extra {
+			extra[k] = v
+		}
+		s.Extra = extra
+	}
+	return s
+}
+
+// isReasoningModel reports whether name belongs to an OpenAI reasoning
+// family, which rejects sampling parameters other than their defaults.
+// The families match go-openai's ReasoningValidator, which also covers
+// the gpt-5 chat models. Gateway prefixes such as "openai/" are ignored.
+func isReasoningModel(name string) bool {
+	name = modelBase(name)
+	for _, prefix := range []string{"gpt-5", "o1", "o3", "o4"} {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// acceptsReasoningEffort reports whether name takes reasoning_effort; the
+// gpt-5 chat models do not, although their sampling is fixed.
+func acceptsReasoningEffort(name string) bool {
+	return isReasoningModel(name) && !strings.Contains(modelBase(name), "-chat")
+}
+
+// modelBase lowercases name and strips gateway prefixes such as "openai/".
+func modelBase(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
+
+// forOpenAI drops the parameters model is known to reject and returns the
+// names of the dropped parameters.
+func (s GenerationSettings) forOpenAI(model string) (GenerationSettings, []string) {
+	var dropped []string
+	if isReasoningModel(model) {
+		if s.Temperature != nil && *s.Temperature != 1 {
+			s.Temperature = nil
+			dropped = append(dropped, "temperature")
+		}
+		if s.TopP != nil && *s.TopP != 1 {
+			s.TopP = nil
+			dropped = append(dropped, "top_p")
+		}
+	}
+	if !acceptsReasoningEffort(model) && s.ReasoningEffort != "" {
+		s.ReasoningEffort = ""
+		dropped = append(dropped, "reasoning_effort")
+	}
+	return s, dropped
+}
+
+// forAnthropic keeps the parameters the Messages API understands. Recent
+// Claude models reject temperature and top_p together, so top_p yields.
+func (s GenerationSettings) forAnthropic() (GenerationSettings, []string) {
+	var dropped []string
+	if s.ReasoningEffort != "" {
+		s.ReasoningEffort = ""
+		dropped = append(dropped, "reasoning_effort")
+	}
+	if s.Seed != nil {
+		s.Seed = nil
+		dropped = append(dropped, "seed")
+	}
+	if s.ServiceTier != "" {
+		s.ServiceTier = ""
+		dropped = append(dropped, "service_tier")
+	}
+	if s.Temperature != nil && s.TopP != nil {
+		s.TopP = nil
+		dropped = append(dropped, "top_p")
+	}
+	return s, dropped
+}
+
+// forOllama keeps the parameters Ollama exposes as model options.
+func (s GenerationSettings) forOllama() (GenerationSettings, []string) {
+	var dropped []string
+	if s.ReasoningEffort != "" {
+		s.ReasoningEffort = ""
+		dropped = append(dropped, "reasoning_effort")
+	}
+	if s.ServiceTier != "" {
+		s.ServiceTier = ""
+		dropped = append(dropped, "service_tier")
+	}
+	return s, dropped
+}
+
+// applyOpenAI copies the settings onto req. Zero temperature and top_p
+// cannot be expressed by the client library, which omits zero values, so
+// they are returned as extra body fields instead.
+func (s GenerationSettings) applyOpenAI(req *openai.ChatCompletionRequest) {
+	if s.Temperature != nil {
+		req.Temperature = float32(*s.Temperature)
+	}
+	if s.TopP != nil {
+		req.TopP = float32(*s.TopP)
+	}
+	req.MaxCompletionTokens = s.MaxCompletionTokens
+	req.ReasoningEffort = s.ReasoningEffort
+	req.Seed = s.Seed
+	req.ServiceTier = openai.ServiceTier(s.ServiceTier)
+}
+
+// openAIExtra returns the body fields applyOpenAI cannot set.
+func (s GenerationSettings) openAIExtra() map[string]any {
+	extra := make(map[string]any, len(s.Extra)+2)
+	if s.Temperature != nil && *s.Temperature == 0 {
+		extra["temperature"] = 0
+	}
+	if s.TopP != nil && *s.TopP == 0 {
+		extra["top_p"] = 0
+	}
+	for k, v := range s.Extra {
+		extra[k] = v
+	}
+	return extra
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// extraFieldsTransport merges fixed fields into the JSON body of POST
+// requests whose path ends in pathSuffix.
+type extraFieldsTransport struct {
+	base       http.RoundTripper
+	pathSuffix string
+	fields     map[string]any
+}
+
+// withExtraFields returns a client that adds fields to matching requests,
+// or client itself when there is nothing to add.
+func withExtraFields(client *http.Client, pathSuffix string, fields map[string]any) *http.Client {
+	if len(fields) == 0 {
+		return client
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	wrapped := *client
+	wrapped.Transport = &extraFieldsTransport{base: base, pathSuffix: pathSuffix, fields: fields}
+	return &wrapped
+}
+
+func (t *extraFieldsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodPost || req.Body == nil || !strings.HasSuffix(req.URL.Path, t.pathSuffix) {
+		return t.base.RoundTrip(req)
+	}
+	data, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	var body map[string]any
+	if err := json.Unmarshal(data, &body); err != nil {
+		return nil, fmt.Errorf("failed to add extra request fields: %w", err)
+	}
+	for k, v := range t.fields {
+		body[k] = v
+	}
+	if data, err = json.Marshal(body); err != nil {
+		return nil, fmt.Errorf("failed to add extra request fields: %w", err)
+	}
+	clone := req.Clone(req.Context())
+	clone.Body = io.NopCloser(bytes.NewReader(data))
+	clone.ContentLength = int64(len(data))
+	clone.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(data)), nil }
+	return t.base.RoundTrip(clone)
+}
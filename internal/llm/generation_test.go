@@ -0,0 +1,129 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestIsReasoningModel(t *testing.T) {
+	tests := map[string]bool{
+		"gpt-5.4-mini":       true,
+		"openai/gpt-5":       true,
+		"o3-mini":            true,
+		"o4-mini":            true,
+		"gpt-5-chat-latest":  true,
+		"gpt-4.1":            false,
+		"gpt-4o-mini":        false,
+		"llama-3.1-70b":      false,
+		"azure/o1-preview":   true,
+		"claude-haiku-4-5":   false,
+		"mistral-large-2411": false,
+	}
+	for name, want := range tests {
+		if got := isReasoningModel(name); got != want {
+			t.Errorf("isReasoningModel(%q) = %v, want %v", name, got, want)
+		}
+	}
+	if acceptsReasoningEffort("gpt-5-chat-latest") || !acceptsReasoningEffort("gpt-5.4-mini") {
+		t.Error("only the gpt-5 reasoning models should take reasoning_effort")
+	}
+}
+
+func TestGenerationSettingsValidate(t *testing.T) {
+	hot, wide := 2.5, 1.5
+	for name, s := range map[string]GenerationSettings{
+		"temperature": {Temperature: &hot},
+		"top_p":       {TopP: &wide},
+		"tokens":      {MaxCompletionTokens: -1},
+		"effort":      {ReasoningEffort: "extreme"},
+		"tier":        {ServiceTier: "gold"},
+	} {
+		if err := s.Validate(); err == nil {
+			t.Errorf("%s: expected validation error", name)
+		}
+	}
+	if err := (GenerationSettings{ReasoningEffort: "low", ServiceTier: "flex"}).Validate(); err != nil {
+		t.Fatalf("Validate returned error: %v", err)
+	}
+}
+
+func TestOpenAIModelAppliesGenerationSettings(t *testing.T) {
+	var bodies []map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		bodies = append(bodies, body)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"choices":[{"message":{"role":"assistant","tool_calls":[{"id":"call_1","type":"function","function":{"name":"` + selectionToolName + `","arguments":"{\"selection\":\"1\"}"}}]}}]}`))
+	}))
+	defer server.Close()
+
+	zero, warm, seed := 0.0, 0.7, 42
+	settings := GenerationSettings{
+		Temperature:         &zero,
+		MaxCompletionTokens: 64,
+		ReasoningEffort:     "low",
+		Seed:                &seed,
+		ServiceTier:         "flex",
+		Extra:               map[string]any{"user": "batch-7"},
+	}
+	tests := []struct {
+		model       string
+		settings    GenerationSettings
+		wantDropped []string
+		want        map[string]any
+		absent      []string
+	}{
+		{
+			model:       "gpt-4.1",
+			settings:    settings,
+			wantDropped: []string{"reasoning_effort"},
+			want:        map[string]any{"temperature": 0.0, "max_completion_tokens": 64.0, "seed": 42.0, "service_tier": "flex", "user": "batch-7"},
+			absent:      []string{"reasoning_effort"},
+		},
+		{
+			model:       "gpt-5.4-mini",
+			settings:    settings.Merge(GenerationSettings{Temperature: &warm}),
+			wantDropped: []string{"temperature"},
+			want:        map[string]any{"reasoning_effort": "low", "user": "batch-7"},
+			absent:      []string{"temperature"},
+		},
+		{
+			// go-openai rejects sampling changes for every gpt-5 model.
+			model:       "gpt-5-chat-latest",
+			settings:    settings.Merge(GenerationSettings{Temperature: &warm}),
+			wantDropped: []string{"temperature", "reasoning_effort"},
+			want:        map[string]any{"user": "batch-7"},
+			absent:      []string{"temperature", "reasoning_effort"},
+		},
+	}
+	for _, tt := range tests {
+		model, err := NewOpenAIModel("test-key", WithBaseURL(server.URL+"/v1"), WithModel(tt.model), WithGeneration(tt.settings), WithTransport(TransportTool))
+		if err != nil {
+			t.Fatalf("%s: NewOpenAIModel returned error: %v", tt.model, err)
+		}
+		if !reflect.DeepEqual(model.DroppedSettings(), tt.wantDropped) {
+			t.Errorf("%s: dropped = %v, want %v", tt.model, model.DroppedSettings(), tt.wantDropped)
+		}
+		if _, err := model.ChooseOption(context.Background(), transportPrompt); err != nil {
+			t.Fatalf("%s: ChooseOption returned error: %v", tt.model, err)
+		}
+		body := bodies[len(bodies)-1]
+		for k, v := range tt.want {
+			if body[k] != v {
+				t.Errorf("%s: body[%q] = %#v, want %#v", tt.model, k, body[k], v)
+			}
+		}
+		for _, k := range tt.absent {
+			if _, ok := body[k]; ok {
+				t.Errorf("%s: body unexpectedly has %q", tt.model, k)
+			}
+		}
+	}
+}
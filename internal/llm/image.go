@@ -0,0 +1,156 @@
+package llm
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	DefaultImageMaxDimension = 1024
+	DefaultImageMaxBytes     = 20 << 20
+	imageJPEGQuality         = 85
+)
+
+// Image is a product photo attached to a prompt. URL is either a remote
+// http(s) URL or a data: URL holding the encoded image bytes.
+type Image struct {
+	URL string
+}
+
+// ImageOptions controls how local image files are prepared before upload.
+type ImageOptions struct {
+	MaxDimension int
+	MaxBytes     int64
+}
+
+// LoadImage turns a file path or URL into an Image. Remote URLs are passed
+// through untouched; local files are downscaled so that their longest side
+// fits MaxDimension, checked against MaxBytes, and embedded as a data URL.
+func LoadImage(source string, opts ImageOptions) (Image, error) {
+	source = strings.TrimSpace(source)
+	if source == "" {
+		return Image{}, errors.New("image source is empty")
+	}
+	lower := strings.ToLower(source)
+	if strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://") || strings.HasPrefix(lower, "data:") {
+		return Image{URL: source}, nil
+	}
+	if opts.MaxDimension <= 0 {
+		opts.MaxDimension = DefaultImageMaxDimension
+	}
+	if opts.MaxBytes <= 0 {
+		opts.MaxBytes = DefaultImageMaxBytes
+	}
+
+	path := filepath.Clean(strings.TrimPrefix(source, "file://"))
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Image{}, err
+	}
+	return encodeImage(path, data, opts)
+}
+
+// encodeImage embeds data as sent when it already fits both limits, and
+// otherwise downscales and re-encodes it. The byte limit applies to what
+// is sent, so a large photo is accepted if it fits once downscaled.
+func encodeImage(name string, data []byte, opts ImageOptions) (Image, error) {
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return Image{}, fmt.Errorf("failed to decode image %s: %w", name, err)
+	}
+
+	bounds := img.Bounds()
+	if bounds.Dx() <= opts.MaxDimension && bounds.Dy() <= opts.MaxDimension && int64(len(data)) <= opts.MaxBytes {
+		switch format {
+		case "jpeg", "png", "gif":
+			return Image{URL: dataURL("image/"+format, data)}, nil
+		}
+	}
+
+	resized := downscale(img, opts.MaxDimension)
+	buf := &bytes.Buffer{}
+	mimeType := "image/jpeg"
+	if format == "png" || format == "gif" {
+		mimeType = "image/png"
+		err = png.Encode(buf, resized)
+	} else {
+		err = jpeg.Encode(buf, resized, &jpeg.Options{Quality: imageJPEGQuality})
+	}
+	if err != nil {
+		return Image{}, fmt.Errorf("failed to encode image %s: %w", name, err)
+	}
+	if int64(buf.Len()) > opts.MaxBytes {
+		return Image{}, fmt.Errorf("image %s is %d bytes after downscaling to %d pixels, exceeding the %d byte limit", name, buf.Len(), opts.MaxDimension, opts.MaxBytes)
+	}
+	return Image{URL: dataURL(mimeType, buf.Bytes())}, nil
+}
+
+func dataURL(mimeType string, data []byte) string {
+	return "data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(data)
+}
+
+// downscale shrinks img so that neither side exceeds maxDim, averaging the
+// source pixels that fall into each destination pixel.
+func downscale(img image.Image, maxDim int) image.Image {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if maxDim <= 0 || (w <= maxDim && h <= maxDim) || w == 0 || h == 0 {
+		return img
+	}
+	dw, dh := maxDim, maxDim
+	if w >= h {
+		dh = h * maxDim / w
+	} else {
+		dw = w * maxDim / h
+	}
+	if dw < 1 {
+		dw = 1
+	}
+	if dh < 1 {
+		dh = 1
+	}
+
+	src := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(src, src.Bounds(), img, bounds.Min, draw.Src)
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		y0 := y * h / dh
+		y1 := (y + 1) * h / dh
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < dw; x++ {
+			x0 := x * w / dw
+			x1 := (x + 1) * w / dw
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				off := src.PixOffset(x0, sy)
+				for sx := x0; sx < x1; sx++ {
+					r += uint64(src.Pix[off])
+					g += uint64(src.Pix[off+1])
+					b += uint64(src.Pix[off+2])
+					a += uint64(src.Pix[off+3])
+					off += 4
+					n++
+				}
+			}
+			dst.SetRGBA(x, y, color.RGBA{R: uint8(r / n), G: uint8(g / n), B: uint8(b / n), A: uint8(a / n)})
+		}
+	}
+	return dst
+}
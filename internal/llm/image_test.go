@@ -0,0 +1,112 @@
+package llm
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTestPNG(t *testing.T, w, h int) string {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 200, A: 255})
+		}
+	}
+	buf := &bytes.Buffer{}
+	if err := png.Encode(buf, img); err != nil {
+		t.Fatalf("png.Encode returned error: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "product.png")
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatalf("failed to write image: %v", err)
+	}
+	return path
+}
+
+func decodeDataURL(t *testing.T, url string) image.Image {
+	t.Helper()
+	idx := strings.Index(url, ";base64,")
+	if !strings.HasPrefix(url, "data:image/") || idx < 0 {
+		t.Fatalf("unexpected data URL prefix: %.40s", url)
+	}
+	data, err := base64.StdEncoding.DecodeString(url[idx+len(";base64,"):])
+	if err != nil {
+		t.Fatalf("failed to decode base64 payload: %v", err)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("failed to decode image payload: %v", err)
+	}
+	return img
+}
+
+func TestLoadImagePassesThroughURLs(t *testing.T) {
+	for _, src := range []string{"https://example.com/a.jpg", "http://example.com/b.png", "data:image/png;base64,AAAA"} {
+		img, err := LoadImage(src, ImageOptions{})
+		if err != nil {
+			t.Fatalf("LoadImage(%q) returned error: %v", src, err)
+		}
+		if img.URL != src {
+			t.Fatalf("LoadImage(%q) URL = %q", src, img.URL)
+		}
+	}
+}
+
+func TestLoadImageDownscalesLargeFiles(t *testing.T) {
+	path := writeTestPNG(t, 200, 100)
+	img, err := LoadImage(path, ImageOptions{MaxDimension: 50})
+	if err != nil {
+		t.Fatalf("LoadImage returned error: %v", err)
+	}
+	if !strings.HasPrefix(img.URL, "data:image/png;base64,") {
+		t.Fatalf("unexpected URL prefix: %.40s", img.URL)
+	}
+	decoded := decodeDataURL(t, img.URL)
+	if got := decoded.Bounds(); got.Dx() != 50 || got.Dy() != 25 {
+		t.Fatalf("downscaled bounds = %v, want 50x25", got)
+	}
+}
+
+func TestLoadImageKeepsSmallFiles(t *testing.T) {
+	path := writeTestPNG(t, 20, 10)
+	img, err := LoadImage(path, ImageOptions{MaxDimension: 50})
+	if err != nil {
+		t.Fatalf("LoadImage returned error: %v", err)
+	}
+	decoded := decodeDataURL(t, img.URL)
+	if got := decoded.Bounds(); got.Dx() != 20 || got.Dy() != 10 {
+		t.Fatalf("bounds = %v, want 20x10", got)
+	}
+}
+
+func TestLoadImageAppliesByteLimitAfterDownscaling(t *testing.T) {
+	path := writeTestPNG(t, 400, 400)
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The file is over the limit, but its 20x20 downscaled copy is not.
+	limit := info.Size() / 2
+	img, err := LoadImage(path, ImageOptions{MaxDimension: 20, MaxBytes: limit})
+	if err != nil {
+		t.Fatalf("LoadImage returned error: %v", err)
+	}
+	if got := decodeDataURL(t, img.URL).Bounds(); got.Dx() != 20 || got.Dy() != 20 {
+		t.Fatalf("bounds = %v, want 20x20", got)
+	}
+}
+
+func TestLoadImageRejectsOversizedFiles(t *testing.T) {
+	path := writeTestPNG(t, 20, 10)
+	if _, err := LoadImage(path, ImageOptions{MaxBytes: 10}); err == nil {
+		t.Fatal("expected error for file over the byte limit")
+	}
+}
@@ -6,24 +6,79 @@ type Option struct {
 	Name     string
 	FullName string
 	ID       string
+	// Description and Aliases help the model tell similar options apart.
+	Description string
+	Aliases     []string
 }
 
 type Prompt struct {
 	Description string
 	Path        []string
 	Options     []Option
+	Images      []Image
+	Locale      string
+	Taxonomy    string
+	Examples    []Example
 }
 
 type Usage struct {
-	PromptTokens     int
-	CompletionTokens int
-	TotalTokens      int
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+	// CachedPromptTokens and CacheWriteTokens are the parts of
+	// PromptTokens read from and written to the provider's prompt cache.
+	CachedPromptTokens int `json:"cached_prompt_tokens,omitempty"`
+	CacheWriteTokens   int `json:"cache_write_tokens,omitempty"`
+	// ReasoningTokens is the part of CompletionTokens spent on hidden
+	// reasoning by models that report it.
+	ReasoningTokens int `json:"reasoning_tokens,omitempty"`
+}
+
+// Add returns the element-wise sum of u and other.
+func (u Usage) Add(other Usage) Usage {
+	return Usage{
+		PromptTokens:       u.PromptTokens + other.PromptTokens,
+		CompletionTokens:   u.CompletionTokens + other.CompletionTokens,
+		TotalTokens:        u.TotalTokens + other.TotalTokens,
+		CachedPromptTokens: u.CachedPromptTokens + other.CachedPromptTokens,
+		CacheWriteTokens:   u.CacheWriteTokens + other.CacheWriteTokens,
+		ReasoningTokens:    u.ReasoningTokens + other.ReasoningTokens,
+	}
 }
 
 type Result struct {
 	Choice      string
 	ChoiceIndex *int
 	Usage       Usage
+	// Model names the backing model that produced Choice.
+	Model string
+	// Backend names the provider or endpoint that answered, when the
+	// model was reached through a FallbackModel.
+	Backend string
+	// UsageByModel splits Usage across every model consulted for this
+	// result. It is nil when a single model answered.
+	UsageByModel map[string]Usage
+	// PromptVersion identifies the prompt template that produced the
+	// request; see PromptTemplate.Version.
+	PromptVersion string
+	// PromptFingerprint is a hash of the rendered request messages and
+	// images. Identical inputs always render identical requests, so equal
+	// fingerprints mark requests that can be replayed or cached.
+	PromptFingerprint string
+	// Retries lists the failed requests that were retried before the
+	// answer arrived.
+	Retries []Retry
+}
+
+// ModelUsage returns the per-model breakdown of the result's token usage.
+func (r *Result) ModelUsage() map[string]Usage {
+	if r == nil {
+		return nil
+	}
+	if r.UsageByModel != nil {
+		return r.UsageByModel
+	}
+	return map[string]Usage{r.Model: r.Usage}
 }
 
 type Model interface {
@@ -0,0 +1,314 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultOllamaModel is the local model used when WithModel is not
+	// supplied.
+	DefaultOllamaModel   = "llama3.1:8b"
+	defaultOllamaBaseURL = "http://localhost:11434"
+)
+
+// OllamaModel implements Model against Ollama's native /api/chat endpoint,
+// constraining the reply with a JSON schema instead of tool calling.
+type OllamaModel struct {
+	baseURL        string
+	model          string
+	httpClient     *http.Client
+	template       *PromptTemplate
+	generation     GenerationSettings
+	dropped        []string
+	maxAttempts    int
+	retryBaseDelay time.Duration
+	retryMaxDelay  time.Duration
+	limiter        *rateLimiter
+	sleep          func(ctx context.Context, d time.Duration) error
+}
+
+// NewOllamaModel creates a model for a local Ollama server. Without
+// WithBaseURL the OLLAMA_HOST environment variable is honoured before
+// falling back to http://localhost:11434.
+func NewOllamaModel(opts ...OptionFunc) (*OllamaModel, error) {
+	cfg := newConfig(DefaultOllamaModel, opts)
+	if err := cfg.generation.Validate(); err != nil {
+		return nil, err
+	}
+	generation, dropped := cfg.generation.forOllama()
+	baseURL := cfg.baseURL
+	if baseURL == "" {
+		baseURL = strings.TrimSpace(os.Getenv("OLLAMA_HOST"))
+	}
+	if baseURL == "" {
+		baseURL = defaultOllamaBaseURL
+	}
+	if !strings.Contains(baseURL, "://") {
+		baseURL = "http://" + baseURL
+	}
+	httpClient, err := cfg.client()
+	if err != nil {
+		return nil, err
+	}
+	retry := cfg.retry.withDefaults()
+	limiter := defaultRateLimiters.get(baseURL, "", cfg.headers)
+	client := withExtraFields(withRateLimits(httpClient, limiter), "/api/chat", generation.Extra)
+	return &OllamaModel{
+		baseURL:        strings.TrimRight(baseURL, "/"),
+		model:          cfg.model,
+		httpClient:     client,
+		template:       cfg.template,
+		generation:     generation,
+		dropped:        dropped,
+		maxAttempts:    retry.MaxAttempts,
+		retryBaseDelay: retry.BaseDelay,
+		retryMaxDelay:  retry.MaxDelay,
+		limiter:        limiter,
+		sleep:          sleepWithContext,
+	}, nil
+}
+
+// Name returns the model name used for requests.
+func (m *OllamaModel) Name() string {
+	if m == nil {
+		return ""
+	}
+	return m.model
+}
+
+type ollamaMessage struct {
+	Role    string   `json:"role"`
+	Content string   `json:"content"`
+	Images  []string `json:"images,omitempty"`
+}
+
+type ollamaChatRequest struct {
+	Model    string          `json:"model"`
+	Messages []ollamaMessage `json:"messages"`
+	Stream   bool            `json:"stream"`
+	Format   map[string]any  `json:"format,omitempty"`
+	Options  map[string]any  `json:"options,omitempty"`
+}
+
+type ollamaChatResponse struct {
+	Message         ollamaMessage `json:"message"`
+	Done            bool          `json:"done"`
+	PromptEvalCount int           `json:"prompt_eval_count"`
+	EvalCount       int           `json:"eval_count"`
+}
+
+// DroppedSettings names the generation parameters Ollama does not accept
+// and that are therefore left out of requests.
+func (m *OllamaModel) DroppedSettings() []string {
+	if m == nil {
+		return nil
+	}
+	return m.dropped
+}
+
+// options maps the generation settings onto Ollama model options.
+func (m *OllamaModel) options() map[string]any {
+	opts := map[string]any{"temperature": 0.0}
+	g := m.generation
+	if g.Temperature != nil {
+		opts["temperature"] = *g.Temperature
+	}
+	if g.TopP != nil {
+		opts["top_p"] = *g.TopP
+	}
+	if g.Seed != nil {
+		opts["seed"] = *g.Seed
+	}
+	if g.MaxCompletionTokens > 0 {
+		opts["num_predict"] = g.MaxCompletionTokens
+	}
+	return opts
+}
+
+// Prepare checks that the model is available locally, pulling it first when
+// pull is true, and loads it into memory so the first classification does
+// not pay the start-up cost.
+func (m *OllamaModel) Prepare(ctx context.Context, pull bool) error {
+	if m == nil {
+		return errors.New("model is nil")
+	}
+	var tags struct {
+		Models []struct {
+			Name  string `json:"name"`
+			Model string `json:"model"`
+		} `json:"models"`
+	}
+	if err := m.do(ctx, http.MethodGet, "/api/tags", nil, &tags); err != nil {
+		return fmt.Errorf("ollama server at %s is not reachable: %w", m.baseURL, err)
+	}
+	found := false
+	for _, t := range tags.Models {
+		if ollamaNameMatches(t.Name, m.model) || ollamaNameMatches(t.Model, m.model) {
+			found = true
+			break
+		}
+	}
+	if !found {
+		if !pull {
+			return fmt.Errorf("ollama model %q is not available locally; run `ollama pull %s` or enable pulling", m.model, m.model)
+		}
+		if err := m.do(ctx, http.MethodPost, "/api/pull", map[string]any{"model": m.model, "stream": false}, nil); err != nil {
+			return fmt.Errorf("failed to pull ollama model %q: %w", m.model, err)
+		}
+	}
+	if err := m.do(ctx, http.MethodPost, "/api/generate", map[string]any{"model": m.model, "prompt": "", "stream": false}, nil); err != nil {
+		return fmt.Errorf("failed to warm up ollama model %q: %w", m.model, err)
+	}
+	return nil
+}
+
+// ollamaNameMatches compares model names, treating a missing tag as
+// ":latest" the way the Ollama CLI does.
+func ollamaNameMatches(have, want string) bool {
+	if have == "" {
+		return false
+	}
+	if !strings.Contains(have, ":") {
+		have += ":latest"
+	}
+	if !strings.Contains(want, ":") {
+		want += ":latest"
+	}
+	return have == want
+}
+
+func (m *OllamaModel) ChooseOption(ctx context.Context, prompt Prompt) (*Result, error) {
+	if m == nil {
+		return nil, errors.New("model is nil")
+	}
+	if len(prompt.Options) == 0 {
+		return nil, errors.New("prompt has no options")
+	}
+
+	rendered, err := m.template.render(prompt, jsonInstruction)
+	if err != nil {
+		return nil, err
+	}
+	// Ollama reuses its KV cache for a repeated message prefix, so the
+	// level-independent context and images go first.
+	messages := []ollamaMessage{{Role: "system", Content: rendered.system}}
+	first := ollamaMessage{Role: "user", Content: rendered.user}
+	if rendered.context != "" {
+		first.Content = rendered.context
+	}
+	for _, img := range prompt.Images {
+		data, ok := base64Payload(img)
+		if !ok {
+			return nil, fmt.Errorf("ollama backend needs local image files, got %s", img.URL)
+		}
+		first.Images = append(first.Images, data)
+	}
+	messages = append(messages, first)
+	if rendered.context != "" {
+		messages = append(messages, ollamaMessage{Role: "user", Content: rendered.user})
+	}
+
+	req := ollamaChatRequest{
+		Model:    m.model,
+		Messages: messages,
+		Stream:   false,
+		Format:   selectionSchema(len(prompt.Options)),
+		Options:  m.options(),
+	}
+
+	var resp ollamaChatResponse
+	retries, err := withRetries(ctx, m.retryPolicy(), shouldRetryStatus, func() error {
+		return m.do(ctx, http.MethodPost, "/api/chat", req, &resp)
+	})
+	if err != nil {
+		return nil, withRetryLog(err, retries)
+	}
+
+	selection, err := parseSelectionArgs(resp.Message.Content)
+	if err != nil {
+		return nil, err
+	}
+	result, err := selectionResult(selection, prompt)
+	if err != nil {
+		return nil, err
+	}
+	result.Model = m.model
+	result.PromptVersion = m.template.Version()
+	result.PromptFingerprint = rendered.fingerprint(prompt.Images)
+	result.Retries = retries
+	result.Usage = Usage{
+		PromptTokens:     resp.PromptEvalCount,
+		CompletionTokens: resp.EvalCount,
+		TotalTokens:      resp.PromptEvalCount + resp.EvalCount,
+	}
+	return result, nil
+}
+
+func (m *OllamaModel) do(ctx context.Context, method, path string, body any, out any) error {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, m.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := m.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(data, &apiErr) == nil && strings.TrimSpace(apiErr.Error) != "" {
+			return &statusError{status: resp.StatusCode, msg: fmt.Sprintf("ollama request failed: endpoint returned HTTP %d: %s", resp.StatusCode, strings.TrimSpace(apiErr.Error))}
+		}
+		return &statusError{status: resp.StatusCode, msg: fmt.Sprintf("ollama request failed: endpoint returned HTTP %d", resp.StatusCode)}
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to parse ollama response: %w", err)
+	}
+	return nil
+}
+
+// base64Payload extracts the base64 data of an inline image.
+func base64Payload(img Image) (string, bool) {
+	rest, ok := strings.CutPrefix(img.URL, "data:")
+	if !ok {
+		return "", false
+	}
+	meta, data, found := strings.Cut(rest, ",")
+	if !found || !strings.HasSuffix(meta, ";base64") {
+		return "", false
+	}
+	return data, true
+}
+
+func (m *OllamaModel) retryPolicy() retryPolicy {
+	return retryPolicy{attempts: m.maxAttempts, base: m.retryBaseDelay, max: m.retryMaxDelay, sleep: m.sleep, limiter: m.limiter}
+}
@@ -0,0 +1,119 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOllamaModelConstrainsSelectionWithSchema(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/chat" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		var req ollamaChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		if req.Stream {
+			t.Error("expected non-streaming request")
+		}
+		props, _ := req.Format["properties"].(map[string]any)
+		selection, _ := props["selection"].(map[string]any)
+		enum, _ := selection["enum"].([]any)
+		if len(enum) != 4 || enum[3] != noneSelection {
+			t.Errorf("unexpected selection enum: %#v", selection["enum"])
+		}
+		if len(req.Messages) != 3 || len(req.Messages[1].Images) != 1 || req.Messages[1].Images[0] != "AAAA" || len(req.Messages[2].Images) != 0 {
+			t.Errorf("unexpected messages: %#v", req.Messages)
+		}
+		_, _ = w.Write([]byte(`{"message":{"role":"assistant","content":"{\"selection\":\"2\"}"},"done":true,"prompt_eval_count":42,"eval_count":7}`))
+	}))
+	defer server.Close()
+
+	model, err := NewOllamaModel(WithBaseURL(server.URL), WithModel("qwen2.5:7b"))
+	if err != nil {
+		t.Fatalf("NewOllamaModel returned error: %v", err)
+	}
+	result, err := model.ChooseOption(context.Background(), Prompt{
+		Description: "tote",
+		Options:     []Option{{Name: "Backpacks", ID: "lb-1"}, {Name: "Totes", ID: "lb-2"}},
+		Images:      []Image{{URL: "data:image/jpeg;base64,AAAA"}},
+	})
+	if err != nil {
+		t.Fatalf("ChooseOption returned error: %v", err)
+	}
+	if result.Choice != "lb-2" || result.Model != "qwen2.5:7b" {
+		t.Fatalf("unexpected result: %#v", result)
+	}
+	if want := (Usage{PromptTokens: 42, CompletionTokens: 7, TotalTokens: 49}); result.Usage != want {
+		t.Fatalf("usage = %#v, want %#v", result.Usage, want)
+	}
+}
+
+func TestOllamaModelRejectsRemoteImages(t *testing.T) {
+	model, err := NewOllamaModel(WithBaseURL("http://127.0.0.1:1"))
+	if err != nil {
+		t.Fatalf("NewOllamaModel returned error: %v", err)
+	}
+	_, err = model.ChooseOption(context.Background(), Prompt{
+		Description: "tote",
+		Options:     []Option{{Name: "Totes"}},
+		Images:      []Image{{URL: "https://example.com/a.jpg"}},
+	})
+	if err == nil {
+		t.Fatal("expected error for remote image")
+	}
+}
+
+func TestOllamaModelPrepare(t *testing.T) {
+	var pulled, warmed bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/tags":
+			if pulled {
+				_, _ = w.Write([]byte(`{"models":[{"name":"llama3.1:8b","model":"llama3.1:8b"}]}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"models":[{"name":"mistral:latest","model":"mistral:latest"}]}`))
+		case "/api/pull":
+			pulled = true
+			_, _ = w.Write([]byte(`{"status":"success"}`))
+		case "/api/generate":
+			warmed = true
+			_, _ = w.Write([]byte(`{"done":true}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":"not found"}`))
+		}
+	}))
+	defer server.Close()
+
+	model, err := NewOllamaModel(WithBaseURL(server.URL))
+	if err != nil {
+		t.Fatalf("NewOllamaModel returned error: %v", err)
+	}
+	if err := model.Prepare(context.Background(), false); err == nil {
+		t.Fatal("expected error for missing model when pulling is disabled")
+	}
+	if err := model.Prepare(context.Background(), true); err != nil {
+		t.Fatalf("Prepare returned error: %v", err)
+	}
+	if !pulled || !warmed {
+		t.Fatalf("pulled=%v warmed=%v, want both", pulled, warmed)
+	}
+	if err := model.Prepare(context.Background(), false); err != nil {
+		t.Fatalf("Prepare after pull returned error: %v", err)
+	}
+}
+
+func TestOllamaNameMatches(t *testing.T) {
+	if !ollamaNameMatches("mistral:latest", "mistral") {
+		t.Fatal("expected untagged name to match :latest")
+	}
+	if ollamaNameMatches("llama3.1:70b", "llama3.1:8b") {
+		t.Fatal("expected different tags not to match")
+	}
+}
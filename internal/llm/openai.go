@@ -10,7 +10,6 @@ import (
 	"time"
 
 	openai "github.com/sashabaranov/go-openai"
-	"github.com/sashabaranov/go-openai/jsonschema"
 )
 
 type chatCompletionClient interface {
@@ -20,11 +19,22 @@ type chatCompletionClient interface {
 type OpenAIModel struct {
 	client         chatCompletionClient
 	model          string
+	endpoint       string
+	transport      Transport
+	transports     *transportCache
+	template       *PromptTemplate
+	generation     GenerationSettings
+	dropped        []string
 	maxAttempts    int
 	retryBaseDelay time.Duration
+	retryMaxDelay  time.Duration
+	limiter        *rateLimiter
 	sleep          func(ctx context.Context, d time.Duration) error
 }
 
+// DefaultOpenAIModel is the chat model used when WithModel is not supplied.
+const DefaultOpenAIModel = "gpt-5.4-mini"
+
 const (
 	selectionToolName  = "select_taxonomy_category"
 	noneSelection      = "none_of_these"
@@ -32,39 +42,81 @@ const (
 )
 
 func NewOpenAIModel(apiKey string, opts ...OptionFunc) (*OpenAIModel, error) {
-	if strings.TrimSpace(apiKey) == "" {
+	cfg := newConfig(DefaultOpenAIModel, opts)
+	if strings.TrimSpace(apiKey) == "" && len(cfg.headers) == 0 {
 		return nil, errors.New("openai api key is empty")
 	}
-	cfg := openai.DefaultConfig(apiKey)
-	for _, opt := range opts {
-		opt.apply(&cfg)
+	if err := cfg.generation.Validate(); err != nil {
+		return nil, err
+	}
+	generation, dropped := cfg.generation.forOpenAI(cfg.model)
+	clientCfg := openai.DefaultConfig(apiKey)
+	if cfg.azure != nil {
+		if cfg.baseURL == "" {
+			return nil, errors.New("azure openai needs an endpoint base URL")
+		}
+		clientCfg = openai.DefaultAzureConfig(apiKey, cfg.baseURL)
+		clientCfg.APIVersion = cfg.azure.APIVersion
+		if clientCfg.APIVersion == "" {
+			clientCfg.APIVersion = DefaultAzureAPIVersion
+		}
+		clientCfg.AzureModelMapperFunc = cfg.azure.deployment
+	} else if cfg.baseURL != "" {
+		clientCfg.BaseURL = cfg.baseURL
+	}
+	httpClient, err := cfg.client()
+	if err != nil {
+		return nil, err
+	}
+	retry := cfg.retry.withDefaults()
+	// Azure applies its limits per deployment.
+	limitedURL := clientCfg.BaseURL
+	if cfg.azure != nil {
+		limitedURL = strings.TrimRight(limitedURL, "/") + "/openai/deployments/" + cfg.azure.deployment(cfg.model)
+	}
+	limiter := defaultRateLimiters.get(limitedURL, apiKey, cfg.headers)
+	httpClient = withRateLimits(httpClient, limiter)
+	clientCfg.HTTPClient = withExtraFields(httpClient, "/chat/completions", generation.openAIExtra())
+	var client chatCompletionClient = openai.NewClientWithConfig(clientCfg)
+	if cfg.cassette != nil {
+		client = cfg.cassette.wrap(client)
+	}
+	transport, err := ParseTransport(string(cfg.transport))
+	if err != nil {
+		return nil, err
 	}
-	client := openai.NewClientWithConfig(cfg)
 	return &OpenAIModel{
 		client:         client,
-		model:          "gpt-5.4-mini",
-		maxAttempts:    defaultMaxAttempts,
-		retryBaseDelay: time.Second,
+		model:          cfg.model,
+		endpoint:       clientCfg.BaseURL,
+		transport:      transport,
+		transports:     defaultTransportCache,
+		template:       cfg.template,
+		generation:     generation,
+		dropped:        dropped,
+		maxAttempts:    retry.MaxAttempts,
+		retryBaseDelay: retry.BaseDelay,
+		retryMaxDelay:  retry.MaxDelay,
+		limiter:        limiter,
 		sleep:          sleepWithContext,
 	}, nil
 }
 
-type OptionFunc interface {
-	apply(cfg *openai.ClientConfig)
-}
-
-type optionFunc func(cfg *openai.ClientConfig)
-
-func (f optionFunc) apply(cfg *openai.ClientConfig) {
-	f(cfg)
+// Name returns the chat model name used for requests.
+func (m *OpenAIModel) Name() string {
+	if m == nil {
+		return ""
+	}
+	return m.model
 }
 
-func WithBaseURL(url string) OptionFunc {
-	return optionFunc(func(cfg *openai.ClientConfig) {
-		if strings.TrimSpace(url) != "" {
-			cfg.BaseURL = url
-		}
-	})
+// DroppedSettings names the generation parameters left out of requests
+// because the model family rejects them.
+func (m *OpenAIModel) DroppedSettings() []string {
+	if m == nil {
+		return nil
+	}
+	return m.dropped
 }
 
 func (m *OpenAIModel) ChooseOption(ctx context.Context, prompt Prompt) (*Result, error) {
@@ -75,163 +127,192 @@ func (m *OpenAIModel) ChooseOption(ctx context.Context, prompt Prompt) (*Result,
 		return nil, errors.New("prompt has no options")
 	}
 
-	sb := &strings.Builder{}
-	sb.WriteString("You are an expert Shopify taxonomy classifier.\n")
-	sb.WriteString("Select the single best matching category from the provided list.\n")
-	sb.WriteString("Use the provided tool to return exactly one selection.\n")
-	sb.WriteString("Do not add explanations.\n\n")
-	sb.WriteString("Product description:\n")
-	sb.WriteString(prompt.Description)
-	sb.WriteString("\n\n")
-	if len(prompt.Path) > 0 {
-		sb.WriteString("Current category path: ")
-		sb.WriteString(strings.Join(prompt.Path, " > "))
-		sb.WriteString("\n")
-	}
-	sb.WriteString("Candidate categories:\n")
-	for i, opt := range prompt.Options {
-		label := opt.FullName
-		if strings.TrimSpace(label) == "" {
-			label = opt.Name
+	key := transportKey(m.endpoint, m.model)
+	transports := transportOrder(m.transport, m.transports, key)
+	var spent Usage
+	var retried []Retry
+	// Only a transport reached by rejections of the ones before it is
+	// remembered.
+	remember := m.transport == "" || m.transport == TransportAuto
+	for i, transport := range transports {
+		result, usage, retries, err := m.chooseWith(ctx, prompt, transport)
+		spent = spent.Add(usage)
+		retried = append(retried, retries...)
+		if err == nil {
+			if remember {
+				m.transports.set(key, transport)
+			}
+			result.Model = m.model
+			result.PromptVersion = m.template.Version()
+			result.Usage = spent
+			result.Retries = retried
+			return result, nil
 		}
-		if strings.TrimSpace(label) == "" {
-			label = opt.ID
+		if i == len(transports)-1 || !(isCapabilityError(err) || isIgnoredError(err)) {
+			return nil, withRetryLog(err, retried)
 		}
-		if strings.TrimSpace(opt.ID) != "" {
-			sb.WriteString(fmt.Sprintf("%d. %s (id: %s)\n", i+1, label, opt.ID))
-		} else {
-			sb.WriteString(fmt.Sprintf("%d. %s\n", i+1, label))
+		if isIgnoredError(err) {
+			remember = false
 		}
 	}
-	sb.WriteString("\nIf none of the categories match, use selection='none_of_these'.")
-
-	allowedSelections := make([]string, 0, len(prompt.Options)+2)
-	for i := range prompt.Options {
-		allowedSelections = append(allowedSelections, strconv.Itoa(i+1))
-	}
-	allowedSelections = append(allowedSelections, strconv.Itoa(len(prompt.Options)+1)) // Backwards-compat for earlier prompts numbering "none of these".
-	allowedSelections = append(allowedSelections, noneSelection)
+	return nil, errors.New("no selection transport available")
+}
 
-	selectionTool := openai.Tool{
-		Type: openai.ToolTypeFunction,
-		Function: &openai.FunctionDefinition{
-			Name:        selectionToolName,
-			Description: "Select the best matching taxonomy option.",
-			Parameters: jsonschema.Definition{
-				Type: jsonschema.Object,
-				Properties: map[string]jsonschema.Definition{
-					"selection": {
-						Type:        jsonschema.String,
-						Description: "One-based index for the selected option, or none_of_these.",
-						Enum:        allowedSelections,
-					},
-				},
-				Required: []string{"selection"},
+// chooseWith asks for a selection over a single transport. An API error
+// showing that the endpoint does not support the transport is returned as
+// capabilityError, and a reply without the requested structure as
+// ignoredError, so that auto-detection can move on to the next one.
+// Malformed structured replies are ordinary invalid responses.
+func (m *OpenAIModel) chooseWith(ctx context.Context, prompt Prompt, transport Transport) (*Result, Usage, []Retry, error) {
+	instruction := toolInstruction
+	switch transport {
+	case TransportJSONSchema:
+		instruction = jsonInstruction
+	case TransportText:
+		instruction = textInstruction
+	}
+	rendered, err := m.template.render(prompt, instruction)
+	if err != nil {
+		return nil, Usage{}, nil, err
+	}
+
+	messages := []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleSystem, Content: rendered.system}}
+	if rendered.context != "" {
+		// The context and images repeat unchanged at every level, so they
+		// go first to form a prefix the provider can cache.
+		messages = append(messages,
+			openAIUserMessage(rendered.context, prompt.Images),
+			openAIUserMessage(rendered.user, nil))
+	} else {
+		messages = append(messages, openAIUserMessage(rendered.user, prompt.Images))
+	}
+
+	req := openai.ChatCompletionRequest{
+		Model:    m.model,
+		Messages: messages,
+	}
+	m.generation.applyOpenAI(&req)
+	switch transport {
+	case TransportJSONSchema:
+		req.ResponseFormat = &openai.ChatCompletionResponseFormat{
+			Type: openai.ChatCompletionResponseFormatTypeJSONSchema,
+			JSONSchema: &openai.ChatCompletionResponseFormatJSONSchema{
+				Name:   "taxonomy_selection",
+				Schema: mustMarshal(selectionSchema(len(prompt.Options))),
+				Strict: true,
 			},
-		},
-	}
-
-	resp, err := m.createChatCompletion(ctx, openai.ChatCompletionRequest{
-		Model:       m.model,
-		Temperature: 0,
-		Messages: []openai.ChatCompletionMessage{
-			{Role: openai.ChatMessageRoleSystem, Content: "You classify Shopify products."},
-			{Role: openai.ChatMessageRoleUser, Content: sb.String()},
-		},
-		Tools: []openai.Tool{selectionTool},
-		ToolChoice: openai.ToolChoice{
+		}
+	case TransportText:
+	default:
+		req.Tools = []openai.Tool{selectionTool(len(prompt.Options))}
+		req.ToolChoice = openai.ToolChoice{
 			Type: openai.ToolTypeFunction,
 			Function: openai.ToolFunction{
 				Name: selectionToolName,
 			},
-		},
-		ParallelToolCalls: false,
-	})
+		}
+		req.ParallelToolCalls = false
+	}
+
+	resp, retries, err := m.createChatCompletion(ctx, req)
 	if err != nil {
-		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
-			return nil, err
+		if rejectsTransport(err) {
+			err = &capabilityError{err: err}
 		}
-		return nil, describeCreateChatCompletionError(err)
+		return nil, Usage{}, retries, err
+	}
+	usage := Usage{
+		PromptTokens:     resp.Usage.PromptTokens,
+		CompletionTokens: resp.Usage.CompletionTokens,
+		TotalTokens:      resp.Usage.TotalTokens,
+	}
+	if d := resp.Usage.PromptTokensDetails; d != nil {
+		usage.CachedPromptTokens = d.CachedTokens
+	}
+	if d := resp.Usage.CompletionTokensDetails; d != nil {
+		usage.ReasoningTokens = d.ReasoningTokens
 	}
 	if len(resp.Choices) == 0 {
-		return nil, errors.New("no completion choices returned")
+		return nil, usage, retries, invalidResponse("no completion choices returned")
 	}
 
-	selection, err := parseSelection(resp.Choices[0].Message)
-	if err != nil {
-		return nil, err
+	msg := resp.Choices[0].Message
+	if refusal := strings.TrimSpace(msg.Refusal); refusal != "" {
+		return nil, usage, retries, &RefusalError{Reason: refusal}
 	}
-	selection = normalizeSelection(selection, len(prompt.Options))
-
-	result := &Result{
-		Choice: "none of these",
-		Usage: Usage{
-			PromptTokens:     resp.Usage.PromptTokens,
-			CompletionTokens: resp.Usage.CompletionTokens,
-			TotalTokens:      resp.Usage.TotalTokens,
-		},
+	if resp.Choices[0].FinishReason == openai.FinishReasonContentFilter {
+		return nil, usage, retries, &RefusalError{Reason: "reply withheld by the content filter"}
 	}
-	if selection != noneSelection {
-		oneBased, err := strconv.Atoi(selection)
-		if err != nil {
-			return nil, fmt.Errorf("invalid selection value %q from model", selection)
-		}
-		idx := oneBased - 1
-		if idx < 0 || idx >= len(prompt.Options) {
-			return nil, fmt.Errorf("selection index %d out of range for %d options", oneBased, len(prompt.Options))
-		}
-		result.ChoiceIndex = &idx
-
-		selected := prompt.Options[idx]
-		switch {
-		case strings.TrimSpace(selected.ID) != "":
-			result.Choice = selected.ID
-		case strings.TrimSpace(selected.FullName) != "":
-			result.Choice = selected.FullName
-		default:
-			result.Choice = selected.Name
+	var selection string
+	switch transport {
+	case TransportJSONSchema:
+		selection, err = parseSelectionArgs(msg.Content)
+	case TransportText:
+		selection, err = parseTextSelection(msg.Content, prompt.Options)
+	default:
+		selection, err = parseSelection(msg)
+	}
+	if err != nil {
+		if ignoresTransport(transport, msg) {
+			// The endpoint accepted the parameters but answered without
+			// the requested structure.
+			err = &ignoredError{err: err}
 		}
+		return nil, usage, retries, err
 	}
-
-	return result, nil
+	result, err := selectionResult(selection, prompt)
+	if err != nil {
+		return nil, usage, retries, err
+	}
+	result.PromptFingerprint = rendered.fingerprint(prompt.Images)
+	return result, usage, retries, nil
 }
 
-func (m *OpenAIModel) createChatCompletion(ctx context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
-	if m == nil || m.client == nil {
-		return openai.ChatCompletionResponse{}, errors.New("model client is nil")
+// selectionTool describes the selection function for a prompt with
+// optionCount candidates.
+func selectionTool(optionCount int) openai.Tool {
+	return openai.Tool{
+		Type: openai.ToolTypeFunction,
+		Function: &openai.FunctionDefinition{
+			Name:        selectionToolName,
+			Description: "Select the best matching taxonomy option.",
+			Parameters:  mustMarshal(selectionSchema(optionCount)),
+		},
 	}
+}
 
-	attempts := m.maxAttempts
-	if attempts < 1 {
-		attempts = 1
+func openAIUserMessage(text string, images []Image) openai.ChatCompletionMessage {
+	if len(images) == 0 {
+		return openai.ChatCompletionMessage{Role: openai.ChatMessageRoleUser, Content: text}
 	}
-
-	sleep := m.sleep
-	if sleep == nil {
-		sleep = sleepWithContext
+	parts := make([]openai.ChatMessagePart, 0, len(images)+1)
+	parts = append(parts, openai.ChatMessagePart{Type: openai.ChatMessagePartTypeText, Text: text})
+	for _, img := range images {
+		parts = append(parts, openai.ChatMessagePart{
+			Type:     openai.ChatMessagePartTypeImageURL,
+			ImageURL: &openai.ChatMessageImageURL{URL: img.URL, Detail: openai.ImageURLDetailAuto},
+		})
 	}
+	return openai.ChatCompletionMessage{Role: openai.ChatMessageRoleUser, MultiContent: parts}
+}
 
-	var lastErr error
-	for attempt := 1; attempt <= attempts; attempt++ {
-		resp, err := m.client.CreateChatCompletion(ctx, req)
-		if err == nil {
-			return resp, nil
-		}
-		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
-			return openai.ChatCompletionResponse{}, err
-		}
-
-		lastErr = err
-		if attempt == attempts || !shouldRetryCreateChatCompletion(err) {
-			break
-		}
-
-		if err := sleep(ctx, retryDelay(attempt, m.retryBaseDelay)); err != nil {
-			return openai.ChatCompletionResponse{}, err
-		}
+// createChatCompletion sends req with retries, returning the retries made
+// and, on failure, an error described by describeCreateChatCompletionError.
+func (m *OpenAIModel) createChatCompletion(ctx context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, []Retry, error) {
+	if m == nil || m.client == nil {
+		return openai.ChatCompletionResponse{}, nil, errors.New("model client is nil")
 	}
 
-	return openai.ChatCompletionResponse{}, lastErr
+	var resp openai.ChatCompletionResponse
+	retries, err := withRetries(ctx, m.retryPolicy(), shouldRetryCreateChatCompletion, func() error {
+		var err error
+		resp, err = m.client.CreateChatCompletion(ctx, req)
+		return describeCreateChatCompletionError(err)
+	})
+	if err != nil {
+		return openai.ChatCompletionResponse{}, retries, err
+	}
+	return resp, retries, nil
 }
 
 func normalizeSelection(selection string, optionCount int) string {
@@ -252,6 +333,20 @@ func normalizeSelection(selection string, optionCount int) string {
 	return selection
 }
 
+// ignoresTransport reports whether msg lacks the structure requested by
+// transport altogether: no tool call for TransportTool, or content that is
+// not a JSON object for TransportJSONSchema.
+func ignoresTransport(transport Transport, msg openai.ChatCompletionMessage) bool {
+	switch transport {
+	case TransportText:
+		return false
+	case TransportJSONSchema:
+		return !strings.HasPrefix(strings.TrimSpace(msg.Content), "{")
+	default:
+		return len(msg.ToolCalls) == 0 && msg.FunctionCall == nil
+	}
+}
+
 func parseSelection(msg openai.ChatCompletionMessage) (string, error) {
 	for _, tc := range msg.ToolCalls {
 		if tc.Type != openai.ToolTypeFunction || tc.Function.Name != selectionToolName {
@@ -262,29 +357,47 @@ func parseSelection(msg openai.ChatCompletionMessage) (string, error) {
 	if msg.FunctionCall != nil && msg.FunctionCall.Name == selectionToolName {
 		return parseSelectionArgs(msg.FunctionCall.Arguments)
 	}
-	return "", errors.New("model did not return selection tool call")
+	return "", invalidResponse("model did not return selection tool call")
 }
 
 func describeCreateChatCompletionError(err error) error {
 	var reqErr *openai.RequestError
 	if errors.As(err, &reqErr) {
 		if reqErr.Err != nil {
-			return fmt.Errorf("chat completion request failed before tool parsing: endpoint returned HTTP %d with a non-JSON error response: %v", reqErr.HTTPStatusCode, reqErr.Err)
+			return &statusError{status: reqErr.HTTPStatusCode, err: err, msg: fmt.Sprintf("chat completion request failed before tool parsing: endpoint returned HTTP %d with a non-JSON error response: %v", reqErr.HTTPStatusCode, reqErr.Err)}
 		}
-		return fmt.Errorf("chat completion request failed before tool parsing: endpoint returned HTTP %d with an unreadable error response", reqErr.HTTPStatusCode)
+		return &statusError{status: reqErr.HTTPStatusCode, err: err, msg: fmt.Sprintf("chat completion request failed before tool parsing: endpoint returned HTTP %d with an unreadable error response", reqErr.HTTPStatusCode)}
 	}
 
 	var apiErr *openai.APIError
 	if errors.As(err, &apiErr) {
 		if msg := strings.TrimSpace(apiErr.Message); msg != "" {
-			return fmt.Errorf("chat completion request failed: endpoint returned HTTP %d: %s", apiErr.HTTPStatusCode, msg)
+			return &statusError{status: apiErr.HTTPStatusCode, err: err, msg: fmt.Sprintf("chat completion request failed: endpoint returned HTTP %d: %s", apiErr.HTTPStatusCode, msg)}
 		}
-		return fmt.Errorf("chat completion request failed: endpoint returned HTTP %d", apiErr.HTTPStatusCode)
+		return &statusError{status: apiErr.HTTPStatusCode, err: err, msg: fmt.Sprintf("chat completion request failed: endpoint returned HTTP %d", apiErr.HTTPStatusCode)}
 	}
 
 	return err
 }
 
+// statusError carries the HTTP status of a failed request alongside a
+// readable description, while still unwrapping to the client error.
+type statusError struct {
+	status int
+	msg    string
+	err    error
+}
+
+func (e *statusError) Error() string   { return e.msg }
+func (e *statusError) Unwrap() error   { return e.err }
+func (e *statusError) StatusCode() int { return e.status }
+
+// Is matches the error class of the status, see Classify.
+func (e *statusError) Is(target error) bool {
+	class := statusClass(e.status)
+	return class != nil && target == class
+}
+
 func shouldRetryCreateChatCompletion(err error) bool {
 	var reqErr *openai.RequestError
 	if errors.As(err, &reqErr) {
@@ -301,31 +414,13 @@ func shouldRetryCreateChatCompletion(err error) bool {
 
 func retryableHTTPStatus(status int) bool {
 	switch status {
-	case 429, 500, 502, 503, 504:
+	case 429, 500, 502, 503, 504, 529:
 		return true
 	default:
 		return false
 	}
 }
 
-func retryDelay(attempt int, base time.Duration) time.Duration {
-	if base <= 0 {
-		base = time.Second
-	}
-	if attempt < 1 {
-		attempt = 1
-	}
-
-	delay := base
-	for i := 1; i < attempt; i++ {
-		if delay > time.Duration(^uint64(0)>>1)/2 {
-			return time.Duration(^uint64(0) >> 1)
-		}
-		delay *= 2
-	}
-	return delay
-}
-
 func sleepWithContext(ctx context.Context, d time.Duration) error {
 	if d <= 0 {
 		select {
@@ -350,18 +445,18 @@ func sleepWithContext(ctx context.Context, d time.Duration) error {
 func parseSelectionArgs(raw string) (string, error) {
 	raw = strings.TrimSpace(raw)
 	if raw == "" {
-		return "", errors.New("model returned empty selection payload")
+		return "", invalidResponse("model returned empty selection payload")
 	}
 
 	dec := json.NewDecoder(strings.NewReader(raw))
 	dec.UseNumber()
 	var payload map[string]any
 	if err := dec.Decode(&payload); err != nil {
-		return "", fmt.Errorf("failed to parse selection payload: %w", err)
+		return "", invalidResponsef("failed to parse selection payload: %w", err)
 	}
 	rawSelection, ok := payload["selection"]
 	if !ok {
-		return "", errors.New("selection payload missing selection field")
+		return "", invalidResponse("selection payload missing selection field")
 	}
 
 	var selection string
@@ -371,12 +466,16 @@ func parseSelectionArgs(raw string) (string, error) {
 	case json.Number:
 		selection = v.String()
 	default:
-		return "", fmt.Errorf("selection payload has unsupported selection type %T", rawSelection)
+		return "", invalidResponsef("selection payload has unsupported selection type %T", rawSelection)
 	}
 
 	selection = strings.TrimSpace(selection)
 	if selection == "" {
-		return "", errors.New("selection payload missing selection field")
+		return "", invalidResponse("selection payload missing selection field")
 	}
 	return normalizeSelection(selection, 0), nil
 }
+
+func (m *OpenAIModel) retryPolicy() retryPolicy {
+	return retryPolicy{attempts: m.maxAttempts, base: m.retryBaseDelay, max: m.retryMaxDelay, sleep: m.sleep, limiter: m.limiter}
+}
@@ -2,7 +2,10 @@ package llm
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
@@ -326,3 +329,169 @@ func TestShouldRetryCreateChatCompletion(t *testing.T) {
 		})
 	}
 }
+
+func TestChooseOptionSendsImagePartsToServer(t *testing.T) {
+	var sawImages int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Messages []struct {
+				Role    string          `json:"role"`
+				Content json.RawMessage `json:"content"`
+			} `json:"messages"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		for _, msg := range req.Messages {
+			if msg.Role != openai.ChatMessageRoleUser {
+				continue
+			}
+			var parts []openai.ChatMessagePart
+			if err := json.Unmarshal(msg.Content, &parts); err != nil {
+				// The per-level candidates follow as plain text.
+				continue
+			}
+			for _, part := range parts {
+				if part.Type == openai.ChatMessagePartTypeImageURL && part.ImageURL != nil {
+					sawImages++
+				}
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"choices":[{"message":{"role":"assistant","tool_calls":[{"id":"call_1","type":"function","function":{"name":"` + selectionToolName + `","arguments":"{\"selection\":\"1\"}"}}]}}],"usage":{"prompt_tokens":5,"completion_tokens":1,"total_tokens":6}}`))
+	}))
+	defer server.Close()
+
+	model, err := NewOpenAIModel("test-key", WithBaseURL(server.URL+"/v1"))
+	if err != nil {
+		t.Fatalf("NewOpenAIModel returned error: %v", err)
+	}
+	result, err := model.ChooseOption(context.Background(), Prompt{
+		Description: "bag",
+		Options:     []Option{{Name: "Bags", ID: "lb-1"}},
+		Images: []Image{
+			{URL: "https://example.com/bag.jpg"},
+			{URL: "data:image/png;base64,AAAA"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("ChooseOption returned error: %v", err)
+	}
+	if result.Choice != "lb-1" {
+		t.Fatalf("result choice = %q, want lb-1", result.Choice)
+	}
+	if sawImages != 2 {
+		t.Fatalf("server saw %d image parts, want 2", sawImages)
+	}
+}
+
+func TestNewOpenAIModelWithModel(t *testing.T) {
+	model, err := NewOpenAIModel("test-key")
+	if err != nil {
+		t.Fatalf("NewOpenAIModel returned error: %v", err)
+	}
+	if model.Name() != DefaultOpenAIModel {
+		t.Fatalf("default model = %q, want %q", model.Name(), DefaultOpenAIModel)
+	}
+	model, err = NewOpenAIModel("test-key", WithModel("gpt-5.4"))
+	if err != nil {
+		t.Fatalf("NewOpenAIModel returned error: %v", err)
+	}
+	if model.Name() != "gpt-5.4" {
+		t.Fatalf("model = %q, want gpt-5.4", model.Name())
+	}
+}
+
+func TestChooseOptionKeepsMessagePrefixStableAcrossLevels(t *testing.T) {
+	reply := toolReply("1")
+	reply.resp.Usage = openai.Usage{
+		PromptTokens:            1200,
+		CompletionTokens:        40,
+		TotalTokens:             1240,
+		PromptTokensDetails:     &openai.PromptTokensDetails{CachedTokens: 1024},
+		CompletionTokensDetails: &openai.CompletionTokensDetails{ReasoningTokens: 32},
+	}
+	client := &recordingChatCompletionClient{fakeChatCompletionClient: fakeChatCompletionClient{
+		responses: []fakeChatCompletionResult{reply, reply},
+	}}
+	model := &OpenAIModel{client: client, model: "m", transport: TransportTool, maxAttempts: 1, sleep: noSleep}
+
+	root := Prompt{Description: "leather handbag", Options: []Option{{Name: "Apparel", ID: "aa"}, {Name: "Luggage & Bags", ID: "lb"}}}
+	child := Prompt{Description: "leather handbag", Path: []string{"Luggage & Bags"}, Options: []Option{{Name: "Handbags", ID: "lb-1"}, {Name: "Backpacks", ID: "lb-2"}, {Name: "Totes", ID: "lb-3"}}}
+	result, err := model.ChooseOption(context.Background(), root)
+	if err != nil {
+		t.Fatalf("ChooseOption returned error: %v", err)
+	}
+	if _, err := model.ChooseOption(context.Background(), child); err != nil {
+		t.Fatalf("ChooseOption returned error: %v", err)
+	}
+
+	prefix := func(req openai.ChatCompletionRequest) string {
+		data, err := json.Marshal(req.Messages[:len(req.Messages)-1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+	if a, b := prefix(client.requests[0]), prefix(client.requests[1]); a != b {
+		t.Fatalf("request prefix differs between levels:\n%s\n%s", a, b)
+	}
+	// The tool schema lists each level's choices so that constrained
+	// decoding rules out numbers beyond the options.
+	for i, want := range []string{`["1","2","3","none_of_these"]`, `["1","2","3","4","none_of_these"]`} {
+		var schema struct {
+			Properties struct {
+				Selection struct {
+					Enum json.RawMessage `json:"enum"`
+				} `json:"selection"`
+			} `json:"properties"`
+		}
+		params, _ := client.requests[i].Tools[0].Function.Parameters.(json.RawMessage)
+		if err := json.Unmarshal(params, &schema); err != nil {
+			t.Fatalf("failed to decode tool schema: %v", err)
+		}
+		if got := string(schema.Properties.Selection.Enum); got != want {
+			t.Fatalf("level %d enum = %s, want %s", i, got, want)
+		}
+	}
+
+	want := Usage{PromptTokens: 1200, CompletionTokens: 40, TotalTokens: 1240, CachedPromptTokens: 1024, ReasoningTokens: 32}
+	if result.Usage != want {
+		t.Fatalf("usage = %+v, want %+v", result.Usage, want)
+	}
+}
+
+func TestChooseOptionRendersIdenticalRequestsForIdenticalPrompts(t *testing.T) {
+	reply := toolReply("1")
+	client := &recordingChatCompletionClient{fakeChatCompletionClient: fakeChatCompletionClient{
+		responses: []fakeChatCompletionResult{reply, reply, reply},
+	}}
+	model := &OpenAIModel{client: client, model: "m", transport: TransportTool, maxAttempts: 1, sleep: noSleep}
+
+	prompt := Prompt{Description: "leather handbag", Path: []string{"Luggage & Bags"}, Options: []Option{{Name: "Handbags", ID: "lb-1"}, {Name: "Totes", ID: "lb-3"}}}
+	first, err := model.ChooseOption(context.Background(), prompt)
+	if err != nil {
+		t.Fatalf("ChooseOption returned error: %v", err)
+	}
+	second, err := model.ChooseOption(context.Background(), prompt)
+	if err != nil {
+		t.Fatalf("ChooseOption returned error: %v", err)
+	}
+	prompt.Options[0], prompt.Options[1] = prompt.Options[1], prompt.Options[0]
+	swapped, err := model.ChooseOption(context.Background(), prompt)
+	if err != nil {
+		t.Fatalf("ChooseOption returned error: %v", err)
+	}
+
+	a, _ := json.Marshal(client.requests[0])
+	b, _ := json.Marshal(client.requests[1])
+	if string(a) != string(b) {
+		t.Fatalf("identical prompts rendered different requests:\n%s\n%s", a, b)
+	}
+	if first.PromptFingerprint == "" || first.PromptFingerprint != second.PromptFingerprint {
+		t.Fatalf("fingerprints = %q, %q; want equal and non-empty", first.PromptFingerprint, second.PromptFingerprint)
+	}
+	if swapped.PromptFingerprint == first.PromptFingerprint {
+		t.Fatal("reordered options kept the same fingerprint")
+	}
+}
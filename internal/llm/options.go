@@ -0,0 +1,145 @@
+package llm
+
+import (
+	"net/http"
+	"strings"
+)
+
+// config holds the settings shared by every HTTP-backed model.
+type config struct {
+	baseURL    string
+	model      string
+	httpClient *http.Client
+	transport  Transport
+	cassette   *Cassette
+	template   *PromptTemplate
+	generation GenerationSettings
+	azure      *AzureConfig
+	headers    http.Header
+	query      map[string]string
+	tls        TLSConfig
+	retry      RetryConfig
+}
+
+func newConfig(defaultModel string, opts []OptionFunc) config {
+	cfg := config{model: defaultModel, template: DefaultPromptTemplate()}
+	for _, opt := range opts {
+		if opt != nil {
+			opt.apply(&cfg)
+		}
+	}
+	return cfg
+}
+
+type OptionFunc interface {
+	apply(cfg *config)
+}
+
+type optionFunc func(cfg *config)
+
+func (f optionFunc) apply(cfg *config) {
+	f(cfg)
+}
+
+func WithBaseURL(url string) OptionFunc {
+	return optionFunc(func(cfg *config) {
+		if strings.TrimSpace(url) != "" {
+			cfg.baseURL = url
+		}
+	})
+}
+
+// WithModel selects the model name sent with every request.
+func WithModel(name string) OptionFunc {
+	return optionFunc(func(cfg *config) {
+		if strings.TrimSpace(name) != "" {
+			cfg.model = strings.TrimSpace(name)
+		}
+	})
+}
+
+// WithHTTPClient replaces the HTTP client used to reach the endpoint.
+func WithHTTPClient(client *http.Client) OptionFunc {
+	return optionFunc(func(cfg *config) {
+		if client != nil {
+			cfg.httpClient = client
+		}
+	})
+}
+
+// WithTransport pins the selection transport instead of auto-detecting it.
+// Only the OpenAI-compatible backend honours it.
+func WithTransport(t Transport) OptionFunc {
+	return optionFunc(func(cfg *config) {
+		cfg.transport = t
+	})
+}
+
+// WithCassette routes chat-completion requests through c so that they are
+// recorded or replayed. Only the OpenAI-compatible backend honours it.
+func WithCassette(c *Cassette) OptionFunc {
+	return optionFunc(func(cfg *config) {
+		cfg.cassette = c
+	})
+}
+
+// WithPromptTemplate replaces the built-in prompt template.
+func WithPromptTemplate(t *PromptTemplate) OptionFunc {
+	return optionFunc(func(cfg *config) {
+		if t != nil {
+			cfg.template = t
+		}
+	})
+}
+
+// WithGeneration sets the sampling and reasoning parameters sent with every
+// request. Parameters the backend or model family rejects are dropped.
+func WithGeneration(s GenerationSettings) OptionFunc {
+	return optionFunc(func(cfg *config) {
+		cfg.generation = s
+	})
+}
+
+// WithAzure talks to Azure OpenAI instead of the OpenAI API. Only the
+// OpenAI-compatible backend honours it.
+func WithAzure(a AzureConfig) OptionFunc {
+	return optionFunc(func(cfg *config) {
+		cfg.azure = &a
+	})
+}
+
+// WithHeader adds a header to every request, replacing any value the
+// backend would send, such as its own authentication header.
+func WithHeader(name, value string) OptionFunc {
+	return optionFunc(func(cfg *config) {
+		if cfg.headers == nil {
+			cfg.headers = make(http.Header)
+		}
+		cfg.headers.Add(name, value)
+	})
+}
+
+// WithQueryParam adds a query parameter to every request URL.
+func WithQueryParam(name, value string) OptionFunc {
+	return optionFunc(func(cfg *config) {
+		if cfg.query == nil {
+			cfg.query = make(map[string]string)
+		}
+		cfg.query[name] = value
+	})
+}
+
+// WithTLS configures client certificates and extra trusted CAs for HTTPS
+// connections. It replaces the transport of any WithHTTPClient client.
+func WithTLS(t TLSConfig) OptionFunc {
+	return optionFunc(func(cfg *config) {
+		cfg.tls = t
+	})
+}
+
+// WithRetry configures how transient failures are retried.
+func WithRetry(r RetryConfig) OptionFunc {
+	return optionFunc(func(cfg *config) {
+		cfg.retry = r
+	})
+}
@@ -0,0 +1,84 @@
+package llm
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// Instructions describing how the model should return its selection, one per
+// way of constraining the answer.
+const (
+	toolInstruction = "Use the provided tool to return exactly one selection."
+	jsonInstruction = "Reply with a JSON object whose selection field holds exactly one selection."
+	textInstruction = "Reply with only the number of the best matching category, or none_of_these."
+)
+
+// selectionEnum lists the values the selection field may take for a prompt
+// with optionCount candidates.
+func selectionEnum(optionCount int) []string {
+	allowed := make([]string, 0, optionCount+2)
+	for i := 0; i < optionCount; i++ {
+		allowed = append(allowed, strconv.Itoa(i+1))
+	}
+	allowed = append(allowed, strconv.Itoa(optionCount+1)) // Backwards-compat for earlier prompts numbering "none of these".
+	allowed = append(allowed, noneSelection)
+	return allowed
+}
+
+// selectionSchema is the JSON schema constraining a reply to one selection
+// of a prompt with optionCount candidates. Every provider uses it, so that
+// out-of-range answers are ruled out by constrained decoding rather than
+// caught after the call.
+func selectionSchema(optionCount int) map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"selection": map[string]any{
+				"type":        "string",
+				"description": "One-based index for the selected option, or none_of_these.",
+				"enum":        selectionEnum(optionCount),
+			},
+		},
+		"required":             []string{"selection"},
+		"additionalProperties": false,
+	}
+}
+
+func mustMarshal(v any) json.RawMessage {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+// selectionResult maps a raw selection value onto the prompt's options.
+func selectionResult(selection string, prompt Prompt) (*Result, error) {
+	selection = normalizeSelection(selection, len(prompt.Options))
+
+	result := &Result{Choice: "none of these"}
+	if selection == noneSelection {
+		return result, nil
+	}
+	oneBased, err := strconv.Atoi(selection)
+	if err != nil {
+		return nil, invalidResponsef("invalid selection value %q from model", selection)
+	}
+	idx := oneBased - 1
+	if idx < 0 || idx >= len(prompt.Options) {
+		return nil, invalidResponsef("selection index %d out of range for %d options", oneBased, len(prompt.Options))
+	}
+	result.ChoiceIndex = &idx
+
+	selected := prompt.Options[idx]
+	switch {
+	case strings.TrimSpace(selected.ID) != "":
+		result.Choice = selected.ID
+	case strings.TrimSpace(selected.FullName) != "":
+		result.Choice = selected.FullName
+	default:
+		result.Choice = selected.Name
+	}
+	return result, nil
+}
@@ -0,0 +1,210 @@
+package llm
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rateLimiter paces requests to one endpoint using the rate-limit headers
+// of its responses. A nil limiter never delays.
+type rateLimiter struct {
+	mu        sync.Mutex
+	notBefore time.Time
+}
+
+// delay returns how long to wait at now before the next request.
+func (l *rateLimiter) delay(now time.Time) time.Duration {
+	if l == nil {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if d := l.notBefore.Sub(now); d > 0 {
+		return d
+	}
+	return 0
+}
+
+// clear drops any pending wait once it has been served.
+func (l *rateLimiter) clear() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.notBefore = time.Time{}
+}
+
+// hold delays requests until at least until.
+func (l *rateLimiter) hold(until time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if until.After(l.notBefore) {
+		l.notBefore = until
+	}
+}
+
+// observe records the pacing asked for by a response: Retry-After (or
+// Azure's retry-after-ms) on throttled and unavailable responses, and the
+// reset time of any request or token budget reported as exhausted through
+// the OpenAI x-ratelimit-* or Anthropic anthropic-ratelimit-* headers.
+// While a request budget runs low, requests are spread evenly over the
+// time left until it resets.
+func (l *rateLimiter) observe(h http.Header, status int, now time.Time) {
+	if l == nil {
+		return
+	}
+	if status == http.StatusTooManyRequests || status >= 500 {
+		if d, ok := parseRetryAfter(h, now); ok {
+			l.hold(now.Add(d))
+		}
+	}
+	for _, kind := range []string{"requests", "tokens", "input-tokens", "output-tokens"} {
+		remaining, reset, ok := rateLimitBudget(h, kind, now)
+		if !ok {
+			continue
+		}
+		switch {
+		case remaining == 0:
+			l.hold(now.Add(reset))
+		case kind == "requests":
+			l.hold(now.Add(reset / time.Duration(remaining+1)))
+		}
+	}
+}
+
+// parseRetryAfter reads retry-after-ms, or Retry-After as seconds or an
+// HTTP date.
+func parseRetryAfter(h http.Header, now time.Time) (time.Duration, bool) {
+	if v := strings.TrimSpace(h.Get("Retry-After-Ms")); v != "" {
+		if ms, err := strconv.ParseFloat(v, 64); err == nil && ms >= 0 {
+			return time.Duration(ms * float64(time.Millisecond)), true
+		}
+	}
+	v := strings.TrimSpace(h.Get("Retry-After"))
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.ParseFloat(v, 64); err == nil && secs >= 0 {
+		return time.Duration(secs * float64(time.Second)), true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(t.Sub(now), 0), true
+	}
+	return 0, false
+}
+
+// rateLimitBudget returns the remaining budget of one kind and the time
+// until it resets.
+func rateLimitBudget(h http.Header, kind string, now time.Time) (int, time.Duration, bool) {
+	remaining := h.Get("X-Ratelimit-Remaining-" + kind)
+	reset := h.Get("X-Ratelimit-Reset-" + kind)
+	if remaining == "" {
+		remaining = h.Get("Anthropic-Ratelimit-" + kind + "-Remaining")
+		reset = h.Get("Anthropic-Ratelimit-" + kind + "-Reset")
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(remaining))
+	if err != nil || n < 0 {
+		return 0, 0, false
+	}
+	d, ok := parseReset(strings.TrimSpace(reset), now)
+	if !ok {
+		return 0, 0, false
+	}
+	return n, d, true
+}
+
+// parseReset accepts OpenAI's durations ("1s", "6m0s", "250ms"), plain
+// seconds, and Anthropic's RFC 3339 timestamps.
+func parseReset(v string, now time.Time) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+		return d, true
+	}
+	if secs, err := strconv.ParseFloat(v, 64); err == nil && secs >= 0 {
+		return time.Duration(secs * float64(time.Second)), true
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return max(t.Sub(now), 0), true
+	}
+	return 0, false
+}
+
+// rateLimiters shares one rateLimiter per endpoint and credentials, so that
+// routed and fallback models calling the same endpoint with the same key
+// pace themselves against the same limits.
+type rateLimiters struct {
+	mu       sync.Mutex
+	limiters map[string]*rateLimiter
+}
+
+var defaultRateLimiters = &rateLimiters{}
+
+// get returns the limiter for requests to endpoint authenticated with
+// apiKey and headers, creating it on first use.
+func (r *rateLimiters) get(endpoint, apiKey string, headers http.Header) *rateLimiter {
+	key := rateLimitKey(endpoint, apiKey, headers)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.limiters == nil {
+		r.limiters = make(map[string]*rateLimiter)
+	}
+	l := r.limiters[key]
+	if l == nil {
+		l = &rateLimiter{}
+		r.limiters[key] = l
+	}
+	return l
+}
+
+// rateLimitKey identifies an endpoint and the credentials sent to it. The
+// credentials are hashed so that keys are not kept in the map.
+func rateLimitKey(endpoint, apiKey string, headers http.Header) string {
+	h := sha256.New()
+	h.Write([]byte(strings.TrimSpace(apiKey)))
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, http.CanonicalHeaderKey(name))
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		h.Write([]byte("\n" + name + ": " + strings.Join(headers.Values(name), ", ")))
+	}
+	return strings.TrimRight(endpoint, "/") + "|" + hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+// rateLimitTransport feeds response headers to a rateLimiter.
+type rateLimitTransport struct {
+	base    http.RoundTripper
+	limiter *rateLimiter
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err == nil {
+		t.limiter.observe(resp.Header, resp.StatusCode, time.Now())
+	}
+	return resp, err
+}
+
+// withRateLimits returns a copy of client whose responses update l.
+func withRateLimits(client *http.Client, l *rateLimiter) *http.Client {
+	var copied http.Client
+	if client != nil {
+		copied = *client
+	}
+	base := copied.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	copied.Transport = &rateLimitTransport{base: base, limiter: l}
+	return &copied
+}
@@ -0,0 +1,231 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterObserve(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	cases := []struct {
+		name   string
+		status int
+		header map[string]string
+		want   time.Duration
+	}{
+		{"retry-after seconds", 429, map[string]string{"Retry-After": "7"}, 7 * time.Second},
+		{"retry-after date", 503, map[string]string{"Retry-After": now.Add(90 * time.Second).Format(http.TimeFormat)}, 90 * time.Second},
+		{"retry-after-ms", 429, map[string]string{"Retry-After-Ms": "1500", "Retry-After": "2"}, 1500 * time.Millisecond},
+		{"retry-after ignored on success", 200, map[string]string{"Retry-After": "7"}, 0},
+		{"openai tokens exhausted", 200, map[string]string{"X-Ratelimit-Remaining-Tokens": "0", "X-Ratelimit-Reset-Tokens": "6m0s"}, 6 * time.Minute},
+		{"openai requests spread", 200, map[string]string{"X-Ratelimit-Remaining-Requests": "3", "X-Ratelimit-Reset-Requests": "2s"}, 500 * time.Millisecond},
+		{"anthropic requests exhausted", 200, map[string]string{"Anthropic-Ratelimit-Requests-Remaining": "0", "Anthropic-Ratelimit-Requests-Reset": now.Add(20 * time.Second).Format(time.RFC3339)}, 20 * time.Second},
+		{"unparsable headers", 429, map[string]string{"Retry-After": "soon", "X-Ratelimit-Remaining-Tokens": "0", "X-Ratelimit-Reset-Tokens": "later"}, 0},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := http.Header{}
+			for k, v := range tc.header {
+				h.Set(k, v)
+			}
+			l := &rateLimiter{}
+			l.observe(h, tc.status, now)
+			if got := l.delay(now); got != tc.want {
+				t.Fatalf("delay = %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRetryDelayIsCapped(t *testing.T) {
+	if got := retryDelay(1, time.Second, 30*time.Second); got != time.Second {
+		t.Fatalf("retryDelay(1) = %s", got)
+	}
+	if got := retryDelay(3, time.Second, 30*time.Second); got != 4*time.Second {
+		t.Fatalf("retryDelay(3) = %s", got)
+	}
+	if got := retryDelay(80, time.Second, 30*time.Second); got != 30*time.Second {
+		t.Fatalf("retryDelay(80) = %s", got)
+	}
+	for i := 0; i < 100; i++ {
+		if d := jitter(time.Second); d < 0 || d > time.Second {
+			t.Fatalf("jitter(1s) = %s", d)
+		}
+	}
+}
+
+func TestWithRetriesHonoursRetryAfter(t *testing.T) {
+	limiter := &rateLimiter{}
+	var slept []time.Duration
+	policy := retryPolicy{attempts: 3, base: time.Millisecond, max: time.Minute, limiter: limiter, sleep: func(_ context.Context, d time.Duration) error {
+		slept = append(slept, d)
+		return nil
+	}}
+	calls := 0
+	retries, err := withRetries(context.Background(), policy, shouldRetryStatus, func() error {
+		calls++
+		if calls == 1 {
+			limiter.observe(http.Header{"Retry-After": []string{"5"}}, 429, time.Now())
+			return &statusError{status: 429, msg: "slow down"}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("withRetries returned error: %v", err)
+	}
+	if len(slept) != 1 || slept[0] < 4*time.Second {
+		t.Fatalf("slept %v, want one wait of about 5s", slept)
+	}
+	if len(retries) != 1 || !retries[0].RetryAfter || retries[0].Status != 429 || retries[0].Attempt != 1 || retries[0].Error != "slow down" {
+		t.Fatalf("unexpected retries: %+v", retries)
+	}
+}
+
+func TestWithRetriesGivesUpOnLongRetryAfter(t *testing.T) {
+	limiter := &rateLimiter{}
+	policy := retryPolicy{attempts: 3, base: time.Millisecond, max: 10 * time.Second, limiter: limiter, sleep: noSleep}
+	calls := 0
+	retries, err := withRetries(context.Background(), policy, shouldRetryStatus, func() error {
+		calls++
+		limiter.observe(http.Header{"Retry-After": []string{"3600"}}, 429, time.Now())
+		return &statusError{status: 429, msg: "quota exhausted"}
+	})
+	if !errors.Is(err, ErrRateLimited) || calls != 1 || len(retries) != 0 {
+		t.Fatalf("err = %v after %d calls and %d retries", err, calls, len(retries))
+	}
+}
+
+func TestOpenAIModelPacesFromResponseHeaders(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		if calls == 1 {
+			w.Header().Set("Retry-After", "3")
+			w.WriteHeader(http.StatusTooManyRequests)
+			io.WriteString(w, `{"error":{"message":"Rate limit reached","type":"requests"}}`)
+			return
+		}
+		w.Header().Set("X-Ratelimit-Remaining-Tokens", "0")
+		w.Header().Set("X-Ratelimit-Reset-Tokens", "2s")
+		io.WriteString(w, gatewayReply)
+	}))
+	defer srv.Close()
+
+	model, err := NewOpenAIModel("key", WithBaseURL(srv.URL+"/v1"), WithTransport(TransportTool), WithRetry(RetryConfig{MaxAttempts: 2, BaseDelay: time.Millisecond}))
+	if err != nil {
+		t.Fatalf("NewOpenAIModel: %v", err)
+	}
+	var slept []time.Duration
+	model.sleep = func(_ context.Context, d time.Duration) error {
+		slept = append(slept, d)
+		return nil
+	}
+	result, err := model.ChooseOption(context.Background(), transportPrompt)
+	if err != nil {
+		t.Fatalf("ChooseOption: %v", err)
+	}
+	if len(result.Retries) != 1 || result.Retries[0].Status != 429 || !result.Retries[0].RetryAfter {
+		t.Fatalf("unexpected retries: %+v", result.Retries)
+	}
+	if len(slept) != 1 || slept[0] < 2*time.Second {
+		t.Fatalf("slept %v, want the Retry-After wait", slept)
+	}
+
+	// The exhausted token budget holds back the next request.
+	if _, err := model.ChooseOption(context.Background(), transportPrompt); err != nil {
+		t.Fatalf("second ChooseOption: %v", err)
+	}
+	if len(slept) != 2 || slept[1] < time.Second {
+		t.Fatalf("slept %v, want a pacing wait before the second request", slept)
+	}
+}
+
+func TestRetriesOfCollectsAcrossFallbacks(t *testing.T) {
+	first := withRetryLog(&statusError{status: 503, msg: "down"}, []Retry{{Attempt: 1, Status: 503}})
+	primary := &stubModel{err: first}
+	backup := &stubModel{name: "backup", index: routerIndex(0)}
+	model, err := NewFallbackModel([]Backend{{Name: "primary", Model: primary}, {Name: "backup", Model: backup}}, BreakerConfig{})
+	if err != nil {
+		t.Fatalf("NewFallbackModel: %v", err)
+	}
+	result, err := model.ChooseOption(context.Background(), transportPrompt)
+	if err != nil {
+		t.Fatalf("ChooseOption: %v", err)
+	}
+	if result.Backend != "backup" || len(result.Retries) != 1 || result.Retries[0].Status != 503 {
+		t.Fatalf("unexpected retries: %+v", result.Retries)
+	}
+}
+
+func TestOllamaModelHonoursRetryAfter(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.Header().Set("Retry-After", "3")
+			http.Error(w, `{"error":"server busy"}`, http.StatusServiceUnavailable)
+			return
+		}
+		io.WriteString(w, `{"message":{"role":"assistant","content":"{\"selection\":\"2\"}"},"done":true}`)
+	}))
+	defer srv.Close()
+
+	model, err := NewOllamaModel(WithBaseURL(srv.URL), WithRetry(RetryConfig{MaxAttempts: 2, BaseDelay: time.Millisecond}))
+	if err != nil {
+		t.Fatalf("NewOllamaModel: %v", err)
+	}
+	var slept []time.Duration
+	model.sleep = func(_ context.Context, d time.Duration) error {
+		slept = append(slept, d)
+		return nil
+	}
+	result, err := model.ChooseOption(context.Background(), transportPrompt)
+	if err != nil {
+		t.Fatalf("ChooseOption: %v", err)
+	}
+	if len(result.Retries) != 1 || !result.Retries[0].RetryAfter {
+		t.Fatalf("unexpected retries: %+v", result.Retries)
+	}
+	if len(slept) != 1 || slept[0] < 2*time.Second {
+		t.Fatalf("slept %v, want the Retry-After wait", slept)
+	}
+}
+
+func TestModelsShareRateLimiterPerEndpointAndKey(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	newModel := func(key, model string) *OpenAIModel {
+		m, err := NewOpenAIModel(key, WithBaseURL(srv.URL+"/v1"), WithModel(model))
+		if err != nil {
+			t.Fatalf("NewOpenAIModel: %v", err)
+		}
+		return m
+	}
+	small, large, other := newModel("key", "small"), newModel("key", "large"), newModel("other-key", "small")
+	if small.limiter != large.limiter {
+		t.Fatal("models on the same endpoint and key have separate limiters")
+	}
+	if small.limiter == other.limiter {
+		t.Fatal("models with different keys share a limiter")
+	}
+
+	anthropic, err := NewAnthropicModel("key", WithBaseURL(srv.URL+"/v1"))
+	if err != nil {
+		t.Fatalf("NewAnthropicModel: %v", err)
+	}
+	if anthropic.limiter != small.limiter {
+		t.Fatal("an Anthropic model on the same endpoint and key has a separate limiter")
+	}
+
+	small.limiter.observe(http.Header{"Retry-After": []string{"5"}}, 429, time.Now())
+	if large.limiter.delay(time.Now()) == 0 {
+		t.Fatal("a Retry-After seen by one model does not hold back the other")
+	}
+}
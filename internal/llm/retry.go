@@ -0,0 +1,204 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+)
+
+const (
+	defaultRetryBaseDelay = time.Second
+	defaultRetryMaxDelay  = 30 * time.Second
+)
+
+// RetryConfig controls how transient failures (HTTP 429 and 5xx) are
+// retried. Zero fields use the defaults: 3 attempts, a 1s base delay and a
+// 30s maximum delay.
+type RetryConfig struct {
+	// MaxAttempts is the number of requests made before giving up,
+	// including the first.
+	MaxAttempts int
+	// BaseDelay is the backoff cap before the first retry; it doubles for
+	// every further retry up to MaxDelay. The actual delay is drawn
+	// uniformly between zero and the cap.
+	BaseDelay time.Duration
+	// MaxDelay caps backoff delays and the waits requested by the
+	// endpoint. A Retry-After beyond it ends the retries instead.
+	MaxDelay time.Duration
+}
+
+func (c RetryConfig) withDefaults() RetryConfig {
+	if c.MaxAttempts <= 0 {
+		c.MaxAttempts = defaultMaxAttempts
+	}
+	if c.BaseDelay <= 0 {
+		c.BaseDelay = defaultRetryBaseDelay
+	}
+	if c.MaxDelay <= 0 {
+		c.MaxDelay = defaultRetryMaxDelay
+	}
+	if c.MaxDelay < c.BaseDelay {
+		c.MaxDelay = c.BaseDelay
+	}
+	return c
+}
+
+// Retry records one failed request that was retried.
+type Retry struct {
+	// Attempt is the 1-based number of the failed request.
+	Attempt int    `json:"attempt"`
+	Status  int    `json:"status,omitempty"`
+	Error   string `json:"error"`
+	// DelayMS is how long the retry waited, in milliseconds.
+	DelayMS int64 `json:"delay_ms"`
+	// RetryAfter is set when the endpoint chose the delay through
+	// Retry-After or rate-limit reset headers.
+	RetryAfter bool `json:"retry_after,omitempty"`
+}
+
+// retryLogError carries the retries made before a request finally failed,
+// so that a FallbackModel or RoutingModel answering afterwards can still
+// report them.
+type retryLogError struct {
+	err     error
+	retries []Retry
+}
+
+func (e *retryLogError) Error() string { return e.err.Error() }
+func (e *retryLogError) Unwrap() error { return e.err }
+
+func withRetryLog(err error, retries []Retry) error {
+	if err == nil || len(retries) == 0 {
+		return err
+	}
+	return &retryLogError{err: err, retries: retries}
+}
+
+// RetriesOf returns the retries recorded on err by the models, in order.
+func RetriesOf(err error) []Retry {
+	var out []Retry
+	var walk func(error)
+	walk = func(err error) {
+		switch e := err.(type) {
+		case nil:
+		case *retryLogError:
+			out = append(out, e.retries...)
+			walk(e.err)
+		case interface{ Unwrap() []error }:
+			for _, inner := range e.Unwrap() {
+				walk(inner)
+			}
+		case interface{ Unwrap() error }:
+			walk(e.Unwrap())
+		}
+	}
+	walk(err)
+	return out
+}
+
+// retryPolicy is a RetryConfig bound to a model's sleep function and rate
+// limiter.
+type retryPolicy struct {
+	attempts int
+	base     time.Duration
+	max      time.Duration
+	sleep    func(ctx context.Context, d time.Duration) error
+	limiter  *rateLimiter
+}
+
+// jitter draws a delay uniformly from [0, d] ("full jitter") so that
+// clients failing together do not retry together.
+var jitter = func(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	return rand.N(d + 1)
+}
+
+// withRetries calls fn up to p.attempts times while retryable reports the
+// error as transient. Before every call it waits for the rate limiter;
+// between calls it sleeps for a jittered exponential backoff, or for the
+// delay the endpoint asked for when that is longer. It returns the retries
+// it made alongside the final error.
+func withRetries(ctx context.Context, p retryPolicy, retryable func(error) bool, fn func() error) ([]Retry, error) {
+	if p.attempts < 1 {
+		p.attempts = 1
+	}
+	if p.max <= 0 {
+		p.max = defaultRetryMaxDelay
+	}
+	if p.sleep == nil {
+		p.sleep = sleepWithContext
+	}
+
+	var retries []Retry
+	var lastErr error
+	for attempt := 1; attempt <= p.attempts; attempt++ {
+		if wait := p.limiter.delay(time.Now()); wait > 0 {
+			if err := p.sleep(ctx, min(wait, p.max)); err != nil {
+				return retries, err
+			}
+		}
+		err := fn()
+		if err == nil {
+			return retries, nil
+		}
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return retries, err
+		}
+
+		lastErr = err
+		if attempt == p.attempts || !retryable(err) {
+			break
+		}
+
+		delay := jitter(retryDelay(attempt, p.base, p.max))
+		serverDelay := p.limiter.delay(time.Now())
+		if serverDelay > p.max {
+			// Waiting that long would stall the walk; let the caller or
+			// a fallback endpoint deal with the failure instead.
+			break
+		}
+		fromServer := serverDelay > delay
+		if fromServer {
+			delay = serverDelay
+		}
+		retries = append(retries, Retry{
+			Attempt:    attempt,
+			Status:     StatusCode(err),
+			Error:      err.Error(),
+			DelayMS:    delay.Milliseconds(),
+			RetryAfter: fromServer,
+		})
+		if err := p.sleep(ctx, delay); err != nil {
+			return retries, err
+		}
+		// The wait requested by the endpoint has been served.
+		p.limiter.clear()
+	}
+	return retries, lastErr
+}
+
+// retryDelay is the backoff cap before retry number attempt: base doubled
+// attempt-1 times, limited to max.
+func retryDelay(attempt int, base, max time.Duration) time.Duration {
+	if base <= 0 {
+		base = defaultRetryBaseDelay
+	}
+	if max <= 0 {
+		max = defaultRetryMaxDelay
+	}
+	if attempt < 1 {
+		attempt = 1
+	}
+
+	delay := base
+	for i := 1; i < attempt; i++ {
+		if delay >= max/2 {
+			return max
+		}
+		delay *= 2
+	}
+	return min(delay, max)
+}
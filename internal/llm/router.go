@@ -0,0 +1,154 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// RoutingRule sends a prompt to Model when the prompt is at least MinDepth
+// levels below the root and offers at least MinOptions candidates.
+type RoutingRule struct {
+	MinDepth   int
+	MinOptions int
+	Model      Model
+}
+
+func (r RoutingRule) matches(prompt Prompt) bool {
+	return len(prompt.Path) >= r.MinDepth && len(prompt.Options) >= r.MinOptions
+}
+
+// RoutingModel chooses a backing Model per prompt. The first matching rule
+// wins; prompts that match no rule go to the fallback model. When an
+// escalation model is configured, a "none of these" answer, an invalid
+// selection or a transient failure is retried once against it.
+type RoutingModel struct {
+	fallback Model
+	rules    []RoutingRule
+	escalate Model
+}
+
+func NewRoutingModel(fallback Model, rules []RoutingRule, escalate Model) (*RoutingModel, error) {
+	if fallback == nil {
+		return nil, errors.New("routing model needs a fallback model")
+	}
+	for i, rule := range rules {
+		if rule.Model == nil {
+			return nil, fmt.Errorf("routing rule %d has no model", i+1)
+		}
+	}
+	return &RoutingModel{fallback: fallback, rules: rules, escalate: escalate}, nil
+}
+
+func (r *RoutingModel) route(prompt Prompt) Model {
+	for _, rule := range r.rules {
+		if rule.matches(prompt) {
+			return rule.Model
+		}
+	}
+	return r.fallback
+}
+
+func (r *RoutingModel) ChooseOption(ctx context.Context, prompt Prompt) (*Result, error) {
+	if r == nil {
+		return nil, errors.New("model is nil")
+	}
+	model := r.route(prompt)
+	result, err := model.ChooseOption(ctx, prompt)
+	if r.escalate == nil || r.escalate == model {
+		return result, err
+	}
+	if err != nil && !escalates(err) {
+		return nil, err
+	}
+	if err == nil && result.ChoiceIndex != nil {
+		return result, nil
+	}
+
+	retry, retryErr := r.escalate.ChooseOption(ctx, prompt)
+	if retryErr != nil {
+		// Only a transient failure of the escalation model leaves the
+		// first answer standing; a budget, credential or refusal error
+		// must not turn into a quiet "none of these".
+		if !escalates(retryErr) {
+			return nil, retryErr
+		}
+		if err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
+	if err != nil {
+		retry.Retries = append(RetriesOf(err), retry.Retries...)
+	}
+	if result != nil {
+		usage := make(map[string]Usage)
+		for name, u := range result.ModelUsage() {
+			usage[name] = usage[name].Add(u)
+		}
+		for name, u := range retry.ModelUsage() {
+			usage[name] = usage[name].Add(u)
+		}
+		retry.UsageByModel = usage
+		retry.Usage = retry.Usage.Add(result.Usage)
+		retry.Retries = append(append([]Retry(nil), result.Retries...), retry.Retries...)
+	}
+	return retry, nil
+}
+
+// escalates reports whether a failed call is worth retrying against the
+// escalation model: an invalid selection, or a rate limit, outage or
+// request timeout. Credential, request and budget errors, refusals and the
+// caller's own deadline would not fare better there.
+func escalates(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	switch Classify(err) {
+	case ErrInvalidResponse, ErrRateLimited, ErrUnavailable, ErrTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// RouteSpec is a parsed --route value: a condition and the model name that
+// should serve prompts meeting it.
+type RouteSpec struct {
+	MinDepth   int
+	MinOptions int
+	Escalate   bool
+	Model      string
+}
+
+// ParseRouteSpec parses "depth>=N:MODEL", "options>=N:MODEL" or
+// "retry:MODEL".
+func ParseRouteSpec(spec string) (RouteSpec, error) {
+	cond, model, ok := strings.Cut(strings.TrimSpace(spec), ":")
+	model = strings.TrimSpace(model)
+	if !ok || model == "" {
+		return RouteSpec{}, fmt.Errorf("route %q must have the form CONDITION:MODEL", spec)
+	}
+	cond = strings.ToLower(strings.ReplaceAll(cond, " ", ""))
+	if cond == "retry" {
+		return RouteSpec{Escalate: true, Model: model}, nil
+	}
+	key, value, ok := strings.Cut(cond, ">=")
+	if !ok {
+		return RouteSpec{}, fmt.Errorf("route %q has unsupported condition %q", spec, cond)
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return RouteSpec{}, fmt.Errorf("route %q has invalid threshold %q", spec, value)
+	}
+	switch key {
+	case "depth":
+		return RouteSpec{MinDepth: n, Model: model}, nil
+	case "options":
+		return RouteSpec{MinOptions: n, Model: model}, nil
+	default:
+		return RouteSpec{}, fmt.Errorf("route %q has unsupported condition %q", spec, key)
+	}
+}
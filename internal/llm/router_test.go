@@ -0,0 +1,211 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type stubModel struct {
+	name   string
+	index  *int
+	err    error
+	calls  int
+	tokens int
+}
+
+func (s *stubModel) ChooseOption(_ context.Context, _ Prompt) (*Result, error) {
+	s.calls++
+	if s.err != nil {
+		return nil, s.err
+	}
+	result := &Result{Choice: "none of these", ChoiceIndex: s.index, Model: s.name, Usage: Usage{TotalTokens: s.tokens}}
+	if s.index != nil {
+		result.Choice = "picked"
+	}
+	return result, nil
+}
+
+func routerIndex(v int) *int {
+	return &v
+}
+
+func TestRoutingModelRoutesByDepthAndOptions(t *testing.T) {
+	cheap := &stubModel{name: "cheap", index: routerIndex(0)}
+	deep := &stubModel{name: "deep", index: routerIndex(0)}
+	wide := &stubModel{name: "wide", index: routerIndex(0)}
+	router, err := NewRoutingModel(cheap, []RoutingRule{
+		{MinDepth: 3, Model: deep},
+		{MinOptions: 3, Model: wide},
+	}, nil)
+	if err != nil {
+		t.Fatalf("NewRoutingModel returned error: %v", err)
+	}
+
+	cases := []struct {
+		path    []string
+		options int
+		want    string
+	}{
+		{nil, 2, "cheap"},
+		{nil, 5, "wide"},
+		{[]string{"a", "b", "c"}, 2, "deep"},
+		{[]string{"a", "b", "c"}, 5, "deep"},
+	}
+	for _, tc := range cases {
+		result, err := router.ChooseOption(context.Background(), Prompt{Path: tc.path, Options: make([]Option, tc.options)})
+		if err != nil {
+			t.Fatalf("ChooseOption returned error: %v", err)
+		}
+		if result.Model != tc.want {
+			t.Fatalf("depth %d, %d options routed to %q, want %q", len(tc.path), tc.options, result.Model, tc.want)
+		}
+	}
+}
+
+func TestRoutingModelEscalatesNoneOfThese(t *testing.T) {
+	cheap := &stubModel{name: "cheap", tokens: 10}
+	strong := &stubModel{name: "strong", index: routerIndex(1), tokens: 30}
+	router, err := NewRoutingModel(cheap, nil, strong)
+	if err != nil {
+		t.Fatalf("NewRoutingModel returned error: %v", err)
+	}
+	result, err := router.ChooseOption(context.Background(), Prompt{Options: make([]Option, 2)})
+	if err != nil {
+		t.Fatalf("ChooseOption returned error: %v", err)
+	}
+	if result.Model != "strong" || result.ChoiceIndex == nil || *result.ChoiceIndex != 1 {
+		t.Fatalf("unexpected escalated result: %#v", result)
+	}
+	if result.Usage.TotalTokens != 40 {
+		t.Fatalf("total tokens = %d, want 40", result.Usage.TotalTokens)
+	}
+	byModel := result.ModelUsage()
+	if byModel["cheap"].TotalTokens != 10 || byModel["strong"].TotalTokens != 30 {
+		t.Fatalf("unexpected per-model usage: %#v", byModel)
+	}
+}
+
+func TestRoutingModelEscalatesErrors(t *testing.T) {
+	for _, cause := range []error{
+		invalidResponse("bad selection"),
+		&statusError{status: 429, msg: "rate limited"},
+		&statusError{status: 503, msg: "unavailable"},
+	} {
+		cheap := &stubModel{name: "cheap", err: cause}
+		strong := &stubModel{name: "strong", index: routerIndex(0)}
+		router, err := NewRoutingModel(cheap, nil, strong)
+		if err != nil {
+			t.Fatalf("NewRoutingModel returned error: %v", err)
+		}
+		result, err := router.ChooseOption(context.Background(), Prompt{Options: make([]Option, 1)})
+		if err != nil {
+			t.Fatalf("%v: ChooseOption returned error: %v", cause, err)
+		}
+		if result.Model != "strong" {
+			t.Fatalf("%v: result model = %q, want strong", cause, result.Model)
+		}
+	}
+}
+
+func TestRoutingModelDoesNotEscalatePermanentErrors(t *testing.T) {
+	for _, cause := range []error{
+		&statusError{status: 401, msg: "invalid api key"},
+		&statusError{status: 400, msg: "unknown model"},
+		&RefusalError{Reason: "no"},
+		errors.New("token budget exhausted"),
+		context.Canceled,
+	} {
+		cheap := &stubModel{name: "cheap", err: cause}
+		strong := &stubModel{name: "strong", index: routerIndex(0)}
+		router, err := NewRoutingModel(cheap, nil, strong)
+		if err != nil {
+			t.Fatalf("NewRoutingModel returned error: %v", err)
+		}
+		if _, err := router.ChooseOption(context.Background(), Prompt{Options: make([]Option, 1)}); !errors.Is(err, cause) {
+			t.Fatalf("ChooseOption error = %v, want %v", err, cause)
+		}
+		if strong.calls != 0 {
+			t.Fatalf("%v: escalation model called %d times, want 0", cause, strong.calls)
+		}
+	}
+}
+
+func TestRoutingModelEscalationFailures(t *testing.T) {
+	budget := errors.New("token budget exhausted")
+	cases := []struct {
+		name     string
+		cheapErr error
+		retryErr error
+		wantErr  error
+	}{
+		// After "none of these", a transient failure keeps the answer.
+		{name: "none, transient", retryErr: &statusError{status: 503, msg: "unavailable"}},
+		{name: "none, invalid", retryErr: invalidResponse("bad selection")},
+		// Permanent failures of the escalation model are reported.
+		{name: "none, budget", retryErr: budget, wantErr: budget},
+		{name: "none, auth", retryErr: &statusError{status: 401, msg: "invalid api key"}, wantErr: ErrAuth},
+		{name: "none, refusal", retryErr: &RefusalError{Reason: "no"}, wantErr: ErrRefusal},
+		{name: "none, cancelled", retryErr: context.Canceled, wantErr: context.Canceled},
+		// After a transient error, the first error stands unless the
+		// escalation failed permanently.
+		{name: "error, transient", cheapErr: &statusError{status: 429, msg: "rate limited"}, retryErr: &statusError{status: 503, msg: "unavailable"}, wantErr: ErrRateLimited},
+		{name: "error, budget", cheapErr: &statusError{status: 429, msg: "rate limited"}, retryErr: budget, wantErr: budget},
+	}
+	for _, tc := range cases {
+		cheap := &stubModel{name: "cheap", err: tc.cheapErr}
+		strong := &stubModel{name: "strong", err: tc.retryErr}
+		router, err := NewRoutingModel(cheap, nil, strong)
+		if err != nil {
+			t.Fatalf("NewRoutingModel returned error: %v", err)
+		}
+		result, err := router.ChooseOption(context.Background(), Prompt{Options: make([]Option, 2)})
+		if tc.wantErr != nil {
+			if !errors.Is(err, tc.wantErr) {
+				t.Errorf("%s: error = %v, want %v", tc.name, err, tc.wantErr)
+			}
+			continue
+		}
+		if err != nil || result.Model != "cheap" || result.ChoiceIndex != nil {
+			t.Errorf("%s: got %#v, %v; want the cheap none-of-these answer", tc.name, result, err)
+		}
+	}
+}
+
+func TestRoutingModelKeepsConfidentAnswers(t *testing.T) {
+	cheap := &stubModel{name: "cheap", index: routerIndex(0)}
+	strong := &stubModel{name: "strong", index: routerIndex(1)}
+	router, err := NewRoutingModel(cheap, nil, strong)
+	if err != nil {
+		t.Fatalf("NewRoutingModel returned error: %v", err)
+	}
+	if _, err := router.ChooseOption(context.Background(), Prompt{Options: make([]Option, 2)}); err != nil {
+		t.Fatalf("ChooseOption returned error: %v", err)
+	}
+	if strong.calls != 0 {
+		t.Fatalf("escalation model called %d times, want 0", strong.calls)
+	}
+}
+
+func TestParseRouteSpec(t *testing.T) {
+	cases := map[string]RouteSpec{
+		"depth>=3:gpt-5.4":      {MinDepth: 3, Model: "gpt-5.4"},
+		"options >= 40:gpt-5.4": {MinOptions: 40, Model: "gpt-5.4"},
+		"retry:gpt-5.4":         {Escalate: true, Model: "gpt-5.4"},
+		"depth>=2:llama3:8b":    {MinDepth: 2, Model: "llama3:8b"},
+	}
+	for spec, want := range cases {
+		got, err := ParseRouteSpec(spec)
+		if err != nil {
+			t.Fatalf("ParseRouteSpec(%q) returned error: %v", spec, err)
+		}
+		if got != want {
+			t.Fatalf("ParseRouteSpec(%q) = %#v, want %#v", spec, got, want)
+		}
+	}
+	for _, bad := range []string{"gpt-5.4", "depth>3:gpt", "depth>=x:gpt", "level>=2:gpt", "retry:"} {
+		if _, err := ParseRouteSpec(bad); err == nil {
+			t.Fatalf("ParseRouteSpec(%q) expected error", bad)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package llm
+
+import (
+	"bytes"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+)
+
+//go:embed prompts/default.tmpl
+var defaultTemplateSource string
+
+var defaultTemplate *PromptTemplate
+
+func init() {
+	defaultTemplate = mustParsePromptTemplate("default", defaultTemplateSource)
+}
+
+// PromptTemplate renders the messages sent for each taxonomy level.
+// Templates use text/template and must define a "system" and a "user"
+// template. An optional "context" template is sent between the two and
+// holds the level-independent part of the prompt.
+type PromptTemplate struct {
+	name    string
+	version string
+	tmpl    *template.Template
+}
+
+// Example is a labelled product offered to the model as guidance.
+type Example struct {
+	Description string `json:"description"`
+	Category    string `json:"category"`
+}
+
+// templateData is the value templates are executed with.
+type templateData struct {
+	Description string
+	Path        []string
+	Options     []templateOption
+	Examples    []Example
+	Locale      string
+	Taxonomy    string
+	Images      int
+	Instruction string
+}
+
+type templateOption struct {
+	Number      int
+	Label       string
+	Name        string
+	FullName    string
+	ID          string
+	Description string
+	Aliases     []string
+}
+
+// DefaultPromptTemplate returns the built-in prompt template.
+func DefaultPromptTemplate() *PromptTemplate {
+	return defaultTemplate
+}
+
+// LoadPromptTemplate parses the template file at path.
+func LoadPromptTemplate(path string) (*PromptTemplate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read prompt template: %w", err)
+	}
+	return ParsePromptTemplate(path, string(data))
+}
+
+// ParsePromptTemplate parses source as a prompt template and checks that it
+// renders against a sample prompt.
+func ParsePromptTemplate(name, source string) (*PromptTemplate, error) {
+	tmpl, err := template.New(name).Funcs(template.FuncMap{
+		"join":  strings.Join,
+		"lower": strings.ToLower,
+		"upper": strings.ToUpper,
+		"trim":  strings.TrimSpace,
+	}).Option("missingkey=error").Parse(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse prompt template %s: %w", name, err)
+	}
+	for _, part := range []string{"system", "user"} {
+		if tmpl.Lookup(part) == nil {
+			return nil, fmt.Errorf("prompt template %s does not define %q", name, part)
+		}
+	}
+	sum := sha256.Sum256([]byte(source))
+	t := &PromptTemplate{name: name, version: hex.EncodeToString(sum[:])[:12], tmpl: tmpl}
+	if _, err := t.render(Prompt{Description: "sample", Options: []Option{{Name: "sample", ID: "sample"}}}, toolInstruction); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func mustParsePromptTemplate(name, source string) *PromptTemplate {
+	t, err := ParsePromptTemplate(name, source)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// Name returns the file name, or "default" for the built-in template.
+func (t *PromptTemplate) Name() string {
+	if t == nil {
+		return defaultTemplate.name
+	}
+	return t.name
+}
+
+// Version is a short hash of the template source, recorded alongside
+// results so that they can be traced back to the prompt that produced them.
+func (t *PromptTemplate) Version() string {
+	if t == nil {
+		return defaultTemplate.version
+	}
+	return t.version
+}
+
+// renderedPrompt holds the messages of one request. system and context are
+// the same at every level of a walk, which keeps the request prefix
+// cacheable; user carries the per-level path and candidates.
+type renderedPrompt struct {
+	system  string
+	context string
+	user    string
+}
+
+// fingerprint returns a short hash of the messages and images.
+func (r renderedPrompt) fingerprint(images []Image) string {
+	h := sha256.New()
+	for _, part := range []string{r.system, r.context, r.user} {
+		fmt.Fprintf(h, "%d:%s", len(part), part)
+	}
+	for _, img := range images {
+		fmt.Fprintf(h, "%d:%s", len(img.URL), img.URL)
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// render executes the template for prompt. instruction tells the model how
+// to return its selection.
+func (t *PromptTemplate) render(prompt Prompt, instruction string) (renderedPrompt, error) {
+	if t == nil {
+		t = defaultTemplate
+	}
+	data := templateData{
+		Description: prompt.Description,
+		Path:        prompt.Path,
+		Examples:    prompt.Examples,
+		Locale:      prompt.Locale,
+		Taxonomy:    prompt.Taxonomy,
+		Images:      len(prompt.Images),
+		Instruction: instruction,
+	}
+	for i, opt := range prompt.Options {
+		label := opt.FullName
+		if strings.TrimSpace(label) == "" {
+			label = opt.Name
+		}
+		if strings.TrimSpace(label) == "" {
+			label = opt.ID
+		}
+		data.Options = append(data.Options, templateOption{
+			Number:      i + 1,
+			Label:       label,
+			Name:        opt.Name,
+			FullName:    opt.FullName,
+			ID:          strings.TrimSpace(opt.ID),
+			Description: strings.TrimSpace(opt.Description),
+			Aliases:     opt.Aliases,
+		})
+	}
+
+	var out renderedPrompt
+	for _, part := range []struct {
+		name string
+		dst  *string
+	}{{"system", &out.system}, {"context", &out.context}, {"user", &out.user}} {
+		if t.tmpl.Lookup(part.name) == nil {
+			continue
+		}
+		var buf bytes.Buffer
+		if err := t.tmpl.ExecuteTemplate(&buf, part.name, data); err != nil {
+			return renderedPrompt{}, fmt.Errorf("failed to render prompt template %s: %w", t.name, err)
+		}
+		*part.dst = strings.TrimSpace(buf.String())
+	}
+	return out, nil
+}
+
+// LoadExamples reads a JSON array of examples for prompt templates.
+func LoadExamples(path string) ([]Example, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read prompt examples: %w", err)
+	}
+	var examples []Example
+	if err := json.Unmarshal(data, &examples); err != nil {
+		return nil, fmt.Errorf("failed to parse prompt examples %s: %w", path, err)
+	}
+	for i, ex := range examples {
+		if strings.TrimSpace(ex.Description) == "" || strings.TrimSpace(ex.Category) == "" {
+			return nil, fmt.Errorf("prompt example %d in %s needs a description and a category", i+1, path)
+		}
+	}
+	return examples, nil
+}
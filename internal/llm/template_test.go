@@ -0,0 +1,128 @@
+package llm
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDefaultPromptTemplateKeepsContextStableAcrossLevels(t *testing.T) {
+	base := Prompt{
+		Description: "red shoe",
+		Images:      []Image{{URL: "https://example.com/shoe.jpg"}},
+		Locale:      "fr",
+		Examples:    []Example{{Description: "canvas backpack", Category: "Luggage & Bags > Backpacks"}},
+	}
+	root := base
+	root.Options = []Option{{Name: "Apparel", ID: "aa"}, {Name: "Luggage & Bags", ID: "lb"}}
+	leaf := base
+	leaf.Path = []string{"Apparel", "Shoes"}
+	leaf.Options = []Option{{Name: "Boots", FullName: "Apparel > Shoes > Boots", ID: "aa-1"}, {Name: "Sneakers"}}
+
+	first, err := DefaultPromptTemplate().render(root, toolInstruction)
+	if err != nil {
+		t.Fatalf("render returned error: %v", err)
+	}
+	second, err := DefaultPromptTemplate().render(leaf, toolInstruction)
+	if err != nil {
+		t.Fatalf("render returned error: %v", err)
+	}
+	if first.system != "You classify Shopify products." {
+		t.Fatalf("system = %q", first.system)
+	}
+	if first.context != second.context {
+		t.Fatalf("context differs between levels:\n%q\n%q", first.context, second.context)
+	}
+	for _, want := range []string{toolInstruction, "red shoe", "locale fr", "- canvas backpack => Luggage & Bags > Backpacks", "Product images are attached"} {
+		if !strings.Contains(first.context, want) {
+			t.Fatalf("context missing %q:\n%s", want, first.context)
+		}
+	}
+	if strings.Contains(first.user, "red shoe") {
+		t.Fatalf("per-level message repeats the description:\n%s", first.user)
+	}
+	want := "Current category path: Apparel > Shoes\nCandidate categories:\n1. Apparel > Shoes > Boots (id: aa-1)\n2. Sneakers"
+	if second.user != want {
+		t.Fatalf("user prompt =\n%q\nwant\n%q", second.user, want)
+	}
+}
+
+func TestDefaultPromptTemplateNamesTaxonomy(t *testing.T) {
+	rendered, err := DefaultPromptTemplate().render(Prompt{Description: "red shoe", Taxonomy: "google"}, toolInstruction)
+	if err != nil {
+		t.Fatalf("render returned error: %v", err)
+	}
+	if rendered.system != "You classify products into the Google product taxonomy." {
+		t.Fatalf("system = %q", rendered.system)
+	}
+	if !strings.Contains(rendered.context, "expert Google product taxonomy classifier") {
+		t.Fatalf("context does not name the Google taxonomy:\n%s", rendered.context)
+	}
+
+	rendered, err = DefaultPromptTemplate().render(Prompt{Description: "red shoe", Taxonomy: "csv"}, toolInstruction)
+	if err != nil {
+		t.Fatalf("render returned error: %v", err)
+	}
+	if rendered.system != "You classify products into a product taxonomy." || strings.Contains(rendered.context, "Shopify") {
+		t.Fatalf("custom taxonomy prompt mentions Shopify:\n%s\n%s", rendered.system, rendered.context)
+	}
+}
+
+func TestDefaultPromptTemplateShowsOverlayAnnotations(t *testing.T) {
+	prompt := Prompt{
+		Description: "jute shopper",
+		Options: []Option{
+			{Name: "Tote Bags", ID: "lb-1", Description: "Open-top bags with two parallel handles"},
+			{Name: "Shoulder Bags", ID: "lb-2", Aliases: []string{"Crossbody Bags", "Messenger Bags"}},
+		},
+	}
+	rendered, err := DefaultPromptTemplate().render(prompt, toolInstruction)
+	if err != nil {
+		t.Fatalf("render returned error: %v", err)
+	}
+	want := "Start at the top level of the taxonomy.\nCandidate categories:\n" +
+		"1. Tote Bags (id: lb-1) - Open-top bags with two parallel handles\n" +
+		"2. Shoulder Bags (id: lb-2) (also: Crossbody Bags, Messenger Bags)"
+	if rendered.user != want {
+		t.Fatalf("user prompt =\n%q\nwant\n%q", rendered.user, want)
+	}
+}
+
+func TestLoadPromptTemplate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "prompt.tmpl")
+	source := `{{define "system"}}Classify.{{end}}{{define "user"}}{{.Description}}: {{range .Options}}{{.Number}}={{.Name}} {{end}}{{.Instruction}}{{end}}`
+	if err := os.WriteFile(path, []byte(source), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tmpl, err := LoadPromptTemplate(path)
+	if err != nil {
+		t.Fatalf("LoadPromptTemplate returned error: %v", err)
+	}
+	if tmpl.Version() == DefaultPromptTemplate().Version() || len(tmpl.Version()) != 12 {
+		t.Fatalf("version = %q, want a distinct 12-character hash", tmpl.Version())
+	}
+	rendered, err := tmpl.render(Prompt{Description: "mug", Options: []Option{{Name: "Cups"}}}, textInstruction)
+	if err != nil {
+		t.Fatalf("render returned error: %v", err)
+	}
+	if rendered.context != "" {
+		t.Fatalf("context = %q, want empty for a template without one", rendered.context)
+	}
+	if want := "mug: 1=Cups " + textInstruction; rendered.user != want {
+		t.Fatalf("user = %q, want %q", rendered.user, want)
+	}
+}
+
+func TestParsePromptTemplateErrors(t *testing.T) {
+	tests := map[string]string{
+		"missing user": `{{define "system"}}x{{end}}`,
+		"bad syntax":   `{{define "system"}}x{{end}}{{define "user"}}{{.Description{{end}}`,
+		"bad field":    `{{define "system"}}x{{end}}{{define "user"}}{{.Title}}{{end}}`,
+	}
+	for name, source := range tests {
+		if _, err := ParsePromptTemplate(name, source); err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package llm
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Transport is the mechanism used to constrain the model's selection.
+type Transport string
+
+const (
+	// TransportAuto tries each transport in turn, remembering the first one
+	// the endpoint accepts.
+	TransportAuto Transport = "auto"
+	// TransportTool forces a call to the selection tool.
+	TransportTool Transport = "tool"
+	// TransportJSONSchema requests a response_format with a strict JSON schema.
+	TransportJSONSchema Transport = "json_schema"
+	// TransportText asks for a bare answer and parses the reply text.
+	TransportText Transport = "text"
+)
+
+// autoTransports is the order in which TransportAuto tries transports, from
+// most to least constrained.
+var autoTransports = []Transport{TransportTool, TransportJSONSchema, TransportText}
+
+// ParseTransport validates a transport name. An empty name means auto.
+func ParseTransport(name string) (Transport, error) {
+	switch t := Transport(strings.ToLower(strings.TrimSpace(name))); t {
+	case "", TransportAuto:
+		return TransportAuto, nil
+	case "json":
+		return TransportJSONSchema, nil
+	case TransportTool, TransportJSONSchema, TransportText:
+		return t, nil
+	default:
+		return "", fmt.Errorf("unknown selection transport %q (want auto, tool, json_schema or text)", name)
+	}
+}
+
+// transportCache remembers, per endpoint and model, the transport to start
+// with once the endpoint has rejected the ones before it, so that later
+// requests skip them.
+type transportCache struct {
+	mu      sync.Mutex
+	entries map[string]Transport
+}
+
+var defaultTransportCache = &transportCache{}
+
+func (c *transportCache) get(key string) (Transport, bool) {
+	if c == nil {
+		return "", false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t, ok := c.entries[key]
+	return t, ok
+}
+
+func (c *transportCache) set(key string, t Transport) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]Transport)
+	}
+	c.entries[key] = t
+}
+
+// transportOrder returns the transports to try, starting at the cached one
+// when auto-detection has already settled on a transport for key.
+func transportOrder(configured Transport, cache *transportCache, key string) []Transport {
+	if configured != "" && configured != TransportAuto {
+		return []Transport{configured}
+	}
+	if cached, ok := cache.get(key); ok {
+		for i, t := range autoTransports {
+			if t == cached {
+				return autoTransports[i:]
+			}
+		}
+	}
+	return autoTransports
+}
+
+// capabilityError marks a failure caused by the endpoint not supporting a
+// selection transport, as opposed to a failure of the request itself.
+type capabilityError struct {
+	err error
+}
+
+func (e *capabilityError) Error() string { return e.err.Error() }
+func (e *capabilityError) Unwrap() error { return e.err }
+
+func isCapabilityError(err error) bool {
+	var capErr *capabilityError
+	return errors.As(err, &capErr)
+}
+
+// ignoredError marks a reply that lacks the requested structure
+// altogether, such as plain text in answer to a forced tool call. Auto
+// detection tries the next transport for that prompt but does not remember
+// it, since a single such reply may be a fluke.
+type ignoredError struct {
+	err error
+}
+
+func (e *ignoredError) Error() string { return e.err.Error() }
+func (e *ignoredError) Unwrap() error { return e.err }
+
+func isIgnoredError(err error) bool {
+	var ignored *ignoredError
+	return errors.As(err, &ignored)
+}
+
+// transportKey identifies an endpoint and model in a transportCache.
+func transportKey(endpoint, model string) string {
+	return endpoint + "|" + model
+}
+
+// rejectsTransport reports whether a failed request looks like the endpoint
+// refusing the tool or response_format parameters rather than the prompt.
+func rejectsTransport(err error) bool {
+	var status interface{ StatusCode() int }
+	if !errors.As(err, &status) {
+		return false
+	}
+	switch status.StatusCode() {
+	case 400, 404, 422, 501:
+	default:
+		return false
+	}
+	msg := strings.ToLower(err.Error())
+	for _, hint := range []string{"tool", "function", "response_format", "json_schema", "structured output"} {
+		if strings.Contains(msg, hint) {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	// selectionNumberPattern matches a number that is the whole reply or
+	// leads it, optionally after a word such as "option", so that option
+	// names like "3D Printers" or "2-in-1 Laptops" are not read as numbers.
+	selectionNumberPattern = regexp.MustCompile(`^(?:(?:option|category|choice|number)\s*|#)?(\d+)(?:$|[\s.):,])`)
+	selectionNonePattern   = regexp.MustCompile(`\bnone([ _-]of[ _-]these)?\b`)
+)
+
+// parseTextSelection extracts a selection from a free-form reply such as
+// "3", "Option 3.", "none of these" or a JSON object. Replies that name an
+// option instead of numbering it are matched against the option labels
+// first.
+func parseTextSelection(content string, options []Option) (string, error) {
+	text := strings.TrimSpace(content)
+	if text == "" {
+		return "", invalidResponse("model returned an empty reply")
+	}
+	if strings.HasPrefix(text, "{") {
+		if selection, err := parseSelectionArgs(text); err == nil {
+			return selection, nil
+		}
+	}
+	lower := strings.ToLower(strings.Trim(text, "*`\"'. \n"))
+	for i, opt := range options {
+		for _, label := range append([]string{opt.ID, opt.FullName, opt.Name}, opt.Aliases...) {
+			if strings.TrimSpace(label) != "" && strings.EqualFold(strings.TrimSpace(label), lower) {
+				return strconv.Itoa(i + 1), nil
+			}
+		}
+	}
+	if selectionNonePattern.MatchString(lower) {
+		return noneSelection, nil
+	}
+	if match := selectionNumberPattern.FindStringSubmatch(lower); match != nil {
+		if n, err := strconv.Atoi(match[1]); err == nil && n >= 1 && n <= len(options)+1 {
+			return normalizeSelection(match[1], len(options)), nil
+		}
+	}
+	return "", invalidResponsef("could not find a selection in model reply %q", truncate(text, 80))
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
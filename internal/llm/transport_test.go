@@ -0,0 +1,226 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+type recordingChatCompletionClient struct {
+	fakeChatCompletionClient
+	requests []openai.ChatCompletionRequest
+}
+
+func (r *recordingChatCompletionClient) CreateChatCompletion(ctx context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	r.requests = append(r.requests, req)
+	return r.fakeChatCompletionClient.CreateChatCompletion(ctx, req)
+}
+
+func requestTransport(req openai.ChatCompletionRequest) Transport {
+	switch {
+	case len(req.Tools) > 0:
+		return TransportTool
+	case req.ResponseFormat != nil:
+		return TransportJSONSchema
+	default:
+		return TransportText
+	}
+}
+
+func contentReply(content string, tokens int) fakeChatCompletionResult {
+	return fakeChatCompletionResult{resp: openai.ChatCompletionResponse{
+		Choices: []openai.ChatCompletionChoice{{Message: openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: content}}},
+		Usage:   openai.Usage{PromptTokens: tokens, TotalTokens: tokens},
+	}}
+}
+
+var transportPrompt = Prompt{
+	Description: "leather handbag",
+	Options:     []Option{{Name: "Shoes", ID: "aa-1"}, {Name: "Handbags", ID: "aa-2"}},
+}
+
+func TestChooseOptionFallsBackWhenToolsRejected(t *testing.T) {
+	client := &recordingChatCompletionClient{fakeChatCompletionClient: fakeChatCompletionClient{
+		responses: []fakeChatCompletionResult{
+			{err: &openai.APIError{HTTPStatusCode: 400, Message: "tools are not supported by this endpoint"}},
+			contentReply(`{"selection":"2"}`, 10),
+			contentReply(`{"selection":"1"}`, 10),
+		},
+	}}
+	cache := &transportCache{}
+	model := &OpenAIModel{client: client, model: "m", endpoint: "https://gateway", transports: cache, maxAttempts: 1, retryBaseDelay: time.Millisecond, sleep: sleepWithContext}
+
+	result, err := model.ChooseOption(context.Background(), transportPrompt)
+	if err != nil {
+		t.Fatalf("ChooseOption returned error: %v", err)
+	}
+	if result.Choice != "aa-2" {
+		t.Fatalf("choice = %q, want aa-2", result.Choice)
+	}
+	if got, _ := cache.get("https://gateway|m"); got != TransportJSONSchema {
+		t.Fatalf("cached transport = %q, want %q", got, TransportJSONSchema)
+	}
+
+	if _, err := model.ChooseOption(context.Background(), transportPrompt); err != nil {
+		t.Fatalf("second ChooseOption returned error: %v", err)
+	}
+	want := []Transport{TransportTool, TransportJSONSchema, TransportJSONSchema}
+	if len(client.requests) != len(want) {
+		t.Fatalf("requests = %d, want %d", len(client.requests), len(want))
+	}
+	for i, req := range client.requests {
+		if got := requestTransport(req); got != want[i] {
+			t.Fatalf("request %d transport = %q, want %q", i, got, want[i])
+		}
+	}
+	if client.requests[1].ResponseFormat.JSONSchema == nil || !client.requests[1].ResponseFormat.JSONSchema.Strict {
+		t.Fatal("json_schema request is not strict")
+	}
+}
+
+func TestChooseOptionFallsBackToTextWhenStructureIgnored(t *testing.T) {
+	client := &recordingChatCompletionClient{fakeChatCompletionClient: fakeChatCompletionClient{
+		responses: []fakeChatCompletionResult{
+			contentReply("2", 4),
+			contentReply("Handbags", 4),
+			contentReply("Option 2.", 4),
+		},
+	}}
+	model := &OpenAIModel{client: client, model: "m", maxAttempts: 1, retryBaseDelay: time.Millisecond, sleep: sleepWithContext}
+
+	result, err := model.ChooseOption(context.Background(), transportPrompt)
+	if err != nil {
+		t.Fatalf("ChooseOption returned error: %v", err)
+	}
+	if result.Choice != "aa-2" {
+		t.Fatalf("choice = %q, want aa-2", result.Choice)
+	}
+	if result.Usage.PromptTokens != 12 {
+		t.Fatalf("prompt tokens = %d, want 12 across all attempts", result.Usage.PromptTokens)
+	}
+	if got := requestTransport(client.requests[2]); got != TransportText {
+		t.Fatalf("last transport = %q, want text", got)
+	}
+}
+
+func TestChooseOptionDoesNotRememberIgnoredTransport(t *testing.T) {
+	client := &recordingChatCompletionClient{fakeChatCompletionClient: fakeChatCompletionClient{
+		responses: []fakeChatCompletionResult{
+			contentReply("2", 4),
+			contentReply(`{"selection":"2"}`, 4),
+			toolReply("1"),
+		},
+	}}
+	cache := &transportCache{}
+	model := &OpenAIModel{client: client, model: "m", endpoint: "https://gateway", transports: cache, maxAttempts: 1, retryBaseDelay: time.Millisecond, sleep: sleepWithContext}
+
+	if _, err := model.ChooseOption(context.Background(), transportPrompt); err != nil {
+		t.Fatalf("ChooseOption returned error: %v", err)
+	}
+	if got, ok := cache.get(transportKey("https://gateway", "m")); ok {
+		t.Fatalf("cached transport = %q after an ignored tool call, want none", got)
+	}
+	if _, err := model.ChooseOption(context.Background(), transportPrompt); err != nil {
+		t.Fatalf("second ChooseOption returned error: %v", err)
+	}
+	if got := requestTransport(client.requests[2]); got != TransportTool {
+		t.Fatalf("second prompt transport = %q, want tool", got)
+	}
+}
+
+func TestChooseOptionDoesNotFallBackOnMalformedToolCall(t *testing.T) {
+	malformed := toolReply("1")
+	malformed.resp.Choices[0].Message.ToolCalls[0].Function.Arguments = `{"selection":`
+	client := &fakeChatCompletionClient{responses: []fakeChatCompletionResult{malformed}}
+	cache := &transportCache{}
+	model := &OpenAIModel{client: client, model: "m", endpoint: "https://gateway", transports: cache, maxAttempts: 1, retryBaseDelay: time.Millisecond, sleep: sleepWithContext}
+
+	_, err := model.ChooseOption(context.Background(), transportPrompt)
+	if err == nil || !errors.Is(err, ErrInvalidResponse) {
+		t.Fatalf("ChooseOption error = %v, want an invalid response", err)
+	}
+	if client.calls != 1 {
+		t.Fatalf("calls = %d, want 1", client.calls)
+	}
+	if got, ok := cache.get(transportKey("https://gateway", "m")); ok {
+		t.Fatalf("cached transport = %q after a malformed reply, want none", got)
+	}
+}
+
+func TestTransportCacheIsKeyedByModel(t *testing.T) {
+	cache := &transportCache{}
+	cache.set(transportKey("https://gateway", "small"), TransportText)
+	if got := transportOrder(TransportAuto, cache, transportKey("https://gateway", "large")); len(got) != len(autoTransports) {
+		t.Fatalf("transport order for another model = %v, want %v", got, autoTransports)
+	}
+	if got := transportOrder(TransportAuto, cache, transportKey("https://gateway", "small")); len(got) != 1 || got[0] != TransportText {
+		t.Fatalf("transport order = %v, want [text]", got)
+	}
+}
+
+func TestChooseOptionPinnedTransportDoesNotFallBack(t *testing.T) {
+	client := &fakeChatCompletionClient{
+		responses: []fakeChatCompletionResult{contentReply("2", 4)},
+	}
+	model := &OpenAIModel{client: client, model: "m", transport: TransportTool, maxAttempts: 1, retryBaseDelay: time.Millisecond, sleep: sleepWithContext}
+
+	_, err := model.ChooseOption(context.Background(), transportPrompt)
+	if err == nil || err.Error() != "model did not return selection tool call" {
+		t.Fatalf("ChooseOption error = %v, want missing tool call", err)
+	}
+	if client.calls != 1 {
+		t.Fatalf("calls = %d, want 1", client.calls)
+	}
+}
+
+func TestParseTextSelection(t *testing.T) {
+	options := []Option{{Name: "Shoes", ID: "aa-1"}, {Name: "Handbags", FullName: "Apparel > Handbags", ID: "aa-2"}, {Name: "3D Printers", ID: "el-3"}, {Name: "2-in-1 Laptops", ID: "el-4"}}
+	tests := []struct {
+		reply string
+		want  string
+	}{
+		{"2", "2"},
+		{"  **2**\n", "2"},
+		{"Option 1.", "1"},
+		{"5", noneSelection},
+		{"3D Printers", "3"},
+		{"2-in-1 Laptops.", "4"},
+		{"4) 2-in-1 Laptops", "4"},
+		{"#2", "2"},
+		{"None of these", noneSelection},
+		{"none_of_these", noneSelection},
+		{`{"selection": "1"}`, "1"},
+		{"Handbags", "2"},
+		{"aa-1", "1"},
+	}
+	for _, tt := range tests {
+		got, err := parseTextSelection(tt.reply, options)
+		if err != nil {
+			t.Fatalf("parseTextSelection(%q) returned error: %v", tt.reply, err)
+		}
+		if got != tt.want {
+			t.Fatalf("parseTextSelection(%q) = %q, want %q", tt.reply, got, tt.want)
+		}
+	}
+
+	for _, reply := range []string{"", "I am not sure", "7", "Probably the 2-in-1 one", "3D"} {
+		if _, err := parseTextSelection(reply, options); err == nil {
+			t.Fatalf("parseTextSelection(%q) expected error", reply)
+		}
+	}
+}
+
+func TestParseTransport(t *testing.T) {
+	for name, want := range map[string]Transport{"": TransportAuto, "AUTO": TransportAuto, "tool": TransportTool, "json": TransportJSONSchema, "text": TransportText} {
+		got, err := ParseTransport(name)
+		if err != nil || got != want {
+			t.Fatalf("ParseTransport(%q) = %q, %v, want %q", name, got, err, want)
+		}
+	}
+	if _, err := ParseTransport("grpc"); err == nil {
+		t.Fatal("expected error for unknown transport")
+	}
+}
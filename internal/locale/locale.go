@@ -0,0 +1,143 @@
+package locale
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Default is the locale used when nothing better can be determined.
+const Default = "en"
+
+// Auto asks callers to pick the locale from the text being classified.
+const Auto = "auto"
+
+// Supported lists the locales Shopify publishes under dist/<locale>/.
+var Supported = []string{
+	"ar", "bg", "cs", "da", "de", "el", "en", "es", "fi", "fr", "hr", "hu",
+	"id", "it", "ja", "ko", "lt", "nb", "nl", "pl", "pt-BR", "pt-PT", "ro",
+	"ru", "sk", "sl", "sv", "th", "tr", "uk", "vi", "zh-CN", "zh-TW",
+}
+
+// Normalize maps user input such as "pt_br" or "FR" onto the spelling used
+// in Supported. Unknown locales are returned trimmed but otherwise unchanged.
+func Normalize(loc string) string {
+	loc = strings.TrimSpace(strings.ReplaceAll(loc, "_", "-"))
+	for _, s := range Supported {
+		if strings.EqualFold(s, loc) {
+			return s
+		}
+	}
+	switch strings.ToLower(loc) {
+	case "pt":
+		return "pt-BR"
+	case "zh", "zh-hans":
+		return "zh-CN"
+	case "zh-hant":
+		return "zh-TW"
+	case "no", "nn":
+		return "nb"
+	}
+	return loc
+}
+
+var stopwords = map[string][]string{
+	"en":    {"the", "and", "with", "for", "of", "in", "to", "this", "is", "made", "from"},
+	"de":    {"und", "der", "die", "das", "mit", "für", "aus", "ein", "eine", "ist", "den", "zum"},
+	"fr":    {"le", "la", "les", "et", "avec", "pour", "de", "des", "du", "en", "une", "un", "est"},
+	"es":    {"el", "la", "los", "las", "y", "con", "para", "de", "del", "una", "un", "es", "por"},
+	"it":    {"il", "lo", "la", "gli", "le", "e", "con", "per", "di", "della", "una", "un", "è"},
+	"pt-BR": {"o", "os", "as", "e", "com", "para", "de", "do", "da", "uma", "um", "é", "em"},
+	"nl":    {"de", "het", "een", "en", "met", "voor", "van", "is", "uit", "op"},
+	"sv":    {"och", "med", "för", "en", "ett", "av", "är", "till", "i", "på"},
+	"da":    {"og", "med", "til", "en", "et", "af", "er", "i", "på", "for"},
+	"nb":    {"og", "med", "til", "en", "et", "av", "er", "i", "på", "for"},
+	"fi":    {"ja", "on", "kanssa", "sekä", "tai", "joka", "varten"},
+	"pl":    {"i", "z", "do", "dla", "na", "w", "jest", "oraz", "ze"},
+	"cs":    {"a", "s", "se", "pro", "na", "v", "je", "z", "ze"},
+	"tr":    {"ve", "ile", "için", "bir", "bu", "de", "da"},
+	"id":    {"dan", "dengan", "untuk", "yang", "ini", "dari", "di"},
+	"ro":    {"și", "cu", "pentru", "de", "din", "o", "un", "este"},
+	"hu":    {"és", "a", "az", "egy", "hogy", "van", "vagy"},
+	"vi":    {"và", "với", "cho", "của", "một", "là", "các"},
+}
+
+// Detect guesses the Shopify locale that best matches the language of text.
+// Non-Latin scripts are recognised by their characters; Latin-script text is
+// scored against short stopword lists. Default is returned when the text
+// offers no usable signal.
+func Detect(text string) string {
+	var han, kana, hangul, cyrillic, arabic, thai, greek, ukrainian, bulgarian int
+	for _, r := range text {
+		switch {
+		case unicode.In(r, unicode.Hiragana, unicode.Katakana):
+			kana++
+		case unicode.Is(unicode.Han, r):
+			han++
+		case unicode.Is(unicode.Hangul, r):
+			hangul++
+		case unicode.Is(unicode.Cyrillic, r):
+			cyrillic++
+			switch r {
+			case 'є', 'ї', 'і', 'ґ', 'Є', 'Ї', 'І', 'Ґ':
+				ukrainian++
+			case 'ъ', 'Ъ':
+				bulgarian++
+			}
+		case unicode.Is(unicode.Arabic, r):
+			arabic++
+		case unicode.Is(unicode.Thai, r):
+			thai++
+		case unicode.Is(unicode.Greek, r):
+			greek++
+		}
+	}
+	switch {
+	case kana > 0:
+		return "ja"
+	case hangul > 0:
+		return "ko"
+	case han > 0:
+		return "zh-CN"
+	case ukrainian > 0:
+		return "uk"
+	case bulgarian > 0:
+		return "bg"
+	case cyrillic > 0:
+		return "ru"
+	case arabic > 0:
+		return "ar"
+	case thai > 0:
+		return "th"
+	case greek > 0:
+		return "el"
+	}
+
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	if len(words) == 0 {
+		return Default
+	}
+	counts := make(map[string]int, len(words))
+	for _, w := range words {
+		counts[w]++
+	}
+	locales := make([]string, 0, len(stopwords))
+	for loc := range stopwords {
+		locales = append(locales, loc)
+	}
+	sort.Strings(locales)
+
+	best, bestScore := Default, 0
+	for _, loc := range locales {
+		score := 0
+		for _, w := range stopwords[loc] {
+			score += counts[w]
+		}
+		if score > bestScore || (score == bestScore && score > 0 && loc == Default) {
+			best, bestScore = loc, score
+		}
+	}
+	return best
+}
@@ -0,0 +1,38 @@
+package locale
+
+import "testing"
+
+func TestDetect(t *testing.T) {
+	cases := map[string]string{
+		"Handmade leather tote bag with a zip for the office":           "en",
+		"Sac cabas en cuir fait main avec une fermeture pour le bureau": "fr",
+		"Handgemachte Ledertasche mit Reißverschluss für das Büro":      "de",
+		"Bolso de cuero hecho a mano con cremallera para la oficina":    "es",
+		"手作りの革のトートバッグ":                                                  "ja",
+		"手工皮革手提包":                                                       "zh-CN",
+		"가죽 토트백":                                                        "ko",
+		"Кожаная сумка ручной работы":                                   "ru",
+		"":         "en",
+		"SKU 4471": "en",
+	}
+	for text, want := range cases {
+		if got := Detect(text); got != want {
+			t.Fatalf("Detect(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	cases := map[string]string{
+		"FR":    "fr",
+		"pt_br": "pt-BR",
+		"pt":    "pt-BR",
+		"zh":    "zh-CN",
+		"xx":    "xx",
+	}
+	for in, want := range cases {
+		if got := Normalize(in); got != want {
+			t.Fatalf("Normalize(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package taxonomy
+
+import (
+	"bytes"
+	"compress/gzip"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+)
+
+// BuiltinSource selects the taxonomy snapshot embedded in the binary.
+const BuiltinSource = "builtin:"
+
+// builtinFile is the gzip-compressed Shopify taxonomy written by
+// scripts/update_builtin_taxonomy.sh. Builds without it still work; they
+// just have no snapshot to fall back to.
+const builtinFile = "builtin/taxonomy.json.gz"
+
+//go:embed builtin
+var embedded embed.FS
+
+var builtinFS fs.FS = embedded
+
+// ErrNoBuiltin is returned when BuiltinSource is requested from a binary
+// built without a taxonomy snapshot.
+var ErrNoBuiltin = errors.New("this build has no embedded taxonomy; run scripts/update_builtin_taxonomy.sh before building")
+
+func builtinData() ([]byte, error) {
+	r, err := openBuiltin()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress embedded taxonomy: %w", err)
+	}
+	return data, nil
+}
+
+func openBuiltin() (*gzip.Reader, error) {
+	compressed, err := fs.ReadFile(builtinFS, builtinFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNoBuiltin
+	}
+	if err != nil {
+		return nil, err
+	}
+	r, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress embedded taxonomy: %w", err)
+	}
+	return r, nil
+}
+
+// BuiltinVersion returns the version of the embedded taxonomy snapshot,
+// or "" when the binary was built without one.
+func BuiltinVersion() string {
+	r, err := openBuiltin()
+	if err != nil {
+		return ""
+	}
+	defer r.Close()
+	// Shopify writes the version ahead of the verticals, so only the start
+	// of the file is decompressed.
+	dec := json.NewDecoder(r)
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return ""
+	}
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return ""
+		}
+		if key == "version" {
+			var version string
+			if err := dec.Decode(&version); err != nil {
+				return ""
+			}
+			return version
+		}
+		var skip json.RawMessage
+		if err := dec.Decode(&skip); err != nil {
+			return ""
+		}
+	}
+	return ""
+}
@@ -0,0 +1,138 @@
+package taxonomy
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// useBuiltin replaces the embedded snapshot with the gzipped contents of
+// path, or with nothing when path is empty, for the rest of the test.
+func useBuiltin(t *testing.T, path string) {
+	t.Helper()
+	snapshot := fstest.MapFS{}
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", path, err)
+		}
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(data); err != nil {
+			t.Fatalf("failed to compress %s: %v", path, err)
+		}
+		if err := zw.Close(); err != nil {
+			t.Fatalf("failed to compress %s: %v", path, err)
+		}
+		snapshot[builtinFile] = &fstest.MapFile{Data: buf.Bytes()}
+	}
+	previous := builtinFS
+	builtinFS = snapshot
+	t.Cleanup(func() { builtinFS = previous })
+}
+
+// TestEmbeddedSnapshot checks the snapshot actually compiled into the
+// binaries. The packaging scripts refuse to build without it.
+func TestEmbeddedSnapshot(t *testing.T) {
+	release, err := fs.ReadFile(embedded, "builtin/RELEASE")
+	if err != nil {
+		t.Fatalf("failed to read RELEASE: %v", err)
+	}
+	ref := strings.TrimSpace(string(release))
+	if !strings.HasPrefix(ref, "refs/tags/v") {
+		t.Fatalf("RELEASE must pin a release tag, got %q", ref)
+	}
+	if _, err := fs.Stat(embedded, builtinFile); errors.Is(err, fs.ErrNotExist) {
+		t.Skipf("%s is missing; run scripts/update_builtin_taxonomy.sh", builtinFile)
+	}
+
+	want := strings.TrimPrefix(ref, "refs/tags/v")
+	if v := BuiltinVersion(); v != want {
+		t.Fatalf("embedded taxonomy version %q does not match RELEASE %q", v, ref)
+	}
+	tax, err := Fetch(context.Background(), BuiltinSource)
+	if err != nil {
+		t.Fatalf("Fetch returned error: %v", err)
+	}
+	if tax.Format != FormatShopify || tax.Version != want || len(tax.Roots) == 0 {
+		t.Fatalf("unexpected embedded taxonomy: format %q, version %q, %d roots", tax.Format, tax.Version, len(tax.Roots))
+	}
+	if tax.FindByID("gid://shopify/TaxonomyCategory/aa") == nil {
+		t.Fatal("embedded taxonomy has no Apparel & Accessories category")
+	}
+}
+
+func TestFetchBuiltin(t *testing.T) {
+	useBuiltin(t, filepath.Join("testdata", "sample.json"))
+
+	if v := BuiltinVersion(); v != "test" {
+		t.Fatalf("unexpected builtin version: %q", v)
+	}
+	tax, err := Fetch(context.Background(), BuiltinSource)
+	if err != nil {
+		t.Fatalf("Fetch returned error: %v", err)
+	}
+	if tax.Version != "test" || tax.Source != BuiltinSource || tax.Format != FormatShopify {
+		t.Fatalf("unexpected builtin taxonomy: version %q, source %q, format %q", tax.Version, tax.Source, tax.Format)
+	}
+	if tax.FindByID("grandchild") == nil {
+		t.Fatal("builtin taxonomy was not indexed")
+	}
+}
+
+func TestFetchBuiltinMissing(t *testing.T) {
+	useBuiltin(t, "")
+
+	if v := BuiltinVersion(); v != "" {
+		t.Fatalf("expected no builtin version, got %q", v)
+	}
+	if _, err := Fetch(context.Background(), BuiltinSource); !errors.Is(err, ErrNoBuiltin) {
+		t.Fatalf("expected ErrNoBuiltin, got %v", err)
+	}
+}
+
+func TestFetchFallsBackToBuiltin(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	useBuiltin(t, filepath.Join("testdata", "sample.json"))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	if _, err := Fetch(context.Background(), server.URL); err == nil {
+		t.Fatal("expected error without the builtin fallback")
+	}
+
+	var warnings []string
+	warn := WithWarnings(func(format string, args ...interface{}) {
+		warnings = append(warnings, fmt.Sprintf(format, args...))
+	})
+	tax, err := Fetch(context.Background(), server.URL, WithBuiltinFallback(), warn)
+	if err != nil {
+		t.Fatalf("Fetch returned error: %v", err)
+	}
+	if tax.Source != BuiltinSource || len(warnings) != 1 || !strings.Contains(warnings[0], "embedded taxonomy test") {
+		t.Fatalf("builtin not used with a warning: source %q, warnings %q", tax.Source, warnings)
+	}
+
+	// Local files are not replaced by the snapshot.
+	if _, err := Fetch(context.Background(), filepath.Join(t.TempDir(), "missing.json"), WithBuiltinFallback(), warn); err == nil {
+		t.Fatal("expected error for a missing file")
+	}
+
+	useBuiltin(t, "")
+	_, err = Fetch(context.Background(), server.URL, WithBuiltinFallback(), warn)
+	if err == nil || !strings.Contains(err.Error(), "503") || !strings.Contains(err.Error(), "no embedded taxonomy") {
+		t.Fatalf("expected the fetch error noting the missing snapshot, got %v", err)
+	}
+}
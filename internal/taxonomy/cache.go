@@ -0,0 +1,198 @@
+package taxonomy
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DefaultCacheMaxAge is how long downloads are used before they are
+// revalidated.
+const DefaultCacheMaxAge = 24 * time.Hour
+
+// cacheMeta is stored next to each cached download and holds the
+// validators used to revalidate it with a conditional request.
+type cacheMeta struct {
+	Source       string    `json:"source"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	FetchedAt    time.Time `json:"fetched_at"`
+}
+
+// cacheEntry is a cached download that parsed successfully.
+type cacheEntry struct {
+	data []byte
+	meta cacheMeta
+}
+
+// fetchHTTP downloads source through the cache. A cached copy younger than
+// cfg.maxAge is used as is; an older one is revalidated with If-None-Match
+// and If-Modified-Since, and served with a warning when the source cannot
+// be reached or returns an error.
+func fetchHTTP(ctx context.Context, source string, cfg fetchConfig, parse func([]byte) error) error {
+	var cached *cacheEntry
+	if !cfg.disableCache {
+		if entry, err := loadFromCache(source); err == nil && parse(entry.data) == nil {
+			if time.Since(entry.meta.FetchedAt) < cfg.maxAge {
+				return nil
+			}
+			cached = entry
+		}
+	}
+
+	err := revalidate(ctx, source, cfg, cached, parse)
+	if err == nil || cached == nil {
+		return err
+	}
+	// parse may have been handed a bad download since the cached copy
+	// was parsed, so parse it again.
+	if parseErr := parse(cached.data); parseErr != nil {
+		return err
+	}
+	cfg.warnf("could not revalidate %s (%v); using the copy cached %s", source, err, cached.meta.FetchedAt.Local().Format(time.RFC3339))
+	return nil
+}
+
+func revalidate(ctx context.Context, source string, cfg fetchConfig, cached *cacheEntry, parse func([]byte) error) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+	if err != nil {
+		return err
+	}
+	if cached != nil {
+		if cached.meta.ETag != "" {
+			req.Header.Set("If-None-Match", cached.meta.ETag)
+		}
+		if cached.meta.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.meta.LastModified)
+		}
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		meta := cached.meta
+		meta.FetchedAt = time.Now()
+		if etag := resp.Header.Get("ETag"); etag != "" {
+			meta.ETag = etag
+		}
+		saveCacheMeta(source, meta)
+		return nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch %s: %s", source, resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if err := parse(data); err != nil {
+		return err
+	}
+	if !cfg.disableCache {
+		saveToCache(source, data, cacheMeta{
+			Source:       source,
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+			FetchedAt:    time.Now(),
+		})
+	}
+	return nil
+}
+
+func cacheFilePath(source string) (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(source))
+	name := hex.EncodeToString(sum[:]) + ".json"
+	return filepath.Join(dir, "taxowalk", name), nil
+}
+
+func metaFilePath(dataPath string) string {
+	return dataPath[:len(dataPath)-len(".json")] + ".meta.json"
+}
+
+// loadFromCache returns the cached copy of source. Copies cached before
+// the metadata sidecar existed are dated by their modification time and
+// have no validators.
+func loadFromCache(source string) (*cacheEntry, error) {
+	path, err := cacheFilePath(source)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+	entry := &cacheEntry{data: data}
+	raw, err := os.ReadFile(metaFilePath(path))
+	if err != nil || json.Unmarshal(raw, &entry.meta) != nil || entry.meta.Source != source {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		entry.meta = cacheMeta{Source: source, FetchedAt: info.ModTime()}
+	}
+	return entry, nil
+}
+
+func saveToCache(source string, data []byte, meta cacheMeta) {
+	path, err := cacheFilePath(source)
+	if err != nil {
+		return
+	}
+	// Drop the old validators first so that they never describe the
+	// wrong data.
+	_ = os.Remove(metaFilePath(path))
+	if err := writeFileAtomic(path, data); err != nil {
+		return
+	}
+	saveCacheMeta(source, meta)
+}
+
+func saveCacheMeta(source string, meta cacheMeta) {
+	path, err := cacheFilePath(source)
+	if err != nil {
+		return
+	}
+	raw, err := json.Marshal(meta)
+	if err != nil {
+		return
+	}
+	_ = writeFileAtomic(metaFilePath(path), raw)
+}
+
+// writeFileAtomic replaces path so that readers never see a partial file.
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "taxonomy-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer tmp.Close()
+	if _, err := tmp.Write(data); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
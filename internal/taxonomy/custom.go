@@ -0,0 +1,270 @@
+package taxonomy
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Formats for in-house category trees.
+const (
+	// FormatCSV reads "id,parent_id,name" rows; an empty parent_id marks a
+	// top-level category and a header row is optional.
+	FormatCSV = "csv"
+	// FormatTree reads a nested YAML or JSON tree of {id, name, children}
+	// categories, either as a list or under a "categories" key next to an
+	// optional "version".
+	FormatTree = "tree"
+	// FormatPaths reads one "A > B > C" path per line, optionally preceded
+	// by an ID and a tab. Missing parents are created, and categories
+	// without an ID use their full path.
+	FormatPaths = "paths"
+)
+
+// pathSeparator joins category names into a FullName.
+const pathSeparator = " > "
+
+type rawTreeNode struct {
+	ID       string        `yaml:"id"`
+	Name     string        `yaml:"name"`
+	Children []rawTreeNode `yaml:"children"`
+}
+
+type rawTree struct {
+	Version    string        `yaml:"version"`
+	Categories []rawTreeNode `yaml:"categories"`
+}
+
+func decodeTree(data []byte) (*Taxonomy, error) {
+	var raw rawTree
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) > 0 && doc.Content[0].Kind == yaml.SequenceNode {
+		err := doc.Content[0].Decode(&raw.Categories)
+		if err != nil {
+			return nil, err
+		}
+	} else if err := doc.Decode(&raw); err != nil {
+		return nil, err
+	}
+
+	tax := &Taxonomy{Version: raw.Version}
+	var build func(raw rawTreeNode, parent string) (*Node, error)
+	build = func(raw rawTreeNode, parent string) (*Node, error) {
+		node := &Node{ID: strings.TrimSpace(raw.ID), Name: strings.TrimSpace(raw.Name), Children: []*Node{}}
+		node.FullName = joinPath(parent, node.Name)
+		if node.ID == "" {
+			return nil, fmt.Errorf("category %q has no id", node.FullName)
+		}
+		for _, rawChild := range raw.Children {
+			child, err := build(rawChild, node.FullName)
+			if err != nil {
+				return nil, err
+			}
+			node.Children = append(node.Children, child)
+		}
+		return node, nil
+	}
+	for _, rawRoot := range raw.Categories {
+		root, err := build(rawRoot, "")
+		if err != nil {
+			return nil, err
+		}
+		tax.Roots = append(tax.Roots, root)
+	}
+	if len(tax.Roots) == 0 {
+		return nil, errors.New("taxonomy has no root categories")
+	}
+	return tax, nil
+}
+
+// flatCategory is a category that names its parent by ID.
+type flatCategory struct {
+	line     int
+	id       string
+	parentID string
+	name     string
+}
+
+func decodeCSV(data []byte) (*Taxonomy, error) {
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\uFEFF"))))
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	var categories []flatCategory
+	for first := true; ; first = false {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		if first && strings.EqualFold(strings.TrimSpace(record[0]), "id") {
+			continue
+		}
+		if len(record) != 3 {
+			return nil, fmt.Errorf("line %d: want id,parent_id,name, got %d fields", line, len(record))
+		}
+		categories = append(categories, flatCategory{
+			line:     line,
+			id:       strings.TrimSpace(record[0]),
+			parentID: strings.TrimSpace(record[1]),
+			name:     strings.TrimSpace(record[2]),
+		})
+	}
+	return buildFlat(categories)
+}
+
+// buildFlat links categories to their parents in source order, rejecting
+// duplicate IDs, unknown parents and parent cycles.
+func buildFlat(categories []flatCategory) (*Taxonomy, error) {
+	nodes := make(map[string]*Node, len(categories))
+	for _, cat := range categories {
+		if cat.id == "" {
+			return nil, fmt.Errorf("line %d: category has no id", cat.line)
+		}
+		if cat.name == "" {
+			return nil, fmt.Errorf("line %d: category %s has no name", cat.line, cat.id)
+		}
+		if _, dup := nodes[cat.id]; dup {
+			return nil, fmt.Errorf("line %d: duplicate category id %s", cat.line, cat.id)
+		}
+		nodes[cat.id] = &Node{ID: cat.id, Name: cat.name, Children: []*Node{}}
+	}
+	tax := &Taxonomy{}
+	for _, cat := range categories {
+		node := nodes[cat.id]
+		if cat.parentID == "" {
+			tax.Roots = append(tax.Roots, node)
+			continue
+		}
+		parent := nodes[cat.parentID]
+		if parent == nil {
+			return nil, fmt.Errorf("line %d: parent %s of category %s does not exist", cat.line, cat.parentID, cat.id)
+		}
+		parent.Children = append(parent.Children, node)
+	}
+
+	// Categories on a parent cycle are never reached from a root.
+	reached := 0
+	var name func(node *Node, parent string)
+	name = func(node *Node, parent string) {
+		reached++
+		node.FullName = joinPath(parent, node.Name)
+		for _, child := range node.Children {
+			name(child, node.FullName)
+		}
+	}
+	for _, root := range tax.Roots {
+		name(root, "")
+	}
+	if reached != len(categories) {
+		for _, cat := range categories {
+			if nodes[cat.id].FullName == "" {
+				return nil, fmt.Errorf("line %d: category %s is part of a parent cycle", cat.line, cat.id)
+			}
+		}
+	}
+	if len(tax.Roots) == 0 {
+		return nil, errors.New("taxonomy has no root categories")
+	}
+	return tax, nil
+}
+
+func decodePaths(data []byte) (*Taxonomy, error) {
+	tax := &Taxonomy{}
+	byPath := make(map[string]*Node)
+	ids := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\uFEFF"))
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		id, path, ok := strings.Cut(line, "\t")
+		if !ok {
+			id, path = "", line
+		}
+		var parts []string
+		for _, part := range strings.Split(path, ">") {
+			if part = strings.TrimSpace(part); part == "" {
+				return nil, fmt.Errorf("line %d: empty category name in %q", lineNo, path)
+			}
+			parts = append(parts, part)
+		}
+		var parent *Node
+		for i, part := range parts {
+			full := strings.Join(parts[:i+1], pathSeparator)
+			node := byPath[full]
+			if node == nil {
+				node = &Node{ID: full, Name: part, FullName: full, Children: []*Node{}}
+				byPath[full] = node
+				if parent == nil {
+					tax.Roots = append(tax.Roots, node)
+				} else {
+					parent.Children = append(parent.Children, node)
+				}
+			}
+			parent = node
+		}
+		if id = strings.TrimSpace(id); id != "" && id != parent.ID {
+			if other, dup := ids[id]; dup && other != parent.FullName {
+				return nil, fmt.Errorf("line %d: duplicate category id %s for %s and %s", lineNo, id, other, parent.FullName)
+			}
+			ids[id] = parent.FullName
+			parent.ID = id
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(tax.Roots) == 0 {
+		return nil, errors.New("taxonomy has no root categories")
+	}
+	return tax, nil
+}
+
+func joinPath(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + pathSeparator + name
+}
+
+// firstLine returns the first line that is neither blank nor a # comment.
+func firstLine(data []byte) string {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\uFEFF"))
+		if line != "" && !strings.HasPrefix(line, "#") {
+			return line
+		}
+	}
+	return ""
+}
+
+var yamlKey = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*:(\s|$)`)
+
+func detectTree(data []byte) bool {
+	line := firstLine(data)
+	return line != "" && (line[0] == '{' || line[0] == '[' || line[0] == '-' || yamlKey.MatchString(line))
+}
+
+func detectCSV(data []byte) bool {
+	return strings.Count(firstLine(data), ",") >= 2
+}
+
+func detectPaths(data []byte) bool {
+	return firstLine(data) != ""
+}
@@ -0,0 +1,102 @@
+package taxonomy
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFetchCustomFormats(t *testing.T) {
+	cases := map[string]string{
+		"custom.csv":  FormatCSV,
+		"custom.yaml": FormatTree,
+		"custom.json": FormatTree,
+		"custom.txt":  FormatPaths,
+	}
+	for file, format := range cases {
+		tax, err := Fetch(context.Background(), filepath.Join("testdata", file))
+		if err != nil {
+			t.Fatalf("Fetch(%s) returned error: %v", file, err)
+		}
+		if tax.Format != format {
+			t.Fatalf("Fetch(%s) detected format %q, want %q", file, tax.Format, format)
+		}
+		if len(tax.Roots) != 2 || tax.Roots[0].ID != "home" || tax.Roots[1].ID != "garden" {
+			t.Fatalf("%s: unexpected roots %+v", file, tax.Roots)
+		}
+		knives := tax.FindByID("knives")
+		if knives == nil {
+			t.Fatalf("%s: FindByID(knives) returned nil", file)
+		}
+		if knives.FullName != "Home & Living > Kitchen > Knives & Blocks" || knives.Depth != 2 || !knives.Leaf || knives.Parent.ID != "kitchen" {
+			t.Fatalf("%s: unexpected node %+v", file, knives)
+		}
+	}
+}
+
+func TestFetchTreeVersion(t *testing.T) {
+	tax, err := Fetch(context.Background(), filepath.Join("testdata", "custom.yaml"))
+	if err != nil {
+		t.Fatalf("Fetch returned error: %v", err)
+	}
+	if tax.Version != "2025.1" {
+		t.Fatalf("version = %q", tax.Version)
+	}
+}
+
+func TestFetchPathsCreatesParents(t *testing.T) {
+	path := writeTaxonomy(t, "paths.txt", "Garden > Barbecues > Charcoal\nGarden > Furniture\n")
+	tax, err := Fetch(context.Background(), path)
+	if err != nil {
+		t.Fatalf("Fetch returned error: %v", err)
+	}
+	if len(tax.Roots) != 1 || len(tax.Roots[0].Children) != 2 {
+		t.Fatalf("unexpected tree: %+v", tax.Roots)
+	}
+	if node := tax.FindByID("Garden > Barbecues"); node == nil || node.Depth != 1 || node.Leaf {
+		t.Fatalf("implied parent not created: %+v", node)
+	}
+}
+
+func TestFetchCustomFormatsRejectInvalidTrees(t *testing.T) {
+	cases := []struct {
+		name, data, format, want string
+	}{
+		{"duplicate.csv", "a,,A\nb,a,B\na,,Again\n", FormatCSV, "line 3: duplicate category id a"},
+		{"orphan.csv", "a,,A\nb,z,B\n", FormatCSV, "line 2: parent z of category b does not exist"},
+		{"cycle.csv", "a,,A\nb,c,B\nc,b,C\n", FormatCSV, "line 2: category b is part of a parent cycle"},
+		{"fields.csv", "a,,A\nb,a\n", FormatCSV, "line 2: want id,parent_id,name"},
+		{"duplicate.yaml", "- id: a\n  name: A\n  children:\n    - id: a\n      name: B\n", FormatTree, "duplicate category id a"},
+		{"noid.yaml", "- name: A\n", FormatTree, `category "A" has no id`},
+		{"duplicate.txt", "x\tA\nx\tB\n", FormatPaths, "line 2: duplicate category id x"},
+		{"empty.txt", "A > > B\n", FormatPaths, "line 1: empty category name"},
+	}
+	for _, tc := range cases {
+		path := writeTaxonomy(t, tc.name, tc.data)
+		_, err := Fetch(context.Background(), path, WithFormat(tc.format))
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: expected error containing %q, got %v", tc.name, tc.want, err)
+		}
+	}
+}
+
+func TestValidateRejectsCycles(t *testing.T) {
+	a := &Node{ID: "a", Name: "A", FullName: "A"}
+	b := &Node{ID: "b", Name: "B", FullName: "A > B", Children: []*Node{a}}
+	a.Children = []*Node{b}
+	tax := &Taxonomy{Roots: []*Node{a}}
+	if err := tax.Validate(); err == nil {
+		t.Fatal("Validate accepted a cycle")
+	}
+}
+
+func writeTaxonomy(t *testing.T, name, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
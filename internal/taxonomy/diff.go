@@ -0,0 +1,125 @@
+package taxonomy
+
+import (
+	"cmp"
+	"slices"
+)
+
+// Change describes one category that differs between two taxonomies. The
+// Old fields are empty for added categories and the New fields for removed
+// ones. Parents are given by ID; top-level categories have no parent ID.
+type Change struct {
+	ID          string `json:"id"`
+	OldName     string `json:"old_name,omitempty"`
+	NewName     string `json:"new_name,omitempty"`
+	OldFullName string `json:"old_full_name,omitempty"`
+	NewFullName string `json:"new_full_name,omitempty"`
+	OldParent   string `json:"old_parent,omitempty"`
+	NewParent   string `json:"new_parent,omitempty"`
+}
+
+// Diff lists the categories added, removed, renamed and re-parented
+// between two taxonomies, each sorted by ID. A category that was both
+// renamed and moved appears in both lists.
+type Diff struct {
+	OldVersion string   `json:"old_version"`
+	NewVersion string   `json:"new_version"`
+	Added      []Change `json:"added"`
+	Removed    []Change `json:"removed"`
+	Renamed    []Change `json:"renamed"`
+	Moved      []Change `json:"moved"`
+}
+
+// Empty reports whether the taxonomies have the same categories, names
+// and parents.
+func (d *Diff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Renamed) == 0 && len(d.Moved) == 0
+}
+
+// Changed returns the change recorded for id, preferring removal over
+// rename over move, and whether there was one.
+func (d *Diff) Changed(id string) (string, Change, bool) {
+	for _, group := range []struct {
+		kind    string
+		changes []Change
+	}{{"removed", d.Removed}, {"renamed", d.Renamed}, {"moved", d.Moved}} {
+		if i, ok := slices.BinarySearchFunc(group.changes, id, func(c Change, id string) int {
+			return cmp.Compare(c.ID, id)
+		}); ok {
+			return group.kind, group.changes[i], true
+		}
+	}
+	return "", Change{}, false
+}
+
+type diffEntry struct {
+	node   *Node
+	parent string
+}
+
+// Compare reports how newer differs from older, matching categories by ID.
+func Compare(older, newer *Taxonomy) *Diff {
+	before, after := diffEntries(older), diffEntries(newer)
+	d := &Diff{Added: []Change{}, Removed: []Change{}, Renamed: []Change{}, Moved: []Change{}}
+	if older != nil {
+		d.OldVersion = older.Version
+	}
+	if newer != nil {
+		d.NewVersion = newer.Version
+	}
+	for id, old := range before {
+		cur, ok := after[id]
+		if !ok {
+			d.Removed = append(d.Removed, Change{ID: id, OldName: old.node.Name, OldFullName: old.node.FullName, OldParent: old.parent})
+			continue
+		}
+		change := Change{
+			ID:          id,
+			OldName:     old.node.Name,
+			NewName:     cur.node.Name,
+			OldFullName: old.node.FullName,
+			NewFullName: cur.node.FullName,
+			OldParent:   old.parent,
+			NewParent:   cur.parent,
+		}
+		if old.node.Name != cur.node.Name {
+			d.Renamed = append(d.Renamed, change)
+		}
+		if old.parent != cur.parent {
+			d.Moved = append(d.Moved, change)
+		}
+	}
+	for id, cur := range after {
+		if _, ok := before[id]; !ok {
+			d.Added = append(d.Added, Change{ID: id, NewName: cur.node.Name, NewFullName: cur.node.FullName, NewParent: cur.parent})
+		}
+	}
+	for _, changes := range []*[]Change{&d.Added, &d.Removed, &d.Renamed, &d.Moved} {
+		slices.SortFunc(*changes, func(a, b Change) int { return cmp.Compare(a.ID, b.ID) })
+	}
+	return d
+}
+
+// diffEntries maps the ID of every category to the node and the ID of its
+// nearest ancestor with an ID.
+func diffEntries(t *Taxonomy) map[string]diffEntry {
+	entries := make(map[string]diffEntry)
+	var visit func(node *Node, parent string)
+	visit = func(node *Node, parent string) {
+		if node.ID != "" {
+			if _, dup := entries[node.ID]; !dup {
+				entries[node.ID] = diffEntry{node: node, parent: parent}
+			}
+			parent = node.ID
+		}
+		for _, child := range node.Children {
+			visit(child, parent)
+		}
+	}
+	if t != nil {
+		for _, root := range t.Roots {
+			visit(root, "")
+		}
+	}
+	return entries
+}
@@ -0,0 +1,63 @@
+package taxonomy
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCompare(t *testing.T) {
+	older, err := decode(strings.NewReader(`{"version":"1","verticals":[{"name":"V","categories":[
+		{"id":"a","name":"Apparel","parent_id":null},
+		{"id":"a-1","name":"Clothing","parent_id":"a"},
+		{"id":"a-2","name":"Shoes","parent_id":"a"},
+		{"id":"a-1-1","name":"Tops","parent_id":"a-1"},
+		{"id":"a-1-2","name":"Capes","parent_id":"a-1"}]}]}`))
+	if err != nil {
+		t.Fatalf("decode returned error: %v", err)
+	}
+	newer, err := decode(strings.NewReader(`{"version":"2","verticals":[{"name":"V","categories":[
+		{"id":"a","name":"Apparel","parent_id":null},
+		{"id":"a-1","name":"Clothing","parent_id":"a"},
+		{"id":"a-2","name":"Footwear","parent_id":"a"},
+		{"id":"a-1-1","name":"Shirts & Tops","parent_id":"a-2"},
+		{"id":"a-1-3","name":"Dresses","parent_id":"a-1"}]}]}`))
+	if err != nil {
+		t.Fatalf("decode returned error: %v", err)
+	}
+
+	d := Compare(older, newer)
+	ids := func(changes []Change) string {
+		var out []string
+		for _, c := range changes {
+			out = append(out, c.ID)
+		}
+		return strings.Join(out, ",")
+	}
+	if d.OldVersion != "1" || d.NewVersion != "2" {
+		t.Fatalf("versions = %q, %q", d.OldVersion, d.NewVersion)
+	}
+	if got := ids(d.Added); got != "a-1-3" {
+		t.Errorf("added = %s", got)
+	}
+	if got := ids(d.Removed); got != "a-1-2" {
+		t.Errorf("removed = %s", got)
+	}
+	if got := ids(d.Renamed); got != "a-1-1,a-2" {
+		t.Errorf("renamed = %s", got)
+	}
+	if got := ids(d.Moved); got != "a-1-1" {
+		t.Errorf("moved = %s", got)
+	}
+	if m := d.Moved[0]; m.OldParent != "a-1" || m.NewParent != "a-2" {
+		t.Errorf("move parents = %s -> %s", m.OldParent, m.NewParent)
+	}
+	if kind, _, ok := d.Changed("a-1-1"); !ok || kind != "renamed" {
+		t.Errorf("Changed(a-1-1) = %s, %t", kind, ok)
+	}
+	if _, _, ok := d.Changed("a"); ok {
+		t.Error("unchanged category reported as changed")
+	}
+	if d.Empty() || !Compare(older, older).Empty() {
+		t.Error("Empty reports the wrong result")
+	}
+}
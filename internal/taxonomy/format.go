@@ -0,0 +1,126 @@
+package taxonomy
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Names of the built-in formats. FormatAuto picks the first registered
+// format whose Detect accepts the data.
+const (
+	FormatAuto    = "auto"
+	FormatShopify = "shopify"
+	FormatGoogle  = "google"
+)
+
+// Format decodes one taxonomy file format.
+type Format struct {
+	// Name selects the format with WithFormat and is recorded as
+	// Taxonomy.Format.
+	Name string
+	// Detect reports whether data looks like this format. Formats without
+	// Detect are only used when selected by name.
+	Detect func(data []byte) bool
+	// Decode builds the taxonomy tree. Nodes are validated and indexed
+	// afterwards, so decoders only need to fill in IDs, names and children.
+	Decode func(data []byte) (*Taxonomy, error)
+}
+
+var (
+	formatsMu sync.RWMutex
+	formats   []Format
+)
+
+func init() {
+	RegisterFormat(Format{Name: FormatShopify, Detect: detectShopify, Decode: func(data []byte) (*Taxonomy, error) {
+		return decode(bytes.NewReader(data))
+	}})
+	RegisterFormat(Format{Name: FormatGoogle, Detect: detectGoogle, Decode: decodeGoogle})
+	RegisterFormat(Format{Name: FormatTree, Detect: detectTree, Decode: decodeTree})
+	RegisterFormat(Format{Name: FormatCSV, Detect: detectCSV, Decode: decodeCSV})
+	// Any other text is read as a list of paths.
+	RegisterFormat(Format{Name: FormatPaths, Detect: detectPaths, Decode: decodePaths})
+}
+
+// RegisterFormat makes a format available to Fetch, replacing any format
+// with the same name. Auto-detection tries formats in registration order.
+func RegisterFormat(f Format) {
+	formatsMu.Lock()
+	defer formatsMu.Unlock()
+	for i, existing := range formats {
+		if existing.Name == f.Name {
+			formats[i] = f
+			return
+		}
+	}
+	formats = append(formats, f)
+}
+
+// Formats returns the names of the registered formats, sorted.
+func Formats() []string {
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
+	names := make([]string, len(formats))
+	for i, f := range formats {
+		names[i] = f.Name
+	}
+	sort.Strings(names)
+	return names
+}
+
+// decodeFormat decodes data with the named format, detecting it when name
+// is empty or FormatAuto, and indexes the result.
+func decodeFormat(name string, data []byte) (*Taxonomy, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	format, err := lookupFormat(name, data)
+	if err != nil {
+		return nil, err
+	}
+	tax, err := format.Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := tax.Validate(); err != nil {
+		return nil, err
+	}
+	tax.Format = format.Name
+	tax.Index()
+	return tax, nil
+}
+
+func lookupFormat(name string, data []byte) (Format, error) {
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
+	for _, f := range formats {
+		if name == "" || name == FormatAuto {
+			if f.Detect != nil && f.Detect(data) {
+				return f, nil
+			}
+		} else if f.Name == name {
+			return f, nil
+		}
+	}
+	if name == "" || name == FormatAuto {
+		return Format{}, fmt.Errorf("unrecognised taxonomy format (known formats: %s)", strings.Join(formatNames(), ", "))
+	}
+	return Format{}, fmt.Errorf("unknown taxonomy format %q (known formats: %s)", name, strings.Join(formatNames(), ", "))
+}
+
+// formatNames is Formats for callers already holding formatsMu.
+func formatNames() []string {
+	names := make([]string, len(formats))
+	for i, f := range formats {
+		names[i] = f.Name
+	}
+	sort.Strings(names)
+	return names
+}
+
+// detectShopify accepts a JSON object with a "verticals" list.
+func detectShopify(data []byte) bool {
+	trimmed := bytes.TrimSpace(bytes.TrimPrefix(data, []byte("\uFEFF")))
+	return len(trimmed) > 0 && trimmed[0] == '{' && bytes.Contains(trimmed, []byte(`"verticals"`))
+}
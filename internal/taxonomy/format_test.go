@@ -0,0 +1,103 @@
+package taxonomy
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFetchGoogleTaxonomy(t *testing.T) {
+	tax, err := Fetch(context.Background(), filepath.Join("testdata", "google.txt"))
+	if err != nil {
+		t.Fatalf("Fetch returned error: %v", err)
+	}
+	if tax.Format != FormatGoogle || tax.Version != "2021-09-21" {
+		t.Fatalf("format %q version %q", tax.Format, tax.Version)
+	}
+	if len(tax.Roots) != 2 || tax.Roots[0].ID != "1" || tax.Roots[1].ID != "166" {
+		t.Fatalf("unexpected roots: %+v", tax.Roots)
+	}
+	pets := tax.Roots[0]
+	if len(pets.Children) != 2 || pets.Children[0].ID != "3237" || pets.Children[1].ID != "2" {
+		t.Fatalf("children out of source order: %+v", pets.Children)
+	}
+
+	birds := tax.FindByID("3")
+	if birds == nil {
+		t.Fatal("FindByID(3) returned nil")
+	}
+	if birds.Name != "Bird Supplies" || birds.FullName != "Animals & Pet Supplies > Pet Supplies > Bird Supplies" {
+		t.Fatalf("unexpected node: %+v", birds)
+	}
+	if birds.Parent == nil || birds.Parent.ID != "2" || birds.Depth != 2 || !birds.Leaf {
+		t.Fatalf("node not indexed: parent %v depth %d leaf %v", birds.Parent, birds.Depth, birds.Leaf)
+	}
+}
+
+func TestFetchDetectsShopifyTaxonomy(t *testing.T) {
+	tax, err := Fetch(context.Background(), filepath.Join("testdata", "sample.json"))
+	if err != nil {
+		t.Fatalf("Fetch returned error: %v", err)
+	}
+	if tax.Format != FormatShopify {
+		t.Fatalf("format = %q", tax.Format)
+	}
+}
+
+func TestFetchWithFormat(t *testing.T) {
+	source := filepath.Join("testdata", "google.txt")
+	if _, err := Fetch(context.Background(), source, WithFormat(FormatShopify)); err == nil {
+		t.Fatal("decoding a Google taxonomy as Shopify succeeded")
+	}
+	if _, err := Fetch(context.Background(), source, WithFormat("amazon")); err == nil || !strings.Contains(err.Error(), "unknown taxonomy format") {
+		t.Fatalf("unexpected error for unknown format: %v", err)
+	}
+	if _, err := Fetch(context.Background(), source, WithFormat("Google")); err != nil {
+		t.Fatalf("Fetch with explicit format returned error: %v", err)
+	}
+}
+
+func TestDecodeGoogleRejectsOrphans(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "orphan.txt")
+	if err := os.WriteFile(path, []byte("1 - Animals\n3 - Apparel > Clothing\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := Fetch(context.Background(), path)
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("expected line 2 error, got %v", err)
+	}
+}
+
+func TestRegisterFormat(t *testing.T) {
+	RegisterFormat(Format{Name: "lines", Decode: func(data []byte) (*Taxonomy, error) {
+		tax := &Taxonomy{}
+		for _, name := range strings.Fields(string(data)) {
+			tax.Roots = append(tax.Roots, &Node{ID: name, Name: name, FullName: name})
+		}
+		return tax, nil
+	}})
+	found := false
+	for _, name := range Formats() {
+		found = found || name == "lines"
+	}
+	if !found {
+		t.Fatalf("Formats() = %v", Formats())
+	}
+
+	path := filepath.Join(t.TempDir(), "lines.txt")
+	if err := os.WriteFile(path, []byte("a b"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if tax, err := Fetch(context.Background(), path); err != nil || tax.Format == "lines" {
+		t.Fatalf("format without Detect was auto-detected: %v", err)
+	}
+	tax, err := Fetch(context.Background(), path, WithFormat("lines"))
+	if err != nil {
+		t.Fatalf("Fetch returned error: %v", err)
+	}
+	if tax.Format != "lines" || tax.FindByID("b") == nil {
+		t.Fatalf("unexpected taxonomy: %+v", tax)
+	}
+}
@@ -0,0 +1,86 @@
+package taxonomy
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// GoogleTaxonomyURL is Google's product taxonomy with IDs in US English.
+const GoogleTaxonomyURL = "https://www.google.com/basepages/producttype/taxonomy-with-ids.en-US.txt"
+
+const googleVersionPrefix = "# Google_Product_Taxonomy_Version:"
+
+// decodeGoogle parses Google's "ID - A > B > C" product taxonomy file, in
+// which every category is listed after its parent.
+func decodeGoogle(data []byte) (*Taxonomy, error) {
+	tax := &Taxonomy{}
+	byPath := make(map[string]*Node)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\uFEFF"))
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			if v, ok := strings.CutPrefix(line, googleVersionPrefix); ok {
+				tax.Version = strings.TrimSpace(v)
+			}
+			continue
+		}
+		id, path, ok := strings.Cut(line, " - ")
+		id = strings.TrimSpace(id)
+		if !ok || id == "" {
+			return nil, fmt.Errorf("line %d: want \"ID - Category > Subcategory\", got %q", lineNo, line)
+		}
+		parts := strings.Split(path, ">")
+		for i := range parts {
+			parts[i] = strings.TrimSpace(parts[i])
+		}
+		full := strings.Join(parts, " > ")
+		node := &Node{ID: id, Name: parts[len(parts)-1], FullName: full, Children: []*Node{}}
+		if len(parts) == 1 {
+			tax.Roots = append(tax.Roots, node)
+		} else {
+			parentPath := strings.Join(parts[:len(parts)-1], " > ")
+			parent := byPath[parentPath]
+			if parent == nil {
+				return nil, fmt.Errorf("line %d: parent %q of %s is not listed before it", lineNo, parentPath, id)
+			}
+			parent.Children = append(parent.Children, node)
+		}
+		byPath[full] = node
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(tax.Roots) == 0 {
+		return nil, errors.New("taxonomy has no root categories")
+	}
+	return tax, nil
+}
+
+// detectGoogle accepts data whose first category line starts with a
+// numeric ID followed by " - ".
+func detectGoogle(data []byte) bool {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\uFEFF"))
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		id, _, ok := strings.Cut(line, " - ")
+		if !ok || id == "" {
+			return false
+		}
+		for _, r := range id {
+			if r < '0' || r > '9' {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
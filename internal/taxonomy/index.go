@@ -0,0 +1,144 @@
+package taxonomy
+
+import (
+	"fmt"
+	"iter"
+)
+
+// Index links every node to its parent, records its depth, leaf flag and
+// ancestors, and rebuilds the ID index used by FindByID. Fetch indexes the
+// taxonomies it returns; call Index again after building or changing a
+// tree by hand.
+func (t *Taxonomy) Index() {
+	t.byID = make(map[string]*Node)
+	var visit func(node, parent *Node)
+	visit = func(node, parent *Node) {
+		node.Parent = parent
+		node.Depth = 0
+		node.ancestors = nil
+		if parent != nil {
+			node.Depth = parent.Depth + 1
+			// Clip so that siblings appending themselves cannot share
+			// a backing array.
+			node.ancestors = append(parent.ancestors[:len(parent.ancestors):len(parent.ancestors)], parent)
+		}
+		node.Leaf = len(node.Children) == 0
+		if node.ID != "" {
+			if _, dup := t.byID[node.ID]; !dup {
+				t.byID[node.ID] = node
+			}
+		}
+		for _, child := range node.Children {
+			visit(child, node)
+		}
+	}
+	for _, root := range t.Roots {
+		visit(root, nil)
+	}
+}
+
+// Ancestors returns the nodes above n, root first, as recorded by
+// Taxonomy.Index. The slice is shared and must not be modified.
+func (n *Node) Ancestors() []*Node {
+	return n.ancestors
+}
+
+// Parents yields n's parent, then its parent, up to the root.
+func (n *Node) Parents() iter.Seq[*Node] {
+	return func(yield func(*Node) bool) {
+		for p := n.Parent; p != nil; p = p.Parent {
+			if !yield(p) {
+				return
+			}
+		}
+	}
+}
+
+// Subtree yields n and every node below it in depth-first pre-order.
+func (n *Node) Subtree() iter.Seq[*Node] {
+	return func(yield func(*Node) bool) {
+		n.walk(yield)
+	}
+}
+
+// Leaves yields the nodes without children in n's subtree, in order.
+func (n *Node) Leaves() iter.Seq[*Node] {
+	return leaves(n.Subtree())
+}
+
+func (n *Node) walk(yield func(*Node) bool) bool {
+	if n == nil {
+		return true
+	}
+	if !yield(n) {
+		return false
+	}
+	for _, child := range n.Children {
+		if !child.walk(yield) {
+			return false
+		}
+	}
+	return true
+}
+
+// All yields every node of the taxonomy in depth-first pre-order.
+func (t *Taxonomy) All() iter.Seq[*Node] {
+	return func(yield func(*Node) bool) {
+		for _, root := range t.Roots {
+			if !root.walk(yield) {
+				return
+			}
+		}
+	}
+}
+
+// Leaves yields every node without children, in order.
+func (t *Taxonomy) Leaves() iter.Seq[*Node] {
+	return leaves(t.All())
+}
+
+func leaves(nodes iter.Seq[*Node]) iter.Seq[*Node] {
+	return func(yield func(*Node) bool) {
+		for node := range nodes {
+			if len(node.Children) == 0 && !yield(node) {
+				return
+			}
+		}
+	}
+}
+
+// Validate reports duplicate category IDs and nodes reachable along more
+// than one path, such as a child that is also its own ancestor. Index and
+// the iterators assume a valid tree.
+func (t *Taxonomy) Validate() error {
+	seen := make(map[*Node]bool)
+	ids := make(map[string]*Node)
+	var visit func(node *Node) error
+	visit = func(node *Node) error {
+		if node == nil {
+			return nil
+		}
+		if seen[node] {
+			return fmt.Errorf("category %q appears more than once in the tree", node.FullName)
+		}
+		seen[node] = true
+		if node.ID != "" {
+			if other := ids[node.ID]; other != nil {
+				return fmt.Errorf("duplicate category id %s for %q and %q", node.ID, other.FullName, node.FullName)
+			}
+			ids[node.ID] = node
+		}
+		for _, child := range node.Children {
+			if err := visit(child); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, root := range t.Roots {
+		if err := visit(root); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -2,29 +2,19 @@ package taxonomy
 
 import "strings"
 
+// FindByID returns the node with the given ID, or nil. Indexed taxonomies
+// answer from the ID index; others are searched depth-first.
 func (t *Taxonomy) FindByID(id string) *Node {
 	id = strings.TrimSpace(id)
 	if id == "" {
 		return nil
 	}
-	for _, root := range t.Roots {
-		if node := findByID(root, id); node != nil {
-			return node
-		}
+	if t.byID != nil {
+		return t.byID[id]
 	}
-	return nil
-}
-
-func findByID(node *Node, id string) *Node {
-	if node == nil {
-		return nil
-	}
-	if node.ID == id {
-		return node
-	}
-	for _, child := range node.Children {
-		if found := findByID(child, id); found != nil {
-			return found
+	for node := range t.All() {
+		if node.ID == id {
+			return node
 		}
 	}
 	return nil
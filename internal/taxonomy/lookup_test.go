@@ -1,6 +1,11 @@
 package taxonomy
 
-import "testing"
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"testing"
+)
 
 func TestFindByID(t *testing.T) {
 	tax := &Taxonomy{
@@ -38,3 +43,61 @@ func TestFindByID(t *testing.T) {
 		t.Fatal("expected nil for unknown ID")
 	}
 }
+
+func TestDecodeIndexesTaxonomy(t *testing.T) {
+	tax, err := Fetch(context.Background(), filepath.Join("testdata", "sample.json"))
+	if err != nil {
+		t.Fatalf("Fetch returned error: %v", err)
+	}
+	vertical := tax.Roots[0]
+	root := vertical.Children[0]
+	child := root.FindChildByName("Child")
+	grandchild := child.FindChildByName("Grandchild")
+
+	if got := tax.FindByID(grandchild.ID); got != grandchild {
+		t.Fatalf("FindByID(%q) = %v, want grandchild", grandchild.ID, got)
+	}
+	if grandchild.Parent != child || child.Parent != root || root.Parent != vertical || vertical.Parent != nil {
+		t.Fatal("parent links not set")
+	}
+	if vertical.Depth != 0 || grandchild.Depth != 3 {
+		t.Fatalf("depths = %d, %d; want 0, 3", vertical.Depth, grandchild.Depth)
+	}
+	if !grandchild.Leaf || child.Leaf {
+		t.Fatalf("leaf flags = %t, %t; want true, false", grandchild.Leaf, child.Leaf)
+	}
+	if got := grandchild.Ancestors(); !slices.Equal(got, []*Node{vertical, root, child}) {
+		t.Fatalf("ancestors = %v", got)
+	}
+	if got := slices.Collect(grandchild.Parents()); !slices.Equal(got, []*Node{child, root, vertical}) {
+		t.Fatalf("parents = %v", got)
+	}
+	if got := slices.Collect(root.Subtree()); !slices.Equal(got, []*Node{root, child, grandchild}) {
+		t.Fatalf("subtree = %v", got)
+	}
+	if got := slices.Collect(tax.Leaves()); !slices.Equal(got, []*Node{grandchild}) {
+		t.Fatalf("leaves = %v", got)
+	}
+	if got := len(slices.Collect(tax.All())); got != 4 {
+		t.Fatalf("All yielded %d nodes, want 4", got)
+	}
+}
+
+func TestIndexAfterEditingTree(t *testing.T) {
+	leaf := &Node{ID: "b", Name: "B"}
+	top := &Node{ID: "a", Name: "A"}
+	tax := &Taxonomy{Roots: []*Node{top}}
+	tax.Index()
+	if !top.Leaf || tax.FindByID("b") != nil {
+		t.Fatal("unexpected index before edit")
+	}
+	top.Children = []*Node{leaf}
+	tax.Index()
+	if top.Leaf || tax.FindByID("b") != leaf || leaf.Parent != top || leaf.Depth != 1 {
+		t.Fatal("index not rebuilt after edit")
+	}
+	// Stopping early must not panic.
+	for range tax.All() {
+		break
+	}
+}
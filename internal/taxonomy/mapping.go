@@ -0,0 +1,222 @@
+package taxonomy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Confidence says how directly a mapped category was derived.
+type Confidence string
+
+const (
+	// ConfidenceExact marks the single target of a rule for the category.
+	ConfidenceExact Confidence = "exact"
+	// ConfidenceAmbiguous marks one of several targets of a rule for the
+	// category; each covers part of it.
+	ConfidenceAmbiguous Confidence = "ambiguous"
+	// ConfidenceInferred marks a target taken from the rule of the
+	// nearest ancestor because the category has no rule of its own.
+	ConfidenceInferred Confidence = "inferred"
+)
+
+// MappedCategory is a category of another taxonomy, or another version of
+// this one, that a category maps to.
+type MappedCategory struct {
+	ID         string     `json:"id"`
+	FullName   string     `json:"full_name,omitempty"`
+	Confidence Confidence `json:"confidence"`
+}
+
+// Mapping translates category IDs from one taxonomy to another, for
+// example from "shopify/2025-01" to "google/2021-09-21".
+type Mapping struct {
+	Input  string
+	Output string
+	rules  map[string][]MappedCategory
+}
+
+// Mappings is the contents of a Shopify mapping file, which holds one or
+// more mappings.
+type Mappings struct {
+	Version  string
+	Mappings []*Mapping
+}
+
+type rawMappings struct {
+	Version  string       `json:"version"`
+	Mappings []rawMapping `json:"mappings"`
+}
+
+type rawMapping struct {
+	InputTaxonomy  string    `json:"input_taxonomy"`
+	OutputTaxonomy string    `json:"output_taxonomy"`
+	Rules          []rawRule `json:"rules"`
+}
+
+type rawRule struct {
+	Input struct {
+		Category rawMappedCategory `json:"category"`
+	} `json:"input"`
+	Output struct {
+		Category []rawMappedCategory `json:"category"`
+	} `json:"output"`
+}
+
+type rawMappedCategory struct {
+	ID       string `json:"id"`
+	FullName string `json:"full_name"`
+}
+
+// FetchMappings loads a Shopify mapping file, such as
+// dist/en/integrations/all_mappings.json, from a URL or file path. Remote
+// files are cached like taxonomies.
+func FetchMappings(ctx context.Context, source string, opts ...FetchOption) (*Mappings, error) {
+	var mappings *Mappings
+	err := fetch(ctx, source, opts, func(data []byte) error {
+		var err error
+		mappings, err = decodeMappings(data)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return mappings, nil
+}
+
+func decodeMappings(data []byte) (*Mappings, error) {
+	var raw rawMappings
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse mapping file: %w", err)
+	}
+	out := &Mappings{Version: raw.Version}
+	for _, rm := range raw.Mappings {
+		m := &Mapping{
+			Input:  strings.TrimSpace(rm.InputTaxonomy),
+			Output: strings.TrimSpace(rm.OutputTaxonomy),
+			rules:  make(map[string][]MappedCategory, len(rm.Rules)),
+		}
+		for _, rule := range rm.Rules {
+			key := mappingKey(rule.Input.Category.ID)
+			if key == "" {
+				continue
+			}
+			for _, target := range rule.Output.Category {
+				id := strings.TrimSpace(target.ID)
+				if id == "" {
+					continue
+				}
+				m.rules[key] = append(m.rules[key], MappedCategory{ID: id, FullName: strings.TrimSpace(target.FullName)})
+			}
+		}
+		out.Mappings = append(out.Mappings, m)
+	}
+	if len(out.Mappings) == 0 {
+		return nil, errors.New("mapping file has no mappings")
+	}
+	return out, nil
+}
+
+// Find returns the mapping from the taxonomy named from to the one named
+// to. A name without a version, such as "google", matches every version of
+// that taxonomy, and an empty from matches any input; when several mappings
+// match, the one with the latest output and input versions wins.
+func (s *Mappings) Find(from, to string) (*Mapping, error) {
+	var found []*Mapping
+	for _, m := range s.Mappings {
+		if taxonomyNameMatches(m.Input, from) && taxonomyNameMatches(m.Output, to) {
+			found = append(found, m)
+		}
+	}
+	if len(found) == 0 {
+		return nil, fmt.Errorf("no mapping from %q to %q (available: %s)", from, to, strings.Join(s.Names(), ", "))
+	}
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].Output != found[j].Output {
+			return found[i].Output > found[j].Output
+		}
+		return found[i].Input > found[j].Input
+	})
+	return found[0], nil
+}
+
+// Names lists the mappings of the file as "input -> output".
+func (s *Mappings) Names() []string {
+	names := make([]string, len(s.Mappings))
+	for i, m := range s.Mappings {
+		names[i] = m.Input + " -> " + m.Output
+	}
+	return names
+}
+
+func taxonomyNameMatches(name, want string) bool {
+	want = strings.ToLower(strings.TrimSpace(want))
+	name = strings.ToLower(name)
+	return want == "" || name == want || strings.HasPrefix(name, want+"/")
+}
+
+// Map returns the categories id maps to. In a mapping to another taxonomy,
+// categories without a rule of their own inherit the targets of their
+// nearest mapped ancestor, flagged ConfidenceInferred, and Map returns nil
+// when neither the category nor any ancestor is mapped. A mapping between
+// two versions of one taxonomy lists only the categories that changed, so
+// there a category without a rule keeps its ID.
+func (m *Mapping) Map(id string) []MappedCategory {
+	key := mappingKey(id)
+	if key == "" {
+		return nil
+	}
+	if taxonomyName(m.Input) == taxonomyName(m.Output) {
+		if targets := m.rules[key]; len(targets) > 0 {
+			return withConfidence(targets, ConfidenceExact)
+		}
+		return []MappedCategory{{ID: shopifyIDPrefix + key, Confidence: ConfidenceExact}}
+	}
+	confidence := ConfidenceExact
+	for key != "" {
+		if targets := m.rules[key]; len(targets) > 0 {
+			return withConfidence(targets, confidence)
+		}
+		// Shopify category IDs name their ancestors: aa-1-13 is the
+		// parent of aa-1-13-8.
+		i := strings.LastIndex(key, "-")
+		if i < 0 {
+			break
+		}
+		key = key[:i]
+		confidence = ConfidenceInferred
+	}
+	return nil
+}
+
+// withConfidence copies targets flagged with confidence, or
+// ConfidenceAmbiguous for an exact rule with several targets.
+func withConfidence(targets []MappedCategory, confidence Confidence) []MappedCategory {
+	if len(targets) > 1 && confidence == ConfidenceExact {
+		confidence = ConfidenceAmbiguous
+	}
+	out := make([]MappedCategory, len(targets))
+	for i, t := range targets {
+		t.Confidence = confidence
+		out[i] = t
+	}
+	return out
+}
+
+const shopifyIDPrefix = "gid://shopify/TaxonomyCategory/"
+
+// mappingKey strips the Shopify GID prefix so that full and short IDs
+// match.
+func mappingKey(id string) string {
+	return strings.TrimPrefix(strings.TrimSpace(id), shopifyIDPrefix)
+}
+
+// taxonomyName returns the name of a mapping's taxonomy without its
+// version, e.g. "shopify" for "shopify/2025-01".
+func taxonomyName(name string) string {
+	name, _, _ = strings.Cut(strings.ToLower(strings.TrimSpace(name)), "/")
+	return name
+}
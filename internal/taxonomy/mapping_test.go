@@ -0,0 +1,66 @@
+package taxonomy
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFetchMappingsAndMap(t *testing.T) {
+	mappings, err := FetchMappings(context.Background(), filepath.Join("testdata", "mappings.json"))
+	if err != nil {
+		t.Fatalf("FetchMappings returned error: %v", err)
+	}
+	google, err := mappings.Find("shopify", "google")
+	if err != nil {
+		t.Fatalf("Find returned error: %v", err)
+	}
+	if google.Output != "google/2021-09-21" {
+		t.Fatalf("mapping output = %s", google.Output)
+	}
+
+	cases := []struct {
+		id         string
+		want       []string
+		confidence Confidence
+	}{
+		{"gid://shopify/TaxonomyCategory/aa-1", []string{"1604"}, ConfidenceExact},
+		{"aa-1", []string{"1604"}, ConfidenceExact},
+		{"gid://shopify/TaxonomyCategory/aa-2", []string{"167", "5941"}, ConfidenceAmbiguous},
+		{"gid://shopify/TaxonomyCategory/aa-1-13-8", []string{"1604"}, ConfidenceInferred},
+		{"gid://shopify/TaxonomyCategory/aa-2-4", []string{"167", "5941"}, ConfidenceInferred},
+		{"gid://shopify/TaxonomyCategory/zz-1", nil, ""},
+	}
+	for _, tc := range cases {
+		got := google.Map(tc.id)
+		if len(got) != len(tc.want) {
+			t.Errorf("Map(%s) = %v, want %v", tc.id, got, tc.want)
+			continue
+		}
+		for i, target := range got {
+			if target.ID != tc.want[i] || target.Confidence != tc.confidence {
+				t.Errorf("Map(%s)[%d] = %+v, want %s (%s)", tc.id, i, target, tc.want[i], tc.confidence)
+			}
+		}
+	}
+
+	versions, err := mappings.Find("shopify/2024-07", "shopify/2025-01")
+	if err != nil {
+		t.Fatalf("Find returned error: %v", err)
+	}
+	if got := versions.Map("gid://shopify/TaxonomyCategory/aa-1-1"); len(got) != 1 || got[0].ID != "gid://shopify/TaxonomyCategory/aa-1-2" {
+		t.Fatalf("version mapping = %v", got)
+	}
+	// Version mappings list only changes: children of a changed category
+	// and unchanged categories keep their IDs instead of inheriting.
+	for _, id := range []string{"gid://shopify/TaxonomyCategory/aa-1-1-3", "aa-2"} {
+		want := "gid://shopify/TaxonomyCategory/" + strings.TrimPrefix(id, "gid://shopify/TaxonomyCategory/")
+		if got := versions.Map(id); len(got) != 1 || got[0].ID != want || got[0].Confidence != ConfidenceExact {
+			t.Fatalf("version mapping of unchanged %s = %v, want %s", id, got, want)
+		}
+	}
+	if _, err := mappings.Find("shopify", "amazon"); err == nil {
+		t.Fatal("Find succeeded for a missing mapping")
+	}
+}
@@ -0,0 +1,240 @@
+package taxonomy
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Overlay is a set of local edits applied on top of a fetched taxonomy, so
+// that categories can be hidden, renamed, described or added without
+// forking the source file. Category IDs may omit a URI prefix such as
+// gid://shopify/TaxonomyCategory/.
+type Overlay struct {
+	// Hide removes categories, and everything below them, from the walk.
+	Hide []string `yaml:"hide"`
+	// Rename maps category IDs to new names.
+	Rename map[string]string `yaml:"rename"`
+	// Annotate maps category IDs to a description shown next to the
+	// option in the prompt, e.g. to tell confusable siblings apart.
+	Annotate map[string]string `yaml:"annotate"`
+	// Alias maps category IDs to other names the category is known by.
+	Alias map[string][]string `yaml:"alias"`
+	// Add lists new categories, each below an existing or earlier added
+	// parent, or at the top level when Parent is empty.
+	Add []OverlayCategory `yaml:"add"`
+
+	hash string
+}
+
+// OverlayCategory is a category added by an overlay.
+type OverlayCategory struct {
+	ID          string   `yaml:"id"`
+	Parent      string   `yaml:"parent"`
+	Name        string   `yaml:"name"`
+	Description string   `yaml:"description"`
+	Aliases     []string `yaml:"aliases"`
+}
+
+// LoadOverlay reads a YAML or JSON overlay from a URL or file path.
+func LoadOverlay(ctx context.Context, source string, opts ...FetchOption) (*Overlay, error) {
+	var overlay *Overlay
+	err := fetch(ctx, source, opts, func(data []byte) error {
+		var err error
+		overlay, err = ParseOverlay(data)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return overlay, nil
+}
+
+// ParseOverlay decodes a YAML or JSON overlay. Unknown keys are rejected so
+// that a misspelt operation does not silently do nothing.
+func ParseOverlay(data []byte) (*Overlay, error) {
+	overlay := &Overlay{}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(overlay); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	overlay.hash = hex.EncodeToString(sum[:])[:16]
+	return overlay, nil
+}
+
+// Hash identifies the overlay's contents; it is recorded with results so
+// they can be traced back to the overlay that shaped the options.
+func (o *Overlay) Hash() string {
+	if o == nil {
+		return ""
+	}
+	return o.hash
+}
+
+// Apply edits tax in place: categories are added first, then renamed,
+// annotated and aliased, and hidden last, so hiding a category also hides
+// categories added below it. Every ID must exist. The taxonomy is
+// re-indexed and its Overlay set to the overlay's hash.
+func (o *Overlay) Apply(tax *Taxonomy) error {
+	if o == nil || tax == nil {
+		return nil
+	}
+	tax.Index()
+	for _, add := range o.Add {
+		if err := addCategory(tax, add); err != nil {
+			return fmt.Errorf("add %s: %w", add.ID, err)
+		}
+	}
+	for _, id := range sortedKeys(o.Rename) {
+		node, err := overlayNode(tax, id)
+		if err != nil {
+			return fmt.Errorf("rename: %w", err)
+		}
+		name := strings.TrimSpace(o.Rename[id])
+		if name == "" {
+			return fmt.Errorf("rename: category %s: empty name", id)
+		}
+		rename(node, name)
+	}
+	for _, id := range sortedKeys(o.Annotate) {
+		node, err := overlayNode(tax, id)
+		if err != nil {
+			return fmt.Errorf("annotate: %w", err)
+		}
+		node.Description = strings.TrimSpace(o.Annotate[id])
+	}
+	for _, id := range sortedKeys(o.Alias) {
+		node, err := overlayNode(tax, id)
+		if err != nil {
+			return fmt.Errorf("alias: %w", err)
+		}
+		node.Aliases = appendAliases(node.Aliases, o.Alias[id])
+	}
+	for _, id := range o.Hide {
+		node, err := overlayNode(tax, id)
+		if err != nil {
+			return fmt.Errorf("hide: %w", err)
+		}
+		hide(tax, node)
+	}
+	if err := tax.Validate(); err != nil {
+		return err
+	}
+	tax.Overlay = o.hash
+	tax.Index()
+	return nil
+}
+
+// overlayNode finds a category by its full ID or by the ID without its URI
+// prefix.
+func overlayNode(tax *Taxonomy, id string) (*Node, error) {
+	id = strings.TrimSpace(id)
+	if node := tax.FindByID(id); node != nil {
+		return node, nil
+	}
+	if !strings.Contains(id, "/") {
+		for node := range tax.All() {
+			if strings.HasSuffix(node.ID, "/"+id) {
+				return node, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("category %s not found", id)
+}
+
+func addCategory(tax *Taxonomy, add OverlayCategory) error {
+	id := strings.TrimSpace(add.ID)
+	name := strings.TrimSpace(add.Name)
+	if id == "" {
+		return errors.New("category has no id")
+	}
+	if name == "" {
+		return errors.New("category has no name")
+	}
+	if _, err := overlayNode(tax, id); err == nil {
+		return errors.New("category already exists")
+	}
+	node := &Node{
+		ID:          id,
+		Name:        name,
+		FullName:    name,
+		Description: strings.TrimSpace(add.Description),
+		Aliases:     appendAliases(nil, add.Aliases),
+		Children:    []*Node{},
+	}
+	if strings.TrimSpace(add.Parent) == "" {
+		tax.Roots = append(tax.Roots, node)
+	} else {
+		parent, err := overlayNode(tax, add.Parent)
+		if err != nil {
+			return fmt.Errorf("parent: %w", err)
+		}
+		node.FullName = joinPath(parent.FullName, name)
+		parent.Children = append(parent.Children, node)
+	}
+	// Later additions may use this category as their parent.
+	tax.Index()
+	return nil
+}
+
+// rename changes node's name and the FullName of node and its subtree.
+func rename(node *Node, name string) {
+	oldFull := node.FullName
+	newFull := name
+	if prefix, ok := strings.CutSuffix(oldFull, node.Name); ok {
+		newFull = prefix + name
+	}
+	node.Name = name
+	for n := range node.Subtree() {
+		if rest, ok := strings.CutPrefix(n.FullName, oldFull); ok {
+			n.FullName = newFull + rest
+		}
+	}
+}
+
+// hide detaches node from the tree, together with any ancestors without an
+// ID, such as Shopify verticals, that are left empty.
+func hide(tax *Taxonomy, node *Node) {
+	for {
+		parent := node.Parent
+		if parent == nil {
+			tax.Roots = slices.DeleteFunc(tax.Roots, func(n *Node) bool { return n == node })
+			return
+		}
+		parent.Children = slices.DeleteFunc(parent.Children, func(n *Node) bool { return n == node })
+		if parent.ID != "" || len(parent.Children) > 0 {
+			return
+		}
+		node = parent
+	}
+}
+
+func appendAliases(aliases, more []string) []string {
+	for _, alias := range more {
+		alias = strings.TrimSpace(alias)
+		if alias != "" && !slices.Contains(aliases, alias) {
+			aliases = append(aliases, alias)
+		}
+	}
+	return aliases
+}
+
+// sortedKeys applies map operations in a stable order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
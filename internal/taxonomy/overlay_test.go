@@ -0,0 +1,119 @@
+package taxonomy
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const overlayTaxonomy = `{"version":"1","verticals":[
+	{"name":"Luggage & Bags","categories":[
+		{"id":"gid://shopify/TaxonomyCategory/lb","name":"Luggage & Bags","full_name":"Luggage & Bags"},
+		{"id":"gid://shopify/TaxonomyCategory/lb-1","name":"Tote Bags","full_name":"Luggage & Bags > Tote Bags","parent_id":"gid://shopify/TaxonomyCategory/lb"},
+		{"id":"gid://shopify/TaxonomyCategory/lb-1-1","name":"Canvas","full_name":"Luggage & Bags > Tote Bags > Canvas","parent_id":"gid://shopify/TaxonomyCategory/lb-1"},
+		{"id":"gid://shopify/TaxonomyCategory/lb-2","name":"Shoulder Bags","full_name":"Luggage & Bags > Shoulder Bags","parent_id":"gid://shopify/TaxonomyCategory/lb"}
+	]},
+	{"name":"Mature","categories":[
+		{"id":"gid://shopify/TaxonomyCategory/ma","name":"Mature","full_name":"Mature"}
+	]}
+]}`
+
+func loadOverlayTaxonomy(t *testing.T) *Taxonomy {
+	t.Helper()
+	tax, err := Fetch(context.Background(), writeTaxonomy(t, "taxonomy.json", overlayTaxonomy))
+	if err != nil {
+		t.Fatalf("Fetch returned error: %v", err)
+	}
+	return tax
+}
+
+func TestOverlayApply(t *testing.T) {
+	tax := loadOverlayTaxonomy(t)
+	overlay, err := LoadOverlay(context.Background(), filepath.Join("testdata", "overlay.yaml"))
+	if err != nil {
+		t.Fatalf("LoadOverlay returned error: %v", err)
+	}
+	if err := overlay.Apply(tax); err != nil {
+		t.Fatalf("Apply returned error: %v", err)
+	}
+	if tax.Overlay == "" || tax.Overlay != overlay.Hash() || len(tax.Overlay) != 16 {
+		t.Fatalf("overlay hash = %q, want %q", tax.Overlay, overlay.Hash())
+	}
+
+	if tax.FindByID("gid://shopify/TaxonomyCategory/ma") != nil {
+		t.Fatal("hidden category is still indexed")
+	}
+	if len(tax.Roots) != 1 {
+		t.Fatalf("empty vertical was not removed: %d roots", len(tax.Roots))
+	}
+
+	totes := tax.FindByID("gid://shopify/TaxonomyCategory/lb-1")
+	if totes.Name != "Tote & Shopper Bags" || totes.FullName != "Luggage & Bags > Tote & Shopper Bags" {
+		t.Fatalf("rename not applied: %q / %q", totes.Name, totes.FullName)
+	}
+	if totes.Description != "Open-top bags with two parallel handles" {
+		t.Fatalf("description = %q", totes.Description)
+	}
+	if canvas := tax.FindByID("gid://shopify/TaxonomyCategory/lb-1-1"); canvas.FullName != "Luggage & Bags > Tote & Shopper Bags > Canvas" {
+		t.Fatalf("descendant full name = %q", canvas.FullName)
+	}
+
+	shoulder := tax.FindByID("gid://shopify/TaxonomyCategory/lb-2")
+	if strings.Join(shoulder.Aliases, ",") != "Crossbody Bags,Messenger Bags" {
+		t.Fatalf("aliases = %v", shoulder.Aliases)
+	}
+
+	market := tax.FindByID("acme-1")
+	if market == nil || market.Parent != totes || market.FullName != "Luggage & Bags > Tote & Shopper Bags > Market Totes" || !market.Leaf {
+		t.Fatalf("added category = %+v", market)
+	}
+	if totes.Leaf {
+		t.Fatal("parent of added category is still a leaf")
+	}
+
+	if matches := tax.Search("crossbody", WithLimit(1)); len(matches) != 1 || matches[0].Node != shoulder {
+		t.Fatalf("search does not match aliases: %+v", matches)
+	}
+}
+
+func TestOverlayRejectsUnknownCategories(t *testing.T) {
+	cases := map[string]string{
+		"hide: [zz]\n":                            "hide: category zz not found",
+		"rename: {zz: Other}\n":                   "rename: category zz not found",
+		"add: [{id: lb-1, name: Again}]\n":        "add lb-1: category already exists",
+		"add: [{id: x, parent: zz, name: X}]\n":   "add x: parent: category zz not found",
+		"add: [{id: x, parent: lb}]\n":            "add x: category has no name",
+		"annotate: {zz: Something}\n":             "annotate: category zz not found",
+		"alias: {lb-2: [Satchels]}\nhide: [lb]\n": "",
+	}
+	for data, want := range cases {
+		overlay, err := ParseOverlay([]byte(data))
+		if err != nil {
+			t.Fatalf("ParseOverlay(%q) returned error: %v", data, err)
+		}
+		err = overlay.Apply(loadOverlayTaxonomy(t))
+		if want == "" {
+			if err != nil {
+				t.Errorf("Apply(%q) returned error: %v", data, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Apply(%q) error = %v, want %q", data, err, want)
+		}
+	}
+}
+
+func TestParseOverlayRejectsUnknownOperations(t *testing.T) {
+	if _, err := ParseOverlay([]byte("hidden: [ma]\n")); err == nil {
+		t.Fatal("ParseOverlay accepted an unknown key")
+	}
+	overlay, err := ParseOverlay([]byte(`{"hide": ["ma"]}`))
+	if err != nil {
+		t.Fatalf("ParseOverlay rejected JSON: %v", err)
+	}
+	if len(overlay.Hide) != 1 {
+		t.Fatalf("hide = %v", overlay.Hide)
+	}
+}
@@ -0,0 +1,188 @@
+package taxonomy
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Match is a node found by Search with its similarity to the query, from
+// 0 (unrelated) to 1 (every query word matches the name exactly).
+type Match struct {
+	Node  *Node
+	Score float64
+}
+
+type searchConfig struct {
+	limit      int
+	leavesOnly bool
+	root       *Node
+}
+
+// SearchOption configures Search behaviour.
+type SearchOption func(*searchConfig)
+
+// WithLimit returns at most n matches. Zero or less returns every match.
+func WithLimit(n int) SearchOption {
+	return func(cfg *searchConfig) {
+		cfg.limit = n
+	}
+}
+
+// WithLeavesOnly only returns nodes without children.
+func WithLeavesOnly() SearchOption {
+	return func(cfg *searchConfig) {
+		cfg.leavesOnly = true
+	}
+}
+
+// WithSubtree only returns root and the nodes below it.
+func WithSubtree(root *Node) SearchOption {
+	return func(cfg *searchConfig) {
+		cfg.root = root
+	}
+}
+
+// Minimum similarity for a query word to count as matching a name word;
+// below it a word contributes nothing.
+const minWordSimilarity = 0.6
+
+// Search ranks the categories of the taxonomy by lexical similarity to
+// query. Each query word is compared with the words of a node's Name and
+// FullName, allowing prefixes, plurals and small typos; the Name counts
+// more than the rest of the path. Matches are ordered best first, with
+// ties broken by shorter and then alphabetical FullName.
+func (t *Taxonomy) Search(query string, opts ...SearchOption) []Match {
+	cfg := searchConfig{}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&cfg)
+		}
+	}
+	words := searchWords(query)
+	if len(words) == 0 {
+		return nil
+	}
+	nodes := t.All()
+	if cfg.root != nil {
+		nodes = cfg.root.Subtree()
+	}
+
+	var matches []Match
+	for node := range nodes {
+		if node.ID == "" || (cfg.leavesOnly && len(node.Children) > 0) {
+			continue
+		}
+		if score := matchScore(words, node); score > 0 {
+			matches = append(matches, Match{Node: node, Score: score})
+		}
+	}
+	slices.SortFunc(matches, func(a, b Match) int {
+		if c := cmp.Compare(b.Score, a.Score); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(len(a.Node.FullName), len(b.Node.FullName)); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Node.FullName, b.Node.FullName)
+	})
+	if cfg.limit > 0 && len(matches) > cfg.limit {
+		matches = matches[:cfg.limit]
+	}
+	return matches
+}
+
+// matchScore scores node by its name or, if better, one of its aliases.
+func matchScore(query []string, node *Node) float64 {
+	full := searchWords(node.FullName)
+	score := nameScore(query, node.Name, full)
+	for _, alias := range node.Aliases {
+		score = max(score, nameScore(query, alias, full))
+	}
+	return score
+}
+
+func nameScore(query []string, nodeName string, full []string) float64 {
+	name := searchWords(nodeName)
+	var score float64
+	for _, q := range query {
+		score += max(bestSimilarity(q, name), 0.6*bestSimilarity(q, full))
+	}
+	score /= float64(len(query))
+	if score > 0 && slices.Equal(query, name) {
+		// An exact name beats a longer name containing the same words.
+		score = 1
+	} else {
+		score *= 0.95
+	}
+	return score
+}
+
+func bestSimilarity(word string, candidates []string) float64 {
+	best := 0.0
+	for _, c := range candidates {
+		if s := wordSimilarity(word, c); s > best {
+			best = s
+		}
+	}
+	return best
+}
+
+// wordSimilarity compares two normalised words: 1 for equal words, a
+// length-weighted score for prefixes and one minus the relative edit
+// distance otherwise.
+func wordSimilarity(a, b string) float64 {
+	if a == b {
+		return 1
+	}
+	ra, rb := []rune(a), []rune(b)
+	short, long := len(ra), len(rb)
+	if short > long {
+		short, long = long, short
+	}
+	var s float64
+	if short >= 3 && (strings.HasPrefix(a, b) || strings.HasPrefix(b, a)) {
+		s = 0.5 + 0.4*float64(short)/float64(long)
+	} else {
+		s = 1 - float64(editDistance(ra, rb))/float64(long)
+	}
+	if s < minWordSimilarity {
+		return 0
+	}
+	return s
+}
+
+func editDistance(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// searchWords splits s into lower-case words and drops a plural "s", so
+// that "Tote Bags" and "tote bag" compare equal.
+func searchWords(s string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	for i, f := range fields {
+		if len(f) > 3 && strings.HasSuffix(f, "s") && !strings.HasSuffix(f, "ss") {
+			fields[i] = strings.TrimSuffix(f, "s")
+		}
+	}
+	return fields
+}
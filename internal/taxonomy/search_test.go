@@ -0,0 +1,66 @@
+package taxonomy
+
+import "testing"
+
+func searchTaxonomy() *Taxonomy {
+	tote := &Node{ID: "lb-1-1", Name: "Tote Bags", FullName: "Luggage & Bags > Handbags > Tote Bags"}
+	clutch := &Node{ID: "lb-1-2", Name: "Clutches", FullName: "Luggage & Bags > Handbags > Clutches"}
+	handbags := &Node{ID: "lb-1", Name: "Handbags", FullName: "Luggage & Bags > Handbags", Children: []*Node{tote, clutch}}
+	luggage := &Node{ID: "lb", Name: "Luggage & Bags", FullName: "Luggage & Bags", Children: []*Node{handbags}}
+	jacket := &Node{ID: "aa-1-1", Name: "Leather Jackets", FullName: "Apparel & Accessories > Clothing > Leather Jackets"}
+	clothing := &Node{ID: "aa-1", Name: "Clothing", FullName: "Apparel & Accessories > Clothing", Children: []*Node{jacket}}
+	apparel := &Node{ID: "aa", Name: "Apparel & Accessories", FullName: "Apparel & Accessories", Children: []*Node{clothing}}
+	tax := &Taxonomy{Roots: []*Node{
+		{Name: "Apparel & Accessories", FullName: "Apparel & Accessories", Children: []*Node{apparel}},
+		{Name: "Luggage & Bags", FullName: "Luggage & Bags", Children: []*Node{luggage}},
+	}}
+	tax.Index()
+	return tax
+}
+
+func TestSearchRanksExactNameFirst(t *testing.T) {
+	matches := searchTaxonomy().Search("tote bag")
+	if len(matches) == 0 || matches[0].Node.ID != "lb-1-1" {
+		t.Fatalf("first match = %v, want Tote Bags", matches)
+	}
+	if matches[0].Score != 1 {
+		t.Fatalf("exact name score = %v, want 1", matches[0].Score)
+	}
+	for _, m := range matches[1:] {
+		if m.Score >= matches[0].Score {
+			t.Fatalf("matches not ordered by score: %v", matches)
+		}
+	}
+}
+
+func TestSearchToleratesTyposAndMatchesFullName(t *testing.T) {
+	tax := searchTaxonomy()
+	if matches := tax.Search("handbgs"); len(matches) == 0 || matches[0].Node.ID != "lb-1" {
+		t.Fatalf("typo search = %v, want Handbags first", matches)
+	}
+	// "luggage" only appears in the path of the clutches.
+	if matches := tax.Search("luggage clutch"); len(matches) == 0 || matches[0].Node.ID != "lb-1-2" {
+		t.Fatalf("path search = %v, want Clutches first", matches)
+	}
+	if matches := tax.Search("xylophone"); len(matches) != 0 {
+		t.Fatalf("unrelated query matched %v", matches)
+	}
+}
+
+func TestSearchFilters(t *testing.T) {
+	tax := searchTaxonomy()
+	for _, m := range tax.Search("bags", WithLeavesOnly()) {
+		if len(m.Node.Children) > 0 {
+			t.Fatalf("leaves-only search returned %s", m.Node.FullName)
+		}
+	}
+	matches := tax.Search("leather", WithSubtree(tax.FindByID("lb")))
+	for _, m := range matches {
+		if m.Node.ID == "aa-1-1" {
+			t.Fatal("subtree search returned a node outside the subtree")
+		}
+	}
+	if got := tax.Search("bags", WithLimit(1)); len(got) != 1 {
+		t.Fatalf("limited search returned %d matches", len(got))
+	}
+}
@@ -1,15 +1,11 @@
 package taxonomy
 
 import (
-	"bytes"
 	"context"
-	"crypto/sha256"
-	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
-	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
@@ -19,7 +15,17 @@ import (
 
 type Taxonomy struct {
 	Version string
-	Roots   []*Node
+	Locale  string
+	// Format names the file format the taxonomy was decoded from.
+	Format string
+	// Overlay is the hash of the overlay applied to the taxonomy, if any.
+	Overlay string
+	// Source is where the taxonomy was loaded from, BuiltinSource when it
+	// is the snapshot embedded in the binary.
+	Source string
+	Roots  []*Node
+
+	byID map[string]*Node
 }
 
 type Node struct {
@@ -27,6 +33,19 @@ type Node struct {
 	Name     string
 	FullName string
 	Children []*Node
+
+	// Description and Aliases come from an overlay and are shown to the
+	// model alongside the category name.
+	Description string
+	Aliases     []string
+
+	// Parent, Depth and Leaf are set by Taxonomy.Index. Roots have no
+	// parent and depth 0.
+	Parent *Node
+	Depth  int
+	Leaf   bool
+
+	ancestors []*Node
 }
 
 func (n *Node) FindChildByName(name string) *Node {
@@ -78,12 +97,27 @@ type rawAncestor struct {
 	Name string `json:"name"`
 }
 
-const cacheMaxAge = 24 * time.Hour
-
-var errCacheMiss = errors.New("cache miss")
-
 type fetchConfig struct {
 	disableCache bool
+	maxAge       time.Duration
+	warnf        func(format string, args ...interface{})
+	format       string
+	fallback     bool
+}
+
+func newFetchConfig(opts []FetchOption) fetchConfig {
+	cfg := fetchConfig{maxAge: DefaultCacheMaxAge}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&cfg)
+		}
+	}
+	if cfg.warnf == nil {
+		cfg.warnf = func(format string, args ...interface{}) {
+			fmt.Fprintf(os.Stderr, "Warning: "+format+"\n", args...)
+		}
+	}
+	return cfg
 }
 
 // FetchOption configures Fetch behaviour.
@@ -96,60 +130,105 @@ func WithCacheDisabled() FetchOption {
 	}
 }
 
-func Fetch(ctx context.Context, source string, opts ...FetchOption) (*Taxonomy, error) {
-	if source == "" {
-		return nil, errors.New("taxonomy source is empty")
+// WithCacheMaxAge sets how long a cached download is used without asking
+// the server whether it changed. Zero revalidates on every fetch.
+func WithCacheMaxAge(d time.Duration) FetchOption {
+	return func(cfg *fetchConfig) {
+		cfg.maxAge = max(d, 0)
 	}
-	cfg := fetchConfig{}
-	for _, opt := range opts {
-		if opt != nil {
-			opt(&cfg)
-		}
+}
+
+// WithWarnings receives warnings such as a stale cached copy being used
+// because the source could not be reached. By default they are printed to
+// standard error.
+func WithWarnings(fn func(format string, args ...interface{})) FetchOption {
+	return func(cfg *fetchConfig) {
+		cfg.warnf = fn
 	}
-	u, err := url.Parse(source)
-	if err == nil && u.Scheme != "" && u.Scheme != "file" {
-		if !cfg.disableCache {
-			if tax, err := loadFromCache(source); err == nil {
-				return tax, nil
-			}
-		}
-		req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
-		if err != nil {
-			return nil, err
-		}
-		resp, err := http.DefaultClient.Do(req)
-		if err != nil {
-			return nil, err
-		}
-		defer resp.Body.Close()
-		if resp.StatusCode != http.StatusOK {
-			return nil, fmt.Errorf("failed to fetch taxonomy: %s", resp.Status)
+}
+
+// WithFormat decodes the taxonomy with the named format instead of
+// detecting it; see RegisterFormat.
+func WithFormat(name string) FetchOption {
+	return func(cfg *fetchConfig) {
+		cfg.format = name
+	}
+}
+
+// WithBuiltinFallback loads the embedded snapshot, with a warning, when a
+// remote source can be neither downloaded nor served from the cache.
+func WithBuiltinFallback() FetchOption {
+	return func(cfg *fetchConfig) {
+		cfg.fallback = true
+	}
+}
+
+// Fetch loads a taxonomy from a URL or file path in any registered format,
+// or the embedded snapshot when source is BuiltinSource.
+func Fetch(ctx context.Context, source string, opts ...FetchOption) (*Taxonomy, error) {
+	cfg := newFetchConfig(opts)
+	var tax *Taxonomy
+	parse := func(data []byte) error {
+		var err error
+		tax, err = decodeFormat(cfg.format, data)
+		return err
+	}
+	err := fetch(ctx, source, opts, parse)
+	if err != nil && cfg.fallback && isRemote(source) {
+		data, builtinErr := builtinData()
+		if errors.Is(builtinErr, ErrNoBuiltin) {
+			return nil, fmt.Errorf("%w (this build has no embedded taxonomy to fall back to)", err)
 		}
-		data, err := io.ReadAll(resp.Body)
-		if err != nil {
+		if builtinErr != nil || parse(data) != nil {
 			return nil, err
 		}
-		tax, err := decode(bytes.NewReader(data))
+		cfg.warnf("could not load %s (%v); using the embedded taxonomy %s", source, err, tax.Version)
+		source = BuiltinSource
+		err = nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	tax.Source = source
+	return tax, nil
+}
+
+// fetch reads source, an HTTP(S) URL, a file path or BuiltinSource, and
+// passes its contents to parse. Remote sources are served from the cache
+// while it is fresh and parses, and cached only after parse accepts them.
+func fetch(ctx context.Context, source string, opts []FetchOption, parse func([]byte) error) error {
+	if source == "" {
+		return errors.New("taxonomy source is empty")
+	}
+	if source == BuiltinSource {
+		data, err := builtinData()
 		if err != nil {
-			return nil, err
-		}
-		if !cfg.disableCache {
-			saveToCache(source, data)
+			return err
 		}
-		return tax, nil
+		return parse(data)
+	}
+	cfg := newFetchConfig(opts)
+	if isRemote(source) {
+		return fetchHTTP(ctx, source, cfg, parse)
 	}
 
 	// Treat as file path.
 	path := source
-	if u != nil && u.Scheme == "file" {
+	if u, err := url.Parse(source); err == nil && u.Scheme == "file" {
 		path = u.Path
 	}
-	f, err := os.Open(filepath.Clean(path))
+	data, err := os.ReadFile(filepath.Clean(path))
 	if err != nil {
-		return nil, err
+		return err
 	}
-	defer f.Close()
-	return decode(f)
+	return parse(data)
+}
+
+// isRemote reports whether source is fetched over the network rather
+// than read from a file.
+func isRemote(source string) bool {
+	u, err := url.Parse(source)
+	return err == nil && u.Scheme != "" && u.Scheme != "file"
 }
 
 func decode(r io.Reader) (*Taxonomy, error) {
@@ -162,7 +241,13 @@ func decode(r io.Reader) (*Taxonomy, error) {
 		vertNode := &Node{Name: vertical.Name, FullName: vertical.Name, Children: []*Node{}}
 		nodes := make(map[string]*Node)
 		parents := make(map[string]string)
+		// Children keep the order of the source file so that the options,
+		// and therefore the prompts, are the same on every run.
+		var order []string
 		for _, cat := range vertical.Categories {
+			if _, seen := nodes[cat.ID]; !seen {
+				order = append(order, cat.ID)
+			}
 			node := ensureNode(nodes, cat.ID)
 			node.ID = cat.ID
 			node.Name = strings.TrimSpace(cat.Name)
@@ -172,7 +257,8 @@ func decode(r io.Reader) (*Taxonomy, error) {
 				parents[cat.ID] = parentID
 			}
 		}
-		for id, node := range nodes {
+		for _, id := range order {
+			node := nodes[id]
 			parentID, ok := parents[id]
 			if !ok {
 				vertNode.Children = append(vertNode.Children, node)
@@ -240,61 +326,3 @@ func buildFullName(cat rawCategory) string {
 	}
 	return strings.Join(names, " > ")
 }
-
-func cacheFilePath(source string) (string, error) {
-	dir, err := os.UserCacheDir()
-	if err != nil {
-		return "", err
-	}
-	sum := sha256.Sum256([]byte(source))
-	name := hex.EncodeToString(sum[:]) + ".json"
-	return filepath.Join(dir, "taxowalk", name), nil
-}
-
-func loadFromCache(source string) (*Taxonomy, error) {
-	path, err := cacheFilePath(source)
-	if err != nil {
-		return nil, errCacheMiss
-	}
-	info, err := os.Stat(path)
-	if err != nil {
-		return nil, errCacheMiss
-	}
-	if time.Since(info.ModTime()) > cacheMaxAge {
-		return nil, errCacheMiss
-	}
-	f, err := os.Open(filepath.Clean(path))
-	if err != nil {
-		return nil, errCacheMiss
-	}
-	defer f.Close()
-	tax, err := decode(f)
-	if err != nil {
-		return nil, errCacheMiss
-	}
-	return tax, nil
-}
-
-func saveToCache(source string, data []byte) {
-	path, err := cacheFilePath(source)
-	if err != nil {
-		return
-	}
-	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
-		return
-	}
-	tmp, err := os.CreateTemp(filepath.Dir(path), "taxonomy-*.json")
-	if err != nil {
-		return
-	}
-	defer tmp.Close()
-	if _, err := tmp.Write(data); err != nil {
-		os.Remove(tmp.Name())
-		return
-	}
-	if err := tmp.Close(); err != nil {
-		os.Remove(tmp.Name())
-		return
-	}
-	_ = os.Rename(tmp.Name(), path)
-}
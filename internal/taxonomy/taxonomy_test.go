@@ -2,6 +2,7 @@ package taxonomy
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -100,21 +101,112 @@ func TestFetchCacheExpires(t *testing.T) {
 		t.Fatalf("expected 1 HTTP hit, got %d", hit)
 	}
 
+	if _, err := Fetch(context.Background(), server.URL, WithCacheMaxAge(time.Hour)); err != nil {
+		t.Fatalf("Fetch returned error: %v", err)
+	}
+	if hit != 1 {
+		t.Fatalf("fresh cache was not used: %d HTTP hits", hit)
+	}
+	if _, err := Fetch(context.Background(), server.URL, WithCacheMaxAge(0)); err != nil {
+		t.Fatalf("Fetch returned error: %v", err)
+	}
+	if hit != 2 {
+		t.Fatalf("expired cache was not refreshed: %d HTTP hits", hit)
+	}
+}
+
+func TestFetchServesStaleCacheWhenOffline(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	failing := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(`{"version":"test","verticals":[{"name":"Root","categories":[{"id":"1","level":1,"name":"Root","full_name":"Root","children":[]}]}]}`))
+	}))
+	defer server.Close()
+	source := server.URL
+
+	if _, err := Fetch(context.Background(), source); err != nil {
+		t.Fatalf("Fetch returned error: %v", err)
+	}
+
+	var warnings []string
+	warn := WithWarnings(func(format string, args ...interface{}) {
+		warnings = append(warnings, fmt.Sprintf(format, args...))
+	})
+	failing = true
+	tax, err := Fetch(context.Background(), source, WithCacheMaxAge(0), warn)
+	if err != nil {
+		t.Fatalf("Fetch returned error on server failure: %v", err)
+	}
+	if tax.Version != "test" || len(warnings) != 1 || !strings.Contains(warnings[0], "503") {
+		t.Fatalf("stale copy not served with a warning: version %q, warnings %q", tax.Version, warnings)
+	}
+
+	server.Close()
+	warnings = nil
+	if _, err := Fetch(context.Background(), source, WithCacheMaxAge(0), warn); err != nil {
+		t.Fatalf("Fetch returned error while offline: %v", err)
+	}
+	if len(warnings) != 1 {
+		t.Fatalf("expected one warning while offline, got %q", warnings)
+	}
+
+	if _, err := Fetch(context.Background(), source, WithCacheDisabled(), warn); err == nil {
+		t.Fatal("expected error while offline with the cache disabled")
+	}
+}
+
+func TestFetchRevalidatesWithValidators(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	const lastModified = "Wed, 01 Jan 2025 00:00:00 GMT"
+	var full, notModified int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` && r.Header.Get("If-Modified-Since") == lastModified {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		full++
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Last-Modified", lastModified)
+		_, _ = w.Write([]byte(`{"version":"test","verticals":[{"name":"Root","categories":[{"id":"1","level":1,"name":"Root","full_name":"Root","children":[]}]}]}`))
+	}))
+	defer server.Close()
+
+	for i := 0; i < 3; i++ {
+		tax, err := Fetch(context.Background(), server.URL, WithCacheMaxAge(0))
+		if err != nil {
+			t.Fatalf("Fetch %d returned error: %v", i, err)
+		}
+		if tax.Version != "test" {
+			t.Fatalf("Fetch %d returned version %q", i, tax.Version)
+		}
+	}
+	if full != 1 || notModified != 2 {
+		t.Fatalf("expected 1 download and 2 revalidations, got %d and %d", full, notModified)
+	}
+
+	// A copy cached before validators were recorded is dated by its
+	// modification time and downloaded again once it is too old.
 	path, err := cacheFilePath(server.URL)
 	if err != nil {
 		t.Fatalf("cacheFilePath returned error: %v", err)
 	}
-	expired := time.Now().Add(-2 * cacheMaxAge)
+	if err := os.Remove(metaFilePath(path)); err != nil {
+		t.Fatalf("failed to remove metadata: %v", err)
+	}
+	expired := time.Now().Add(-2 * DefaultCacheMaxAge)
 	if err := os.Chtimes(path, expired, expired); err != nil {
 		t.Fatalf("failed to update cache timestamp: %v", err)
 	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
-	defer cancel()
-	server.Close()
-
-	if _, err := Fetch(ctx, server.URL); err == nil {
-		t.Fatalf("expected error when cache expired and server unavailable")
+	if _, err := Fetch(context.Background(), server.URL); err != nil {
+		t.Fatalf("Fetch returned error: %v", err)
+	}
+	if full != 2 {
+		t.Fatalf("expired legacy cache was not downloaded again: %d downloads", full)
 	}
 }
 
@@ -173,3 +265,24 @@ func TestDecodeBuildsHierarchyFromParentIDs(t *testing.T) {
 		t.Fatalf("expected full name to end with T-Shirts, got %q", tshirts.FullName)
 	}
 }
+
+func TestDecodeKeepsSourceOrder(t *testing.T) {
+	data := `{"version":"test","verticals":[{"name":"V","categories":[{"id":"p","name":"Parent","parent_id":null},{"id":"p-3","name":"Zebra","parent_id":"p"},{"id":"p-1","name":"Alpha","parent_id":"p"},{"id":"p-2","name":"Middle","parent_id":"p"},{"id":"q","name":"Other","parent_id":null}]}]}`
+	for i := 0; i < 20; i++ {
+		tax, err := decode(strings.NewReader(data))
+		if err != nil {
+			t.Fatalf("decode returned error: %v", err)
+		}
+		vertical := tax.Roots[0]
+		if len(vertical.Children) != 2 || vertical.Children[0].ID != "p" || vertical.Children[1].ID != "q" {
+			t.Fatalf("top-level order = %v", vertical.Children)
+		}
+		var names []string
+		for _, child := range vertical.Children[0].Children {
+			names = append(names, child.Name)
+		}
+		if got := strings.Join(names, ","); got != "Zebra,Alpha,Middle" {
+			t.Fatalf("child order = %s, want source order", got)
+		}
+	}
+}
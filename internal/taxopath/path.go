@@ -1,6 +1,7 @@
 package taxopath
 
 import (
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
@@ -10,6 +11,10 @@ import (
 
 const idPrefix = "gid://shopify/TaxonomyCategory/"
 
+// topLevelNumbers are the published numbers of Shopify's top-level
+// categories. They are kept deliberately, for Shopify IDs only, so that
+// Path works offline and paths stay stable whatever order a taxonomy file
+// lists its verticals in; other taxonomies are numbered from the tree.
 var topLevelNumbers = map[string]int{
 	"aa": 1,  // Apparel & Accessories
 	"ap": 2,  // Animals & Pet Supplies
@@ -39,6 +44,13 @@ var topLevelNumbers = map[string]int{
 	"vp": 26, // Vehicles & Parts
 }
 
+// errStop ends a walk early once the category has been found.
+var errStop = errors.New("stop")
+
+// Path returns the dot-separated numeric path of a Shopify category ID,
+// e.g. "1.1.13.8" for gid://shopify/TaxonomyCategory/aa-1-13-8. Top-level
+// categories have fixed numbers, so no taxonomy is needed. The
+// gid://shopify/TaxonomyCategory/ prefix may be omitted.
 func Path(id string) (string, error) {
 	prefix, segments, err := parseID(id)
 	if err != nil {
@@ -60,51 +72,154 @@ func Path(id string) (string, error) {
 	return strings.Join(parts, "."), nil
 }
 
+// PathIn returns the numeric path of the category with the given ID in
+// tax. Shopify IDs are numbered as by Path. Categories of other
+// taxonomies, such as Google's, are numbered by the "-N" suffixes of their
+// IDs when all their siblings have distinct ones, and otherwise by their
+// position among their siblings in source order.
+func PathIn(tax *taxonomy.Taxonomy, id string) (string, error) {
+	if tax == nil {
+		return "", fmt.Errorf("taxonomy is nil")
+	}
+	id = strings.TrimSpace(id)
+	if id == "" {
+		return "", fmt.Errorf("taxonomy ID is empty")
+	}
+	var found []int
+	err := walk(tax.Roots, nil, func(node *taxonomy.Node, path []int) error {
+		if node.ID == id || localID(node.ID) == id {
+			found = path
+			return errStop
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errStop) {
+		return "", err
+	}
+	if found == nil {
+		return "", fmt.Errorf("taxonomy category %q not found", id)
+	}
+	parts := make([]string, len(found))
+	for i, n := range found {
+		parts[i] = strconv.Itoa(n)
+	}
+	return strings.Join(parts, "."), nil
+}
+
+// Maximum returns the largest number used in any category path, numbered
+// as by PathIn.
 func Maximum(tax *taxonomy.Taxonomy) (int, error) {
 	if tax == nil {
 		return 0, fmt.Errorf("taxonomy is nil")
 	}
 	max := 0
-	var walk func(node *taxonomy.Node) error
-	walk = func(node *taxonomy.Node) error {
-		if node == nil {
-			return nil
+	err := walk(tax.Roots, nil, func(_ *taxonomy.Node, path []int) error {
+		if n := path[len(path)-1]; n > max {
+			max = n
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return max, nil
+}
+
+// walk calls visit with the path of every node that has an ID, numbering
+// each set of siblings with one scheme; see number. Nodes without IDs,
+// such as Shopify's verticals, are skipped and their children numbered
+// along with their siblings. It returns the first error from numbering a
+// node or from visit.
+func walk(nodes []*taxonomy.Node, prefix []int, visit func(*taxonomy.Node, []int) error) error {
+	level := siblings(nodes, nil)
+	numbers, err := number(level)
+	if err != nil {
+		return err
+	}
+	for i, node := range level {
+		path := append(prefix[:len(prefix):len(prefix)], numbers[i])
+		if err := visit(node, path); err != nil {
+			return err
+		}
+		if err := walk(node.Children, path, visit); err != nil {
+			return err
 		}
-		if node.ID != "" {
+	}
+	return nil
+}
+
+// siblings appends the nodes with IDs in nodes to out, replacing nodes
+// without IDs by their children.
+func siblings(nodes []*taxonomy.Node, out []*taxonomy.Node) []*taxonomy.Node {
+	for _, node := range nodes {
+		switch {
+		case node == nil:
+		case node.ID == "":
+			out = siblings(node.Children, out)
+		default:
+			out = append(out, node)
+		}
+	}
+	return out
+}
+
+// number returns the numbers of a set of siblings. Shopify IDs carry their
+// numbers: the fixed number of the top-level category, then the last
+// segment. Other siblings are numbered by the "-N" suffixes of their IDs
+// when every one has a distinct suffix, and otherwise all by position.
+func number(level []*taxonomy.Node) ([]int, error) {
+	numbers := make([]int, len(level))
+	shopify, suffixed := true, true
+	seen := make(map[int]bool, len(level))
+	for i, node := range level {
+		shopify = shopify && strings.HasPrefix(node.ID, idPrefix)
+		n, ok := suffix(localID(node.ID))
+		suffixed = suffixed && ok && !seen[n]
+		seen[n] = true
+		numbers[i] = n
+	}
+	switch {
+	case shopify:
+		for i, node := range level {
 			prefix, segments, err := parseID(node.ID)
 			if err != nil {
-				return err
-			}
-			root, ok := topLevelNumbers[prefix]
-			if !ok {
-				return fmt.Errorf("unknown taxonomy prefix %q", prefix)
-			}
-			if root > max {
-				max = root
+				return nil, err
 			}
-			for _, segment := range segments {
-				n, err := strconv.Atoi(segment)
+			if len(segments) > 0 {
+				n, err := strconv.Atoi(segments[len(segments)-1])
 				if err != nil {
-					return fmt.Errorf("invalid taxonomy segment %q", segment)
-				}
-				if n > max {
-					max = n
+					return nil, fmt.Errorf("invalid taxonomy segment %q", segments[len(segments)-1])
 				}
+				numbers[i] = n
+				continue
 			}
-		}
-		for _, child := range node.Children {
-			if err := walk(child); err != nil {
-				return err
+			root, ok := topLevelNumbers[prefix]
+			if !ok {
+				return nil, fmt.Errorf("unknown taxonomy prefix %q", prefix)
 			}
+			numbers[i] = root
 		}
-		return nil
-	}
-	for _, root := range tax.Roots {
-		if err := walk(root); err != nil {
-			return 0, err
+	case !suffixed:
+		for i := range numbers {
+			numbers[i] = i + 1
 		}
 	}
-	return max, nil
+	return numbers, nil
+}
+
+// suffix returns N for an ID ending in "-N" with N > 0.
+func suffix(id string) (int, bool) {
+	idx := strings.LastIndex(id, "-")
+	if idx < 0 {
+		return 0, false
+	}
+	n, err := strconv.Atoi(id[idx+1:])
+	return n, err == nil && n > 0
+}
+
+// localID strips a URI prefix such as gid://shopify/TaxonomyCategory/.
+func localID(id string) string {
+	return id[strings.LastIndex(id, "/")+1:]
 }
 
 func parseID(id string) (string, []string, error) {
@@ -112,7 +227,7 @@ func parseID(id string) (string, []string, error) {
 	if trimmed == "" {
 		return "", nil, fmt.Errorf("taxonomy ID is empty")
 	}
-	if !strings.HasPrefix(trimmed, idPrefix) {
+	if strings.Contains(trimmed, "/") && !strings.HasPrefix(trimmed, idPrefix) {
 		return "", nil, fmt.Errorf("taxonomy ID %q does not use expected prefix", trimmed)
 	}
 	body := strings.TrimPrefix(trimmed, idPrefix)
@@ -1,17 +1,63 @@
 package taxopath
 
 import (
+	"strconv"
 	"testing"
 
 	"taxowalk/internal/taxonomy"
 )
 
+func shopifyTaxonomy() *taxonomy.Taxonomy {
+	return &taxonomy.Taxonomy{
+		Roots: []*taxonomy.Node{
+			{
+				Name: "Apparel & Accessories",
+				Children: []*taxonomy.Node{
+					{
+						ID: "gid://shopify/TaxonomyCategory/aa",
+						Children: []*taxonomy.Node{
+							{
+								ID: "gid://shopify/TaxonomyCategory/aa-1",
+								Children: []*taxonomy.Node{
+									{
+										ID: "gid://shopify/TaxonomyCategory/aa-1-13",
+										Children: []*taxonomy.Node{
+											{ID: "gid://shopify/TaxonomyCategory/aa-1-13-8"},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			{
+				Name: "Animals & Pet Supplies",
+				Children: []*taxonomy.Node{
+					{
+						ID: "gid://shopify/TaxonomyCategory/ap",
+						Children: []*taxonomy.Node{
+							{
+								ID: "gid://shopify/TaxonomyCategory/ap-2",
+								Children: []*taxonomy.Node{
+									{ID: "gid://shopify/TaxonomyCategory/ap-2-1"},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
 func TestPath(t *testing.T) {
 	cases := map[string]string{
 		"gid://shopify/TaxonomyCategory/aa":        "1",
 		"gid://shopify/TaxonomyCategory/aa-1":      "1.1",
 		"gid://shopify/TaxonomyCategory/aa-1-13-8": "1.1.13.8",
 		"gid://shopify/TaxonomyCategory/ap-2-1":    "2.2.1",
+		"ap-2":                                     "2.2",
 	}
 	for id, expected := range cases {
 		path, err := Path(id)
@@ -28,6 +74,106 @@ func TestPath(t *testing.T) {
 	}
 }
 
+func TestPathKeepsShopifyTopLevelNumbers(t *testing.T) {
+	expected := []string{"aa", "ap", "ae", "bt", "bu", "bi", "co", "el", "fb", "fr", "gc", "ha", "hb", "hg", "lb", "ma", "me", "os", "pa", "rc", "se", "so", "sg", "tg", "na", "vp"}
+	for i, prefix := range expected {
+		path, err := Path(prefix + "-3")
+		if err != nil {
+			t.Fatalf("Path(%q) returned error: %v", prefix, err)
+		}
+		if want := strconv.Itoa(i+1) + ".3"; path != want {
+			t.Fatalf("Path(%q) = %q, expected %q", prefix+"-3", path, want)
+		}
+	}
+}
+
+func TestPathIn(t *testing.T) {
+	tax := shopifyTaxonomy()
+	// The taxonomy lists Animals & Pet Supplies first; Shopify IDs keep
+	// their fixed numbers regardless.
+	tax.Roots[0], tax.Roots[1] = tax.Roots[1], tax.Roots[0]
+	cases := map[string]string{
+		"gid://shopify/TaxonomyCategory/aa":        "1",
+		"gid://shopify/TaxonomyCategory/aa-1-13-8": "1.1.13.8",
+		"gid://shopify/TaxonomyCategory/ap-2-1":    "2.2.1",
+		"ap-2":                                     "2.2",
+	}
+	for id, expected := range cases {
+		path, err := PathIn(tax, id)
+		if err != nil {
+			t.Fatalf("PathIn(%q) returned error: %v", id, err)
+		}
+		if path != expected {
+			t.Fatalf("PathIn(%q) = %q, expected %q", id, path, expected)
+		}
+	}
+
+	if _, err := PathIn(tax, "gid://shopify/TaxonomyCategory/aa-9"); err == nil {
+		t.Fatal("expected error for unknown category")
+	}
+}
+
+func TestPathNumbersByPosition(t *testing.T) {
+	tax := &taxonomy.Taxonomy{
+		Roots: []*taxonomy.Node{
+			{ID: "1", Children: []*taxonomy.Node{{ID: "3237"}, {ID: "2", Children: []*taxonomy.Node{{ID: "3"}}}}},
+			{ID: "166", Children: []*taxonomy.Node{{ID: "1604"}}},
+		},
+	}
+	cases := map[string]string{
+		"1":    "1",
+		"3237": "1.1",
+		"3":    "1.2.1",
+		"1604": "2.1",
+	}
+	for id, expected := range cases {
+		path, err := PathIn(tax, id)
+		if err != nil {
+			t.Fatalf("PathIn(%q) returned error: %v", id, err)
+		}
+		if path != expected {
+			t.Fatalf("PathIn(%q) = %q, expected %q", id, path, expected)
+		}
+	}
+	max, err := Maximum(tax)
+	if err != nil {
+		t.Fatalf("Maximum returned error: %v", err)
+	}
+	if max != 2 {
+		t.Fatalf("Maximum = %d, expected 2", max)
+	}
+}
+
+func TestPathNumbersEachSiblingSetOneWay(t *testing.T) {
+	tax := &taxonomy.Taxonomy{
+		Roots: []*taxonomy.Node{
+			// Not every sibling has a suffix, so all are numbered by
+			// position rather than x-2 and y both becoming 2.
+			{ID: "x-2", Children: []*taxonomy.Node{{ID: "x-2-7"}, {ID: "x-2-3"}}},
+			{ID: "y"},
+			// Duplicate suffixes also fall back to positions.
+			{ID: "z", Children: []*taxonomy.Node{{ID: "a-1"}, {ID: "b-1"}}},
+		},
+	}
+	cases := map[string]string{
+		"x-2":   "1",
+		"y":     "2",
+		"z":     "3",
+		"x-2-7": "1.7",
+		"x-2-3": "1.3",
+		"b-1":   "3.2",
+	}
+	for id, expected := range cases {
+		path, err := PathIn(tax, id)
+		if err != nil {
+			t.Fatalf("PathIn(%q) returned error: %v", id, err)
+		}
+		if path != expected {
+			t.Fatalf("PathIn(%q) = %q, expected %q", id, path, expected)
+		}
+	}
+}
+
 func TestMaximum(t *testing.T) {
 	tax := &taxonomy.Taxonomy{
 		Roots: []*taxonomy.Node{
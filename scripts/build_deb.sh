@@ -12,7 +12,12 @@ CONTROL_DIR="$BUILD_DIR/DEBIAN"
 rm -rf "$BUILD_DIR"
 mkdir -p "$BIN_DIR" "$MAN_DIR" "$CONTROL_DIR" "$DIST_DIR"
 
-binaries=(taxowalk taxoname taxopath)
+if [ ! -f "$ROOT_DIR/internal/taxonomy/builtin/taxonomy.json.gz" ]; then
+    echo "internal/taxonomy/builtin/taxonomy.json.gz is missing; run scripts/update_builtin_taxonomy.sh and commit it" >&2
+    exit 1
+fi
+
+binaries=(taxowalk taxoname taxofind taxodiff taxomap taxopath)
 for bin in "${binaries[@]}"; do
     GOOS=linux GOARCH=amd64 CGO_ENABLED=0 go build -trimpath -ldflags "-s -w -X main.version=$VERSION" -o "$BIN_DIR/$bin" "$ROOT_DIR/cmd/$bin"
 done
@@ -29,7 +34,7 @@ Section: utils
 Priority: optional
 Architecture: amd64
 Maintainer: Industrial Linguistics <packages@industrial-linguistics.com>
-Description: Shopify taxonomy utilities powered by OpenAI
+Description: Shopify taxonomy utilities powered by OpenAI and Anthropic models
  taxowalk classifies product descriptions into the Shopify taxonomy by
  iteratively querying the gpt-5.4-mini model. Companion tools resolve and
  inspect taxonomy identifiers.
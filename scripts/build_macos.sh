@@ -5,7 +5,12 @@ ROOT_DIR=$(cd "$(dirname "${BASH_SOURCE[0]}")/.." && pwd)
 VERSION=${1:-$(cat "$ROOT_DIR/VERSION")}
 DIST_DIR="$ROOT_DIR/dist/macos"
 WORK_DIR="$ROOT_DIR/build/macos"
-BINARIES=(taxowalk taxoname taxopath)
+BINARIES=(taxowalk taxoname taxofind taxodiff taxomap taxopath)
+
+if [ ! -f "$ROOT_DIR/internal/taxonomy/builtin/taxonomy.json.gz" ]; then
+    echo "internal/taxonomy/builtin/taxonomy.json.gz is missing; run scripts/update_builtin_taxonomy.sh and commit it" >&2
+    exit 1
+fi
 
 rm -rf "$WORK_DIR"
 mkdir -p "$WORK_DIR" "$DIST_DIR"
@@ -14,11 +14,16 @@ $Build = Join-Path $Root 'build/windows'
 Remove-Item -Recurse -Force -ErrorAction SilentlyContinue $Build
 New-Item -ItemType Directory -Force -Path $Build, $Dist | Out-Null
 
+$snapshot = Join-Path $Root 'internal/taxonomy/builtin/taxonomy.json.gz'
+if (-not (Test-Path $snapshot)) {
+    throw 'internal/taxonomy/builtin/taxonomy.json.gz is missing; run scripts/update_builtin_taxonomy.sh and commit it'
+}
+
 $env:GOOS = 'windows'
 $env:GOARCH = 'amd64'
 $env:CGO_ENABLED = '0'
 
-$binaries = @('taxowalk', 'taxoname', 'taxopath')
+$binaries = @('taxowalk', 'taxoname', 'taxofind', 'taxodiff', 'taxomap', 'taxopath')
 $builtPaths = @()
 foreach ($name in $binaries) {
     $binaryPath = Join-Path $Build ("{0}.exe" -f $name)
@@ -26,7 +31,7 @@ foreach ($name in $binaries) {
     $builtPaths += $binaryPath
 }
 
-$helpFiles = @('taxowalk-help.txt', 'taxoname-help.txt', 'taxopath-help.txt') |
+$helpFiles = @('taxowalk-help.txt', 'taxoname-help.txt', 'taxofind-help.txt', 'taxodiff-help.txt', 'taxomap-help.txt', 'taxopath-help.txt') |
     ForEach-Object { Join-Path $Root 'docs' $_ }
 
 $zipPath = Join-Path $Dist ("taxowalk_{0}_windows_amd64.zip" -f $Version)
@@ -0,0 +1,19 @@
+#!/usr/bin/env bash
+set -euo pipefail
+
+# Downloads the English Shopify taxonomy for a product-taxonomy tag or branch
+# and stores it, compressed, as the snapshot embedded in the binaries.
+
+ROOT_DIR=$(cd "$(dirname "${BASH_SOURCE[0]}")/.." && pwd)
+BUILTIN_DIR="$ROOT_DIR/internal/taxonomy/builtin"
+REF=${1:-$(cat "$BUILTIN_DIR/RELEASE")}
+URL="https://raw.githubusercontent.com/Shopify/product-taxonomy/${REF}/dist/en/taxonomy.json"
+OUT="$BUILTIN_DIR/taxonomy.json.gz"
+
+tmp=$(mktemp)
+trap 'rm -f "$tmp" "$tmp.gz"' EXIT
+
+curl -fsSL "$URL" -o "$tmp"
+gzip -9 -n -c "$tmp" > "$tmp.gz"
+mv "$tmp.gz" "$OUT"
+echo "Embedded $URL as $OUT"